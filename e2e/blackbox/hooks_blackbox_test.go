@@ -1,6 +1,8 @@
 package blackbox
 
 import (
+	"os"
+	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
@@ -10,13 +12,14 @@ import (
 // Contract: C6
 func TestHooksInstallUninstallIdempotentOnTempProfile(t *testing.T) {
 	t.Parallel()
-	if runtime.GOOS != "windows" {
-		t.Skip("windows-specific powershell hooks test")
-	}
 	h := newHarness(t)
 	h.mustRun("init")
 
 	profilePath := filepath.Join(h.rootDir, "home", "Documents", "PowerShell", "Microsoft.PowerShell_profile.ps1")
+	if runtime.GOOS != "windows" {
+		// pwsh on Linux/macOS reads its profile from XDG_CONFIG_HOME.
+		profilePath = filepath.Join(h.rootDir, "appdata", "powershell", "Microsoft.PowerShell_profile.ps1")
+	}
 
 	h.mustRun("hooks", "install", "powershell", "--yes")
 	h.mustRun("hooks", "install", "powershell", "--yes")
@@ -56,3 +59,29 @@ func TestHookRecorderAntiRecursion(t *testing.T) {
 		t.Fatalf("anti-recursion violated: cmdry command captured")
 	}
 }
+
+// Contract: C6
+func TestHooksSelfTestBash(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks test runs POSIX shells")
+	}
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not installed")
+	}
+	h := newHarness(t)
+
+	res := h.mustRun("hooks", "test", "bash")
+	for _, want := range []string{
+		"records failing command with exit code  PASS    FAILED, exit 3",
+		"skips cmdry self-invocation             PASS",
+		"bash hook works",
+	} {
+		if !strings.Contains(res.Stdout, want) {
+			t.Fatalf("expected %q in self-test output:\n%s", want, res.Stdout)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(h.rootDir, "appdata", "commandry")); !os.IsNotExist(err) {
+		t.Fatalf("self-test must not touch the real config root (stat err: %v)", err)
+	}
+}
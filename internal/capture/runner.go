@@ -62,6 +62,42 @@ func RunCommand(ctx context.Context, args []string, cwd string) (RunResult, erro
 	return result, err
 }
 
+// ExpectedStatus classifies the exit code of a command that ran against the
+// caller's expectations. expectFail accepts any nonzero exit code; otherwise
+// the code must be listed in expected.
+func ExpectedStatus(exitCode int, expected []int, expectFail bool) (status string, reason string) {
+	if expectFail {
+		if exitCode == 0 {
+			return "UNEXPECTED", "unexpected_success"
+		}
+		return "OK", ""
+	}
+	for _, code := range expected {
+		if code == exitCode {
+			return "OK", ""
+		}
+	}
+	return "UNEXPECTED", "unexpected_exit"
+}
+
+// ShellCommand returns the argv that runs script through the user's shell:
+// $SHELL -c on POSIX systems and %ComSpec% /c on Windows.
+func ShellCommand(script string) []string {
+	if runtime.GOOS == "windows" {
+		comspec := os.Getenv("ComSpec")
+		if comspec == "" {
+			comspec = "cmd.exe"
+		}
+		return []string{comspec, "/c", script}
+	}
+
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+	return []string{shell, "-c", script}
+}
+
 func classifyStartError(err error) string {
 	var execErr *exec.Error
 	if errors.As(err, &execErr) && errors.Is(execErr.Err, exec.ErrNotFound) {
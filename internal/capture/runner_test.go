@@ -75,6 +75,36 @@ func TestRunCommand_ClassifiesExitCodes(t *testing.T) {
 	})
 }
 
+func TestExpectedStatus(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		exitCode   int
+		expected   []int
+		expectFail bool
+		status     string
+		reason     string
+	}{
+		{name: "listed nonzero", exitCode: 1, expected: []int{0, 1}, status: "OK"},
+		{name: "unlisted", exitCode: 2, expected: []int{0, 1}, status: "UNEXPECTED", reason: "unexpected_exit"},
+		{name: "expected failure", exitCode: 3, expectFail: true, status: "OK"},
+		{name: "unexpected success", exitCode: 0, expectFail: true, status: "UNEXPECTED", reason: "unexpected_success"},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			status, reason := ExpectedStatus(tc.exitCode, tc.expected, tc.expectFail)
+			if status != tc.status || reason != tc.reason {
+				t.Fatalf("ExpectedStatus() = %q, %q; want %q, %q", status, reason, tc.status, tc.reason)
+			}
+		})
+	}
+}
+
 func TestHelperProcess(t *testing.T) {
 	// Arguments after "--" are controlled by our tests.
 	args := os.Args
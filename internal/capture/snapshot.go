@@ -0,0 +1,137 @@
+package capture
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const maxTrackedFiles = 10000
+
+// FileState is the metadata kept for one tracked file.
+type FileState struct {
+	Size    int64
+	ModTime time.Time
+	SHA256  string
+}
+
+// Snapshot maps display paths (relative to the working directory when
+// possible, slash-separated) to file state.
+type Snapshot map[string]FileState
+
+type FileChange struct {
+	Path   string
+	Change string // created, modified, deleted
+}
+
+// SnapshotFiles records size, mtime and SHA-256 for every regular file under
+// paths. Paths that do not exist yet are skipped so files created by the
+// command show up in the diff. Files are always hashed: mtime granularity is
+// too coarse to detect fast rewrites that keep the size.
+func SnapshotFiles(cwd string, paths []string) (Snapshot, error) {
+	snap := make(Snapshot)
+	for _, raw := range paths {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		root := raw
+		if !filepath.IsAbs(root) {
+			root = filepath.Join(cwd, root)
+		}
+
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) && path == root {
+					return nil
+				}
+				return err
+			}
+			if d.IsDir() {
+				if d.Name() == ".git" && path != root {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+
+			key := displayPath(cwd, path)
+			if _, ok := snap[key]; ok {
+				return nil
+			}
+			if len(snap) >= maxTrackedFiles {
+				return fmt.Errorf("more than %d files under tracked paths", maxTrackedFiles)
+			}
+
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			sum, err := hashFile(path)
+			if err != nil {
+				return err
+			}
+			snap[key] = FileState{Size: info.Size(), ModTime: info.ModTime(), SHA256: sum}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("snapshot %s: %w", raw, err)
+		}
+	}
+	return snap, nil
+}
+
+// DiffSnapshots lists created, modified and deleted files sorted by path.
+// Files that were only touched (same size and content) are not reported.
+func DiffSnapshots(before, after Snapshot) []FileChange {
+	changes := make([]FileChange, 0)
+	for path, state := range after {
+		old, ok := before[path]
+		switch {
+		case !ok:
+			changes = append(changes, FileChange{Path: path, Change: "created"})
+		case old.Size != state.Size || old.SHA256 != state.SHA256:
+			changes = append(changes, FileChange{Path: path, Change: "modified"})
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			changes = append(changes, FileChange{Path: path, Change: "deleted"})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
+
+func displayPath(cwd, path string) string {
+	if rel, err := filepath.Rel(cwd, path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(path)
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
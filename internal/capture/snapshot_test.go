@@ -0,0 +1,58 @@
+package capture
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSnapshotFilesDiff(t *testing.T) {
+	t.Parallel()
+
+	cwd := t.TempDir()
+	writeTestFile(t, filepath.Join(cwd, "conf", "app.yaml"), "replicas: 1\n")
+	writeTestFile(t, filepath.Join(cwd, "conf", "old.yaml"), "legacy: true\n")
+	writeTestFile(t, filepath.Join(cwd, "conf", "same.yaml"), "keep: true\n")
+	writeTestFile(t, filepath.Join(cwd, "conf", ".git", "HEAD"), "ref: main\n")
+
+	before, err := SnapshotFiles(cwd, []string{"conf", "out.yaml"})
+	if err != nil {
+		t.Fatalf("snapshot before: %v", err)
+	}
+	if _, ok := before["conf/.git/HEAD"]; ok {
+		t.Fatalf("expected nested .git directory to be skipped")
+	}
+
+	writeTestFile(t, filepath.Join(cwd, "conf", "app.yaml"), "replicas: 3\n")
+	if err := os.Remove(filepath.Join(cwd, "conf", "old.yaml")); err != nil {
+		t.Fatalf("remove old.yaml: %v", err)
+	}
+	writeTestFile(t, filepath.Join(cwd, "out.yaml"), "kind: Deployment\n")
+
+	after, err := SnapshotFiles(cwd, []string{"conf", "out.yaml"})
+	if err != nil {
+		t.Fatalf("snapshot after: %v", err)
+	}
+
+	got := DiffSnapshots(before, after)
+	want := []FileChange{
+		{Path: "conf/app.yaml", Change: "modified"},
+		{Path: "conf/old.yaml", Change: "deleted"},
+		{Path: "out.yaml", Change: "created"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("diff mismatch\n got: %#v\nwant: %#v", got, want)
+	}
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir %s: %v", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
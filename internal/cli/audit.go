@@ -0,0 +1,153 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fixi2/Commandry/internal/policy"
+	"github.com/fixi2/Commandry/internal/store"
+	"github.com/spf13/cobra"
+)
+
+// recordAudit logs the policy event behind a recorded step. The step is
+// already stored, so a failed write only warns.
+func recordAudit(cmd *cobra.Command, s store.SessionStore, sessionID string, step store.Step, result policy.Result) {
+	err := s.AppendAudit(cmd.Context(), store.AuditEvent{
+		Timestamp: step.Timestamp,
+		SessionID: sessionID,
+		Event:     step.Reason,
+		Rule:      result.Rule,
+		Tool:      result.Tool,
+		Source:    "run",
+	})
+	if err != nil {
+		printWarn(cmd.ErrOrStderr(), "Failed to write the audit log (%v).", err)
+	}
+}
+
+func newPolicyAuditCmd(s store.SessionStore) *cobra.Command {
+	var since string
+
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Summarize policy blocks and redactions by rule and tool",
+		Long: "Summarize audit.jsonl in the config root, which records every command `cmdry run` or the shell hooks\n" +
+			"blocked or redacted with the denylist: when, in which session, by which rule and for which tool.\n" +
+			"Commands themselves are never logged.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			from, err := parseSince(since, time.Now())
+			if err != nil {
+				return err
+			}
+			events, err := s.AuditEvents(cmd.Context(), from)
+			if err != nil {
+				if errors.Is(err, store.ErrNotInitialized) {
+					return errors.New("Commandry is not initialized. Run `cmdry init` first")
+				}
+				return fmt.Errorf("read audit log: %w", err)
+			}
+			printAuditSummary(cmd.OutOrStdout(), events, from)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&since, "since", "", "Only count events since a duration ago (24h, 7d) or a date (2006-01-02, RFC 3339)")
+	return cmd
+}
+
+// parseSince accepts a Go duration, a number of days such as 7d, a date or an
+// RFC 3339 timestamp. Empty means no limit.
+func parseSince(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q: use a duration (24h, 7d), a date (2006-01-02) or an RFC 3339 time", value)
+}
+
+type auditCount struct {
+	rule     string
+	tool     string
+	blocked  int
+	redacted int
+}
+
+func printAuditSummary(w io.Writer, events []store.AuditEvent, since time.Time) {
+	period := "recorded"
+	if !since.IsZero() {
+		period = "since " + since.Format(time.RFC3339)
+	}
+	if len(events) == 0 {
+		printOK(w, "No policy blocks or redactions %s", period)
+		return
+	}
+
+	byKey := make(map[[2]string]*auditCount)
+	blocked, redacted := 0, 0
+	for _, event := range events {
+		key := [2]string{event.Rule, event.Tool}
+		count, ok := byKey[key]
+		if !ok {
+			count = &auditCount{rule: event.Rule, tool: event.Tool}
+			byKey[key] = count
+		}
+		if event.Event == "policy_blocked" {
+			count.blocked++
+			blocked++
+		} else {
+			count.redacted++
+			redacted++
+		}
+	}
+	counts := make([]*auditCount, 0, len(byKey))
+	for _, count := range byKey {
+		if count.rule == "" {
+			count.rule = "(unknown)"
+		}
+		if count.tool == "" {
+			count.tool = "-"
+		}
+		counts = append(counts, count)
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		a, b := counts[i], counts[j]
+		if a.blocked+a.redacted != b.blocked+b.redacted {
+			return a.blocked+a.redacted > b.blocked+b.redacted
+		}
+		if a.rule != b.rule {
+			return a.rule < b.rule
+		}
+		return a.tool < b.tool
+	})
+
+	fmt.Fprintf(w, "Policy events %s: %d (blocked %d, redacted %d)\n\n", period, len(events), blocked, redacted)
+	ruleWidth, toolWidth := len("RULE"), len("TOOL")
+	for _, count := range counts {
+		ruleWidth = max(ruleWidth, len(count.rule))
+		toolWidth = max(toolWidth, len(count.tool))
+	}
+	fmt.Fprintf(w, "%-*s  %-*s  %7s  %8s\n", ruleWidth, "RULE", toolWidth, "TOOL", "BLOCKED", "REDACTED")
+	for _, count := range counts {
+		fmt.Fprintf(w, "%-*s  %-*s  %7d  %8d\n", ruleWidth, count.rule, toolWidth, count.tool, count.blocked, count.redacted)
+	}
+}
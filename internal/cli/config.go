@@ -0,0 +1,232 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fixi2/Commandry/internal/policy"
+	"github.com/fixi2/Commandry/internal/store"
+	"github.com/spf13/cobra"
+)
+
+func newConfigCmd(s store.SessionStore) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Validate and inspect config.yaml and .commandry.yaml",
+	}
+	cmd.AddCommand(
+		newConfigValidateCmd(s),
+		newConfigShowCmd(s),
+	)
+	return cmd
+}
+
+func newConfigValidateCmd(s store.SessionStore) *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate",
+		Short: "Check config.yaml and .commandry.yaml for unknown keys and invalid values",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			out := cmd.OutOrStdout()
+			var invalid []string
+
+			path := configFilePath(s)
+			if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+				printWarn(out, "No config file at %s; built-in defaults apply", path)
+			} else {
+				problems, err := validateConfigFile(path)
+				if err != nil {
+					return err
+				}
+				for _, problem := range problems {
+					printError(out, "%s", problem)
+				}
+				if len(problems) > 0 {
+					invalid = append(invalid, fmt.Sprintf("%s has %d problem(s)", path, len(problems)))
+				} else {
+					printOK(out, "Config is valid: %s", path)
+				}
+			}
+
+			cwd, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("get working directory: %w", err)
+			}
+			projectPath, err := policy.FindProjectConfig(cwd)
+			if err != nil {
+				return err
+			}
+			if projectPath != "" {
+				problems, err := validateProjectFile(projectPath)
+				if err != nil {
+					return err
+				}
+				for _, problem := range problems {
+					printError(out, "%s: %s", projectPath, problem)
+				}
+				if len(problems) > 0 {
+					invalid = append(invalid, fmt.Sprintf("%s has %d problem(s)", projectPath, len(problems)))
+				} else {
+					printOK(out, "Project config is valid: %s", projectPath)
+				}
+			}
+
+			if len(invalid) > 0 {
+				return &ExitError{Code: 1, Err: errors.New(strings.Join(invalid, "; "))}
+			}
+			return nil
+		},
+	}
+}
+
+func newConfigShowCmd(s store.SessionStore) *cobra.Command {
+	var effective bool
+
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Print config.yaml",
+		Long: "Print config.yaml as written. With --effective, print the configuration\n" +
+			"Commandry actually uses: the file merged over the built-in defaults, plus the\n" +
+			"project .commandry.yaml for the current directory, with its sources listed first.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			path := configFilePath(s)
+			_, statErr := os.Stat(path)
+			missing := errors.Is(statErr, os.ErrNotExist)
+
+			if !effective {
+				if missing {
+					return fmt.Errorf("no config file at %s. Run `cmdry init` or use --effective to see the defaults", path)
+				}
+				data, err := os.ReadFile(path)
+				if err != nil {
+					return fmt.Errorf("read config: %w", err)
+				}
+				_, err = cmd.OutOrStdout().Write(data)
+				return err
+			}
+
+			cwd, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("get working directory: %w", err)
+			}
+			loaded, err := loadPolicyConfig(s.RootDir(), cwd)
+			if err != nil {
+				return err
+			}
+			printConfigSources(cmd.OutOrStdout(), loaded, "# ")
+			cfg := loaded.config
+			out, err := policy.FormatConfig(cfg)
+			if err != nil {
+				return err
+			}
+			_, err = cmd.OutOrStdout().Write(out)
+			return err
+		},
+	}
+
+	cmd.Flags().BoolVar(&effective, "effective", false, "Print the config merged over built-in defaults")
+	return cmd
+}
+
+// validateConfigFile returns the problems found in path, including patterns
+// that parse but do not compile in the base policy or a profile.
+func validateConfigFile(path string) ([]policy.ConfigProblem, error) {
+	cfg, err := policy.ParseConfigFile(path)
+	if err != nil {
+		var cfgErr *policy.ConfigError
+		if errors.As(err, &cfgErr) {
+			return cfgErr.Problems, nil
+		}
+		return nil, err
+	}
+	if _, err := policy.NewResolver(cfg); err != nil {
+		return []policy.ConfigProblem{{Message: err.Error()}}, nil
+	}
+	return nil, nil
+}
+
+func validateProjectFile(path string) ([]policy.ConfigProblem, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read project config: %w", err)
+	}
+	if _, err := policy.ParseProjectConfig(string(data)); err != nil {
+		var cfgErr *policy.ConfigError
+		if errors.As(err, &cfgErr) {
+			return cfgErr.Problems, nil
+		}
+		return nil, err
+	}
+	return nil, nil
+}
+
+func configFilePath(s store.SessionStore) string {
+	return filepath.Join(s.RootDir(), "config.yaml")
+}
+
+// policyConfig is the effective config and the files it was read from.
+type policyConfig struct {
+	config      policy.Config
+	userPath    string // "" when config.yaml does not exist
+	projectPath string // "" when no .commandry.yaml applies
+	// ignored lists project settings dropped because they weaken config.yaml.
+	ignored []string
+}
+
+// loadPolicyConfig reads config.yaml from rootDir and merges the
+// .commandry.yaml that applies to dir. On error the result still holds a
+// usable config: the defaults when config.yaml is invalid, config.yaml alone
+// when the project file is.
+func loadPolicyConfig(rootDir, dir string) (policyConfig, error) {
+	loaded := policyConfig{config: policy.DefaultConfig()}
+	userPath := filepath.Join(rootDir, "config.yaml")
+	if _, err := os.Stat(userPath); err == nil {
+		cfg, err := policy.ParseConfigFile(userPath)
+		if err != nil {
+			return loaded, fmt.Errorf("load policy config from %s: %w", userPath, err)
+		}
+		loaded.config, loaded.userPath = cfg, userPath
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return loaded, fmt.Errorf("load policy config from %s: %w", userPath, err)
+	}
+
+	if !loaded.config.Project.Enabled {
+		return loaded, nil
+	}
+	project, err := policy.LoadProjectConfig(dir)
+	if err != nil {
+		return loaded, fmt.Errorf("load project config: %w", err)
+	}
+	if project.Path == "" {
+		return loaded, nil
+	}
+	loaded.projectPath = project.Path
+	loaded.config, loaded.ignored = loaded.config.MergeProject(project)
+	return loaded, nil
+}
+
+// printConfigSources lists where the effective config comes from, using
+// prefix for every line.
+func printConfigSources(w io.Writer, loaded policyConfig, prefix string) {
+	user := loaded.userPath
+	if user == "" {
+		user = "none (built-in defaults)"
+	}
+	fmt.Fprintf(w, "%sUser config: %s\n", prefix, user)
+	switch {
+	case loaded.projectPath != "":
+		fmt.Fprintf(w, "%sProject config: %s\n", prefix, loaded.projectPath)
+	case !loaded.config.Project.Enabled:
+		fmt.Fprintf(w, "%sProject config: disabled (project.enabled is false)\n", prefix)
+	default:
+		fmt.Fprintf(w, "%sProject config: none\n", prefix)
+	}
+	for _, note := range loaded.ignored {
+		fmt.Fprintf(w, "%sIgnored from project config: %s\n", prefix, note)
+	}
+}
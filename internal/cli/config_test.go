@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigValidateAndShowEffective(t *testing.T) {
+	configRoot := setupCLIEnv(t)
+
+	out := mustExecuteCLI(t, "config", "validate")
+	if !strings.Contains(out, "built-in defaults apply") {
+		t.Fatalf("expected missing-config note, got %q", out)
+	}
+	out = mustExecuteCLI(t, "config", "show", "--effective")
+	if !strings.Contains(out, "  enforce_denylist: false\n") || !strings.Contains(out, "  annotate: auto\n") {
+		t.Fatalf("expected defaults in effective config, got %q", out)
+	}
+
+	mustExecuteCLI(t, "init")
+	out = mustExecuteCLI(t, "config", "validate")
+	if !strings.Contains(out, "Config is valid") {
+		t.Fatalf("expected default config.yaml to validate, got %q", out)
+	}
+
+	configPath := filepath.Join(configRoot, "config.yaml")
+	config := "policy:\n  enforce_denylst: true\nhooks:\n  ignore:\n    command_not_found: maybe\n"
+	if err := os.WriteFile(configPath, []byte(config), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	out, err := executeCLI(t, "config", "validate")
+	var exitErr *ExitError
+	if !asExitErrorCLI(err, &exitErr) || exitErr.ExitCode() != 1 {
+		t.Fatalf("expected exit code 1, got %v", err)
+	}
+	for _, want := range []string{
+		"line 2: unknown key policy.enforce_denylst",
+		`line 5: hooks.ignore.command_not_found must be true or false, got "maybe"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in validate output, got %q", want, out)
+		}
+	}
+	if _, err := executeCLI(t, "config", "show", "--effective"); err == nil {
+		t.Fatalf("expected --effective to fail on an invalid config")
+	}
+
+	config = "policy:\n  enforce_denylist: true\nexport:\n  annotate: never\n"
+	if err := os.WriteFile(configPath, []byte(config), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	out = mustExecuteCLI(t, "config", "show")
+	if out != config {
+		t.Fatalf("expected config show to print the file, got %q", out)
+	}
+	out = mustExecuteCLI(t, "config", "show", "--effective")
+	for _, want := range []string{"  enforce_denylist: true\n", "  annotate: never\n", "    - printenv\n"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in effective config, got %q", want, out)
+		}
+	}
+}
+
+func TestConfigMergesProjectFile(t *testing.T) {
+	configRoot := setupCLIEnv(t)
+	mustExecuteCLI(t, "init")
+	if err := os.WriteFile(filepath.Join(configRoot, "config.yaml"), []byte("policy:\n  enforce_denylist: true\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	repo := t.TempDir()
+	workDir := filepath.Join(repo, "deploy")
+	for _, dir := range []string{filepath.Join(repo, ".git"), workDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+	}
+	projectPath := filepath.Join(repo, ".commandry.yaml")
+	project := "policy:\n  denylist: [\"terraform destroy*\"]\n  enforce_denylist: false\n"
+	if err := os.WriteFile(projectPath, []byte(project), 0o600); err != nil {
+		t.Fatalf("write project config: %v", err)
+	}
+	chdirForTest(t, workDir)
+
+	out := mustExecuteCLI(t, "config", "show", "--effective")
+	for _, want := range []string{
+		"# Project config: " + projectPath + "\n",
+		"# Ignored from project config: policy.enforce_denylist: false would weaken config.yaml\n",
+		"  enforce_denylist: true\n",
+		"    - terraform destroy*\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in effective config, got %q", want, out)
+		}
+	}
+	if out := mustExecuteCLI(t, "policy", "test", "--", "terraform", "destroy"); !strings.Contains(out, "Denied and blocked") {
+		t.Fatalf("expected the project denylist to be enforced, got %q", out)
+	}
+	if out := mustExecuteCLI(t, "doctor"); !strings.Contains(out, "Project config: "+projectPath) {
+		t.Fatalf("expected doctor to list the project config, got %q", out)
+	}
+
+	if err := os.WriteFile(projectPath, []byte("policy:\n  denylist: []\nhooks: {}\n"), 0o600); err != nil {
+		t.Fatalf("write project config: %v", err)
+	}
+	out, err := executeCLI(t, "config", "validate")
+	var exitErr *ExitError
+	if !asExitErrorCLI(err, &exitErr) || exitErr.ExitCode() != 1 {
+		t.Fatalf("expected exit code 1, got %v", err)
+	}
+	if !strings.Contains(out, "Config is valid") || !strings.Contains(out, projectPath+": line 3: unknown key hooks") {
+		t.Fatalf("expected the project file problem in validate output, got %q", out)
+	}
+}
+
+func chdirForTest(t *testing.T, dir string) {
+	t.Helper()
+
+	prev, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("get working directory: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("chdir %s: %v", dir, err)
+	}
+	t.Cleanup(func() {
+		if err := os.Chdir(prev); err != nil {
+			t.Fatalf("restore working directory: %v", err)
+		}
+	})
+}
@@ -27,6 +27,14 @@ func newDoctorCmd(s store.SessionStore) *cobra.Command {
 			fmt.Fprintf(cmd.OutOrStdout(), "OS: %s/%s\n", runtime.GOOS, runtime.GOARCH)
 			fmt.Fprintf(cmd.OutOrStdout(), "Root dir: %s\n", root)
 			fmt.Fprintf(cmd.OutOrStdout(), "Config file: %s\n", configPath)
+			if cwd, err := os.Getwd(); err == nil {
+				loaded, err := loadPolicyConfig(root, cwd)
+				printConfigSources(cmd.OutOrStdout(), loaded, "")
+				if err != nil {
+					printWarn(cmd.OutOrStdout(), "Policy config: %v", err)
+					printHint(cmd.OutOrStdout(), "Run `cmdry config validate` for details.")
+				}
+			}
 			fmt.Fprintf(cmd.OutOrStdout(), "Sessions store: %s\n", sessionsPath)
 			fmt.Fprintf(cmd.OutOrStdout(), "Active session file: %s\n", activeSessionPath)
 
@@ -54,9 +54,13 @@ func collectFlaggedSteps(session *store.Session) []flaggedStep {
 }
 
 func isFailedStep(step store.Step) bool {
-	if strings.EqualFold(step.Status, "FAILED") {
+	if strings.EqualFold(step.Status, "FAILED") || strings.EqualFold(step.Status, "UNEXPECTED") {
 		return true
 	}
+	if strings.EqualFold(step.Status, "OK") {
+		// A nonzero exit that matched --expect-exit/--expect-fail.
+		return false
+	}
 	return step.ExitCode != nil && *step.ExitCode != 0
 }
 
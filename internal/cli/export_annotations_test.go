@@ -15,12 +15,17 @@ func TestCollectFlaggedSteps(t *testing.T) {
 		{Command: "[REDACTED BY POLICY]", Status: "REDACTED", Reason: "policy_redacted"},
 		{Command: "failed command", Status: "FAILED", ExitCode: intPtr(1)},
 		{Command: "nonzero no status", ExitCode: intPtr(2)},
+		{Command: "grep -q foo app.log", Status: "OK", ExitCode: intPtr(1), ExpectedExit: []int{0, 1}},
+		{Command: "test -f missing", Status: "UNEXPECTED", Reason: "unexpected_success", ExitCode: intPtr(0), ExpectFail: true},
 	}
 
 	session := &store.Session{Steps: steps}
 	flagged := collectFlaggedSteps(session)
-	if len(flagged) != 3 {
-		t.Fatalf("expected 3 flagged steps, got %d", len(flagged))
+	if len(flagged) != 4 {
+		t.Fatalf("expected 4 flagged steps, got %d", len(flagged))
+	}
+	if flagged[3].StepIndex != 5 {
+		t.Fatalf("expected UNEXPECTED step to be flagged and expected nonzero exit to be skipped: %#v", flagged)
 	}
 	if flagged[0].Number != 1 || flagged[1].Number != 2 || flagged[2].Number != 3 {
 		t.Fatalf("unexpected numbering: %#v", flagged)
@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"strings"
+
+	"github.com/fixi2/Commandry/internal/export"
+)
+
+// localIdentity returns the login name and hostname `export --anonymize`
+// hides. On Windows the domain part of DOMAIN\user is dropped.
+func localIdentity() (string, string) {
+	name := ""
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	if name == "" {
+		name = os.Getenv("USER")
+	}
+	if name == "" {
+		name = os.Getenv("USERNAME")
+	}
+	if i := strings.LastIndex(name, `\`); i >= 0 {
+		name = name[i+1:]
+	}
+	host, _ := os.Hostname()
+	return name, host
+}
+
+// printPseudonyms prints the anonymization mapping. It goes to the terminal
+// only, never into the runbook.
+func printPseudonyms(w io.Writer, mapping []export.Pseudonym) {
+	if len(mapping) == 0 {
+		fmt.Fprintln(w, "Anonymized values: none found")
+		return
+	}
+	kindWidth, originalWidth := len("KIND"), len("ORIGINAL")
+	for _, p := range mapping {
+		kindWidth = max(kindWidth, len(p.Kind))
+		originalWidth = max(originalWidth, len(p.Original))
+	}
+	fmt.Fprintln(w, "Anonymized values (not written to the runbook):")
+	fmt.Fprintf(w, "  %-*s  %-*s  %s\n", kindWidth, "KIND", originalWidth, "ORIGINAL", "PSEUDONYM")
+	for _, p := range mapping {
+		fmt.Fprintf(w, "  %-*s  %-*s  %s\n", kindWidth, p.Kind, originalWidth, p.Original, p.Replacement)
+	}
+}
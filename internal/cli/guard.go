@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var errGuardConfirmationRequired = errors.New("confirmation required for guarded command; rerun with --yes for non-interactive use")
+
+// confirmGuardedCommand asks the user to type "yes" before a guarded command
+// runs. Any other answer declines; an empty non-interactive stdin returns
+// errGuardConfirmationRequired.
+func confirmGuardedCommand(cmd *cobra.Command, rule, env, command string) (bool, error) {
+	if env != "" {
+		printWarn(cmd.ErrOrStderr(), "Command matches guarded rule %q (env: %s):", rule, env)
+	} else {
+		printWarn(cmd.ErrOrStderr(), "Command matches guarded rule %q:", rule)
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "   %s\n", command)
+	fmt.Fprintln(cmd.ErrOrStderr(), "Type 'yes' to run it (anything else aborts):")
+
+	reader := bufio.NewReader(cmd.InOrStdin())
+	raw, err := reader.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, fmt.Errorf("read confirmation: %w", err)
+	}
+	if errors.Is(err, io.EOF) && strings.TrimSpace(raw) == "" {
+		return false, errGuardConfirmationRequired
+	}
+	return strings.EqualFold(strings.TrimSpace(raw), "yes"), nil
+}
@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/fixi2/Commandry/internal/hooks"
+	"github.com/fixi2/Commandry/internal/policy"
+	"github.com/fixi2/Commandry/internal/store"
+	"github.com/spf13/cobra"
+)
+
+func newHookdCmd(s store.SessionStore, policies *policy.Resolver, stateStore hooks.StateStore) *cobra.Command {
+	return &cobra.Command{
+		Use:   "hookd",
+		Short: "Serve shell hook events over a Unix socket to cut prompt latency",
+		Long: "Run a foreground server that records shell hook events sent to <config root>/hookd.sock.\n" +
+			"Installed bash, zsh and fish hooks use the socket when it exists and fall back to `cmdry hook record` otherwise.\n" +
+			"Policy config, including the .commandry.yaml of the directory hookd starts in, is read once at startup;\n" +
+			"restart hookd after editing either file.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			initialized, err := s.IsInitialized(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("check initialization: %w", err)
+			}
+			if !initialized {
+				return errors.New("Commandry is not initialized. Run `cmdry init` first")
+			}
+
+			path := hooks.SocketPath(s.RootDir())
+			ln, err := hooks.Listen(path)
+			if err != nil {
+				if errors.Is(err, hooks.ErrServerRunning) {
+					return fmt.Errorf("hookd is already listening on %s", path)
+				}
+				return err
+			}
+			defer os.Remove(path)
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			printOK(cmd.OutOrStdout(), "hookd listening on %s", path)
+			printHint(cmd.OutOrStdout(), "Press Ctrl+C to stop. Shell hooks fall back to one-shot recording while hookd is down.")
+			server := hooks.NewServer(hooks.NewRecorder(s, policies, stateStore), cmd.ErrOrStderr())
+			if err := server.Serve(ctx, ln); err != nil {
+				return fmt.Errorf("hookd: %w", err)
+			}
+			return nil
+		},
+	}
+}
@@ -3,6 +3,7 @@ package cli
 import (
 	"errors"
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/fixi2/Commandry/internal/hooks"
@@ -22,8 +23,9 @@ func newHooksCmd(s store.SessionStore, stateStore hooks.StateStore) *cobra.Comma
 		newHooksEnableCmd(stateStore),
 		newHooksDisableCmd(stateStore),
 		newHooksConfigureCmd(stateStore),
-		newHooksInstallCmd(),
-		newHooksUninstallCmd(),
+		newHooksInstallCmd(stateStore),
+		newHooksUninstallCmd(stateStore),
+		newHooksTestCmd(),
 	)
 	return cmd
 }
@@ -53,15 +55,12 @@ func newHooksStatusCmd(s store.SessionStore, stateStore hooks.StateStore) *cobra
 			if psDetails != "" {
 				fmt.Fprintln(cmd.OutOrStdout(), psDetails)
 			}
-			bashInstalled, bashDetails := bashInstallStatus()
-			fmt.Fprintf(cmd.OutOrStdout(), "Bash hook installed: %s\n", boolLabel(bashInstalled))
-			if bashDetails != "" {
-				fmt.Fprintln(cmd.OutOrStdout(), bashDetails)
-			}
-			zshInstalled, zshDetails := zshInstallStatus()
-			fmt.Fprintf(cmd.OutOrStdout(), "Zsh hook installed: %s\n", boolLabel(zshInstalled))
-			if zshDetails != "" {
-				fmt.Fprintln(cmd.OutOrStdout(), zshDetails)
+			for _, h := range []profileHook{bashProfileHook, zshProfileHook, fishProfileHook} {
+				installed, details := h.installStatus(state)
+				fmt.Fprintf(cmd.OutOrStdout(), "%s hook installed: %s\n", h.label, boolLabel(installed))
+				if details != "" {
+					fmt.Fprintln(cmd.OutOrStdout(), details)
+				}
 			}
 			return nil
 		},
@@ -139,23 +138,25 @@ func newHooksConfigureCmd(stateStore hooks.StateStore) *cobra.Command {
 	return cmd
 }
 
-func newHookCmd(s store.SessionStore, p *policy.Policy, stateStore hooks.StateStore) *cobra.Command {
+func newHookCmd(s store.SessionStore, policies *policy.Resolver, stateStore hooks.StateStore) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:    "hook",
 		Short:  "Internal hooks endpoint",
 		Hidden: true,
 	}
-	cmd.AddCommand(newHookRecordCmd(s, p, stateStore))
+	cmd.AddCommand(newHookRecordCmd(s, policies, stateStore))
 	return cmd
 }
 
-func newHookRecordCmd(s store.SessionStore, p *policy.Policy, stateStore hooks.StateStore) *cobra.Command {
+func newHookRecordCmd(s store.SessionStore, policies *policy.Resolver, stateStore hooks.StateStore) *cobra.Command {
 	var (
 		rawCommand string
 		cwd        string
 		exitCode   int
 		durationMS int64
 		timestamp  string
+		debug      bool
+		shellToken string
 	)
 
 	cmd := &cobra.Command{
@@ -172,18 +173,36 @@ func newHookRecordCmd(s store.SessionStore, p *policy.Policy, stateStore hooks.S
 				ts = parsed
 			}
 
-			rec := hooks.NewRecorder(s, p, stateStore)
+			if !cmd.Flags().Changed("shell-token") {
+				shellToken = os.Getenv(hooks.ShellTokenEnv)
+			}
+
+			rec := hooks.NewRecorder(s, policies, stateStore)
 			result, err := rec.Record(cmd.Context(), hooks.RecordInput{
 				Command:    rawCommand,
 				CWD:        cwd,
 				ExitCode:   exitCode,
 				DurationMS: durationMS,
 				Timestamp:  ts,
+				ShellToken: shellToken,
 			})
 			if err != nil {
 				return err
 			}
+			if result.Warning != "" {
+				printWarn(cmd.ErrOrStderr(), "%s", result.Warning)
+			}
 
+			if debug {
+				switch {
+				case result.Recorded:
+					fmt.Fprintf(cmd.ErrOrStderr(), "recorded: %s (%s)\n", result.Step.Command, result.Step.Status)
+				case result.SkippedRule != "":
+					fmt.Fprintf(cmd.ErrOrStderr(), "skipped: %s (rule: %s)\n", result.SkippedReason, result.SkippedRule)
+				default:
+					fmt.Fprintf(cmd.ErrOrStderr(), "skipped: %s\n", result.SkippedReason)
+				}
+			}
 			if result.Reminder {
 				fmt.Fprintln(cmd.ErrOrStderr(), "[REC] Commandry recording is active.")
 			}
@@ -194,8 +213,10 @@ func newHookRecordCmd(s store.SessionStore, p *policy.Policy, stateStore hooks.S
 	cmd.Flags().StringVar(&rawCommand, "command", "", "Raw command line to record")
 	cmd.Flags().StringVar(&cwd, "cwd", "", "Working directory of the command")
 	cmd.Flags().IntVar(&exitCode, "exit-code", 0, "Command exit code")
-	cmd.Flags().Int64Var(&durationMS, "duration-ms", 0, "Command duration in milliseconds")
+	cmd.Flags().Int64Var(&durationMS, "duration-ms", -1, "Command duration in milliseconds (-1 when not measured)")
 	cmd.Flags().StringVar(&timestamp, "timestamp", "", "Command timestamp in RFC3339 format")
+	cmd.Flags().StringVar(&shellToken, "shell-token", "", "Token of the recording shell (defaults to $"+hooks.ShellTokenEnv+")")
+	cmd.Flags().BoolVar(&debug, "debug", false, "Explain on stderr whether the command was recorded or skipped")
 	_ = cmd.MarkFlagRequired("command")
 	return cmd
 }
@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	fishHookBeginMarker = "# >>> commandry hooks (fish) >>>"
+	fishHookEndMarker   = "# <<< commandry hooks (fish) <<<"
+)
+
+// fishConfPath returns the conf.d snippet fish sources on startup.
+func fishConfPath() (string, error) {
+	configDir := strings.TrimSpace(os.Getenv("XDG_CONFIG_HOME"))
+	if configDir == "" {
+		home, err := hooksHomeDir()
+		if err != nil || home == "" {
+			return "", errors.New("cannot resolve home directory for fish config")
+		}
+		configDir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configDir, "fish", "conf.d", "commandry.fish"), nil
+}
+
+func fishSingleQuote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return strings.ReplaceAll(value, "'", `\'`)
+}
+
+// fishHookBlock records from the fish_postexec event, which already carries
+// the exit status and $CMD_DURATION. The prompt is wrapped lazily on the first
+// fish_prompt event so prompts defined after conf.d (themes, config.fish) are
+// picked up too.
+func fishHookBlock(executablePath string) string {
+	exe := fishSingleQuote(executablePath)
+	return strings.Join([]string{
+		fishHookBeginMarker,
+		"# Identifies this terminal for `cmdry start --this-terminal`; nested shells keep it.",
+		"set -q COMMANDRY_SHELL_TOKEN; or set -gx COMMANDRY_SHELL_TOKEN \"$fish_pid-\"(random)(random)",
+		"set -g __commandry_hook_active 0",
+		"set -g __commandry_cwd \"\"",
+		"function __commandry_state_root",
+		"    if test -n \"$APPDATA\"",
+		"        echo \"$APPDATA/commandry\"",
+		"    else if test -n \"$XDG_CONFIG_HOME\"",
+		"        echo \"$XDG_CONFIG_HOME/commandry\"",
+		"    else if test (uname -s 2>/dev/null) = Darwin",
+		"        echo \"$HOME/Library/Application Support/commandry\"",
+		"    else",
+		"        echo \"$HOME/.config/commandry\"",
+		"    end",
+		"end",
+		"function __commandry_should_prefix",
+		"    set -l __it_root (__commandry_state_root)",
+		"    test -f \"$__it_root/hooks_state.json\"; or return 1",
+		"    test -f \"$__it_root/active_session.json\"; or return 1",
+		"    grep -qi '\"enabled\"[[:space:]]*:[[:space:]]*true' \"$__it_root/hooks_state.json\" 2>/dev/null",
+		"end",
+		"function __commandry_return",
+		"    return $argv[1]",
+		"end",
+		"function __commandry_wrap_prompt --on-event fish_prompt",
+		"    functions -q fish_prompt; or return",
+		"    functions fish_prompt | string match -q '*__commandry_orig_fish_prompt*'; and return",
+		"    functions -e __commandry_orig_fish_prompt",
+		"    functions -c fish_prompt __commandry_orig_fish_prompt",
+		"    function fish_prompt",
+		"        set -l __it_status $status",
+		"        if __commandry_should_prefix",
+		"            printf '[REC] '",
+		"        end",
+		"        __commandry_return $__it_status",
+		"        __commandry_orig_fish_prompt",
+		"    end",
+		"end",
+		"function __commandry_hookd_send",
+		"    set -l __it_sock (__commandry_state_root)/hookd.sock",
+		"    test -S \"$__it_sock\"; or return 1",
+		"    command -q socat; or return 1",
+		"    set -l __it_c (string replace -a -- '\\\\' '\\\\\\\\' \"$argv[1]\" | string join '\\n')",
+		"    set -l __it_d (string replace -a -- '\\\\' '\\\\\\\\' \"$argv[4]\" | string join '\\n')",
+		"    set -l __it_reply (printf 'command: %s\\nexit-code: %s\\nduration-ms: %s\\ncwd: %s\\nshell-token: %s\\n\\n' \"$__it_c\" $argv[2] $argv[3] \"$__it_d\" \"$COMMANDRY_SHELL_TOKEN\" | socat -t 2 - \"UNIX-CONNECT:$__it_sock\" 2>/dev/null)",
+		"    or return 1",
+		"    string match -q -r '^(ok|skipped)' -- \"$__it_reply\"",
+		"end",
+		"function __commandry_preexec --on-event fish_preexec",
+		"    set -g __commandry_cwd \"$PWD\"",
+		"end",
+		"function __commandry_postexec --on-event fish_postexec",
+		"    set -l __it_exit $status",
+		"    set -l __it_duration $CMD_DURATION",
+		"    test \"$__commandry_hook_active\" = 1; and return",
+		"    set -l __it_cmd (string trim -- \"$argv[1]\" | string collect)",
+		"    test -n \"$__it_cmd\"; or return",
+		"    switch $__it_cmd",
+		"        case 'cmdry*' 'cmdr*' 'it*' exit logout",
+		"            return",
+		"    end",
+		"    set -l __it_cwd \"$__commandry_cwd\"",
+		"    test -n \"$__it_cwd\"; or set __it_cwd \"$PWD\"",
+		"    set -g __commandry_hook_active 1",
+		"    if not __commandry_hookd_send \"$__it_cmd\" \"$__it_exit\" \"$__it_duration\" \"$__it_cwd\"",
+		fmt.Sprintf("        '%s' hook record --command \"$__it_cmd\" --exit-code \"$__it_exit\" --duration-ms \"$__it_duration\" --cwd \"$__it_cwd\" >/dev/null 2>&1", exe),
+		"    end",
+		"    set -g __commandry_hook_active 0",
+		"end",
+		fishHookEndMarker,
+	}, "\n")
+}
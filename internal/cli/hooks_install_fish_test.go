@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFishHookBlockUsesEventsAndAbsolutePath(t *testing.T) {
+	t.Parallel()
+
+	block := fishHookBlock("/opt/it's/cmdry")
+	if !strings.Contains(block, `'/opt/it\'s/cmdry' hook record`) {
+		t.Fatalf("expected quoted absolute path in fish block: %s", block)
+	}
+	for _, want := range []string{
+		"--on-event fish_preexec",
+		"--on-event fish_postexec",
+		"--duration-ms \"$__it_duration\"",
+		"set -l __it_exit $status",
+		"set -l __it_duration $CMD_DURATION",
+		"printf '[REC] '",
+		"__commandry_should_prefix",
+		"/hookd.sock",
+		"set -gx COMMANDRY_SHELL_TOKEN",
+		"if not __commandry_hookd_send",
+	} {
+		if !strings.Contains(block, want) {
+			t.Fatalf("expected %q in fish block: %s", want, block)
+		}
+	}
+}
+
+func TestHooksInstallFishLifecycle(t *testing.T) {
+	setupCLIEnv(t)
+	confPath, err := fishConfPath()
+	if err != nil {
+		t.Fatalf("fishConfPath failed: %v", err)
+	}
+	if want := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "fish", "conf.d", "commandry.fish"); confPath != want {
+		t.Fatalf("fish conf path = %q, want %q", confPath, want)
+	}
+
+	mustExecuteCLI(t, "hooks", "install", "fish")
+	content, err := os.ReadFile(confPath)
+	if err != nil {
+		t.Fatalf("read fish hook file: %v", err)
+	}
+	if !strings.Contains(string(content), fishHookBeginMarker) || !strings.Contains(string(content), fishHookEndMarker) {
+		t.Fatalf("expected fish markers in %s: %s", confPath, content)
+	}
+
+	out := mustExecuteCLI(t, "hooks", "status")
+	if !strings.Contains(out, "Fish hook installed: enabled") {
+		t.Fatalf("expected fish hook in status output: %s", out)
+	}
+
+	mustExecuteCLI(t, "hooks", "uninstall", "fish")
+	if _, err := os.Stat(confPath); !os.IsNotExist(err) {
+		t.Fatalf("expected fish hook file to be removed, stat err=%v", err)
+	}
+}
@@ -23,70 +23,6 @@ const (
 	legacyZshHookEndMarker    = "# <<< infratrack hooks (zsh) <<<"
 )
 
-func newHooksInstallBashCmd() *cobra.Command {
-	return &cobra.Command{
-		Use:   "bash",
-		Short: "Install bash profile hook",
-		RunE: func(cmd *cobra.Command, _ []string) error {
-			path, err := bashProfilePath()
-			if err != nil {
-				return err
-			}
-			exe, err := os.Executable()
-			if err != nil {
-				return fmt.Errorf("resolve executable path: %w", err)
-			}
-			return installPosixHook(cmd, path, bashHookBeginMarker, bashHookEndMarker, bashHookBlock(exe))
-		},
-	}
-}
-
-func newHooksInstallZshCmd() *cobra.Command {
-	return &cobra.Command{
-		Use:   "zsh",
-		Short: "Install zsh profile hook",
-		RunE: func(cmd *cobra.Command, _ []string) error {
-			path, err := zshProfilePath()
-			if err != nil {
-				return err
-			}
-			exe, err := os.Executable()
-			if err != nil {
-				return fmt.Errorf("resolve executable path: %w", err)
-			}
-			return installPosixHook(cmd, path, zshHookBeginMarker, zshHookEndMarker, zshHookBlock(exe))
-		},
-	}
-}
-
-func newHooksUninstallBashCmd() *cobra.Command {
-	return &cobra.Command{
-		Use:   "bash",
-		Short: "Remove bash profile hook",
-		RunE: func(cmd *cobra.Command, _ []string) error {
-			path, err := bashProfilePath()
-			if err != nil {
-				return err
-			}
-			return uninstallPosixHook(cmd, path, bashHookBeginMarker, bashHookEndMarker)
-		},
-	}
-}
-
-func newHooksUninstallZshCmd() *cobra.Command {
-	return &cobra.Command{
-		Use:   "zsh",
-		Short: "Remove zsh profile hook",
-		RunE: func(cmd *cobra.Command, _ []string) error {
-			path, err := zshProfilePath()
-			if err != nil {
-				return err
-			}
-			return uninstallPosixHook(cmd, path, zshHookBeginMarker, zshHookEndMarker)
-		},
-	}
-}
-
 func installPosixHook(cmd *cobra.Command, path, begin, end, block string) error {
 	current, err := readTextFile(path)
 	if err != nil && !errors.Is(err, os.ErrNotExist) {
@@ -108,41 +44,6 @@ func installPosixHook(cmd *cobra.Command, path, begin, end, block string) error
 	return nil
 }
 
-func uninstallPosixHook(cmd *cobra.Command, path, begin, end string) error {
-	current, err := readTextFile(path)
-	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			fmt.Fprintln(cmd.OutOrStdout(), "No hook block found.")
-			return nil
-		}
-		return fmt.Errorf("read profile: %w", err)
-	}
-	updated, changed, err := replaceBetweenMarkers(current, begin, end, "")
-	if err != nil {
-		return err
-	}
-	if !changed {
-		legacyBegin, legacyEnd := legacyPosixMarkers(begin, end)
-		if legacyBegin != "" {
-			updatedLegacy, changedLegacy, legacyErr := replaceBetweenMarkers(current, legacyBegin, legacyEnd, "")
-			if legacyErr != nil {
-				return legacyErr
-			}
-			updated = updatedLegacy
-			changed = changedLegacy
-		}
-	}
-	if !changed {
-		fmt.Fprintln(cmd.OutOrStdout(), "No hook block found.")
-		return nil
-	}
-	if err := writeTextFileAtomic(path, updated); err != nil {
-		return fmt.Errorf("write profile: %w", err)
-	}
-	printOK(cmd.OutOrStdout(), "Removed hooks from %s", path)
-	return nil
-}
-
 func upsertHookBlock(content, begin, end, block string) (string, bool, error) {
 	legacyBegin, legacyEnd := legacyPosixMarkers(begin, end)
 	if legacyBegin != "" && strings.Contains(content, legacyBegin) && strings.Contains(content, legacyEnd) &&
@@ -174,7 +75,11 @@ func bashProfilePath() (string, error) {
 	return filepath.Join(home, ".bashrc"), nil
 }
 
+// zshProfilePath follows zsh itself: .zshrc lives in $ZDOTDIR when set.
 func zshProfilePath() (string, error) {
+	if zdotdir := strings.TrimSpace(os.Getenv("ZDOTDIR")); zdotdir != "" {
+		return filepath.Join(zdotdir, ".zshrc"), nil
+	}
 	home, err := hooksHomeDir()
 	if err != nil || home == "" {
 		return "", errors.New("cannot resolve home directory for zsh profile")
@@ -182,63 +87,41 @@ func zshProfilePath() (string, error) {
 	return filepath.Join(home, ".zshrc"), nil
 }
 
-func bashInstallStatus() (bool, string) {
-	path, err := bashProfilePath()
-	if err != nil {
-		return false, ""
-	}
-	state := "not found"
-	content, readErr := readTextFile(path)
-	if readErr == nil {
-		if (strings.Contains(content, bashHookBeginMarker) && strings.Contains(content, bashHookEndMarker)) ||
-			(strings.Contains(content, legacyBashHookBeginMarker) && strings.Contains(content, legacyBashHookEndMarker)) {
-			state = "installed"
-		} else {
-			state = "present (no commandry block)"
-		}
-	}
-	return state == "installed", fmt.Sprintf("- %s: %s", path, state)
-}
-
-func zshInstallStatus() (bool, string) {
-	path, err := zshProfilePath()
-	if err != nil {
-		return false, ""
-	}
-	state := "not found"
-	content, readErr := readTextFile(path)
-	if readErr == nil {
-		if (strings.Contains(content, zshHookBeginMarker) && strings.Contains(content, zshHookEndMarker)) ||
-			(strings.Contains(content, legacyZshHookBeginMarker) && strings.Contains(content, legacyZshHookEndMarker)) {
-			state = "installed"
-		} else {
-			state = "present (no commandry block)"
-		}
-	}
-	return state == "installed", fmt.Sprintf("- %s: %s", path, state)
-}
-
 func shellSingleQuote(value string) string {
 	return strings.ReplaceAll(value, "'", "'\"'\"'")
 }
 
+// bashHookBlock captures the command line and a start time in the DEBUG trap
+// and records it from PROMPT_COMMAND once the command has finished, so the
+// step gets the real exit code and elapsed time. The trap is armed by the last
+// PROMPT_COMMAND entry so only the first command of each prompt line counts.
 func bashHookBlock(executablePath string) string {
 	exe := shellSingleQuote(executablePath)
 	return strings.Join([]string{
 		bashHookBeginMarker,
+		"# Identifies this terminal for `cmdry start --this-terminal`; nested shells keep it.",
+		"[ -n \"${COMMANDRY_SHELL_TOKEN:-}\" ] || export COMMANDRY_SHELL_TOKEN=\"$$-$RANDOM$RANDOM\"",
 		"__commandry_hook_active=0",
 		"__commandry_hook_ready=0",
-		"__commandry_should_prefix() {",
-		"  local __it_root",
+		"__commandry_armed=0",
+		"__commandry_cmd=\"\"",
+		"__commandry_start_ms=0",
+		"__commandry_histno=\"\"",
+		"__commandry_hist_seeded=0",
+		"__commandry_state_root() {",
 		"  if [ -n \"${APPDATA:-}\" ]; then",
-		"    __it_root=\"$APPDATA/commandry\"",
+		"    __commandry_root=\"$APPDATA/commandry\"",
 		"  elif [ -n \"${XDG_CONFIG_HOME:-}\" ]; then",
-		"    __it_root=\"$XDG_CONFIG_HOME/commandry\"",
+		"    __commandry_root=\"$XDG_CONFIG_HOME/commandry\"",
 		"  elif [ \"$(uname -s 2>/dev/null)\" = \"Darwin\" ]; then",
-		"    __it_root=\"$HOME/Library/Application Support/commandry\"",
+		"    __commandry_root=\"$HOME/Library/Application Support/commandry\"",
 		"  else",
-		"    __it_root=\"$HOME/.config/commandry\"",
+		"    __commandry_root=\"$HOME/.config/commandry\"",
 		"  fi",
+		"}",
+		"__commandry_should_prefix() {",
+		"  __commandry_state_root",
+		"  local __it_root=\"$__commandry_root\"",
 		"  local __it_state=\"$__it_root/hooks_state.json\"",
 		"  local __it_active=\"$__it_root/active_session.json\"",
 		"  [ -f \"$__it_state\" ] || return 1",
@@ -258,8 +141,39 @@ func bashHookBlock(executablePath string) string {
 		"    esac",
 		"  fi",
 		"}",
+		"__commandry_now_ms() {",
+		"  if [ -n \"${EPOCHREALTIME:-}\" ]; then",
+		"    local __it_us=\"${EPOCHREALTIME/[.,]/}\"",
+		"    __commandry_now=$(( 10#$__it_us / 1000 ))",
+		"  else",
+		"    __commandry_now=$(( SECONDS * 1000 ))",
+		"  fi",
+		"}",
+		"__commandry_last_history() {",
+		"  local __it_re='^[[:space:]]*([0-9]+)[*]?[[:space:]]+(.*)$'",
+		"  local __it_line",
+		"  __it_line=\"$(HISTTIMEFORMAT= builtin history 1 2>/dev/null)\"",
+		"  __commandry_hist_cmd=\"\"",
+		"  [[ \"$__it_line\" =~ $__it_re ]] || return 1",
+		"  [ \"${BASH_REMATCH[1]}\" != \"$__commandry_histno\" ] || return 1",
+		"  __commandry_histno=\"${BASH_REMATCH[1]}\"",
+		"  __commandry_hist_cmd=\"${BASH_REMATCH[2]}\"",
+		"}",
+		"__commandry_hookd_send() {",
+		"  __commandry_state_root",
+		"  local __it_sock=\"$__commandry_root/hookd.sock\"",
+		"  [ -S \"$__it_sock\" ] || return 1",
+		"  command -v socat >/dev/null 2>&1 || return 1",
+		"  local __it_c=\"${1//\\\\/\\\\\\\\}\" __it_d=\"${PWD//\\\\/\\\\\\\\}\" __it_reply",
+		"  __it_c=\"${__it_c//$'\\n'/\\\\n}\"",
+		"  __it_d=\"${__it_d//$'\\n'/\\\\n}\"",
+		"  __it_reply=\"$(printf 'command: %s\\nexit-code: %s\\nduration-ms: %s\\ncwd: %s\\nshell-token: %s\\n\\n' \"$__it_c\" \"$2\" \"$3\" \"$__it_d\" \"${COMMANDRY_SHELL_TOKEN:-}\" | socat -t 2 - \"UNIX-CONNECT:$__it_sock\" 2>/dev/null)\" || return 1",
+		"  case \"$__it_reply\" in",
+		"    ok*|skipped*) return 0 ;;",
+		"  esac",
+		"  return 1",
+		"}",
 		"__commandry_hook_record() {",
-		"  local __it_exit=$?",
 		"  if [ \"${__commandry_hook_active}\" = \"1\" ]; then return; fi",
 		"  local __it_cmd=\"$1\"",
 		"  [ -z \"$__it_cmd\" ] && return",
@@ -267,26 +181,58 @@ func bashHookBlock(executablePath string) string {
 		"    cmdry*|cmdr*|it*) return ;;",
 		"  esac",
 		"  __commandry_hook_active=1",
-		fmt.Sprintf("  '%s' hook record --command \"$__it_cmd\" --exit-code \"$__it_exit\" --duration-ms 0 --cwd \"$PWD\" >/dev/null 2>&1 || true", exe),
+		"  if ! __commandry_hookd_send \"$__it_cmd\" \"$2\" \"$3\"; then",
+		fmt.Sprintf("    '%s' hook record --command \"$__it_cmd\" --exit-code \"$2\" --duration-ms \"$3\" --cwd \"$PWD\" >/dev/null 2>&1 || true", exe),
+		"  fi",
 		"  __commandry_hook_active=0",
-		"  __commandry_apply_ps1_prefix",
 		"}",
 		"__commandry_preexec() {",
 		"  [ \"${__commandry_hook_ready}\" = \"1\" ] || return",
+		"  [ \"${__commandry_armed}\" = \"1\" ] || return",
+		"  [ -z \"${COMP_LINE:-}\" ] || return",
 		"  local __it_cmd=\"$BASH_COMMAND\"",
 		"  case \"$__it_cmd\" in",
 		"    __commandry_*|history*|trap*|PROMPT_COMMAND*|\"[ \"*|\"exit\"|\"logout\"|\"\") return ;;",
 		"  esac",
-		"  __commandry_hook_record \"$__it_cmd\"",
+		"  __commandry_armed=0",
+		"  # Prefer the history entry: it holds the whole line, pipelines included.",
+		"  if __commandry_last_history; then",
+		"    __it_cmd=\"$__commandry_hist_cmd\"",
+		"  fi",
+		"  __commandry_cmd=\"$__it_cmd\"",
+		"  __commandry_now_ms",
+		"  __commandry_start_ms=$__commandry_now",
+		"}",
+		"__commandry_precmd() {",
+		"  local __it_exit=$?",
+		"  __commandry_armed=0",
+		"  if [ -n \"$__commandry_cmd\" ]; then",
+		"    local __it_cmd=\"$__commandry_cmd\"",
+		"    __commandry_cmd=\"\"",
+		"    __commandry_now_ms",
+		"    local __it_elapsed=$(( __commandry_now - __commandry_start_ms ))",
+		"    [ \"$__it_elapsed\" -lt 0 ] && __it_elapsed=0",
+		"    __commandry_hook_record \"$__it_cmd\" \"$__it_exit\" \"$__it_elapsed\"",
+		"  fi",
+		"  __commandry_apply_ps1_prefix",
+		"  return $__it_exit",
+		"}",
+		"__commandry_arm() {",
+		"  local __it_exit=$?",
+		"  if [ \"$__commandry_hist_seeded\" != \"1\" ]; then",
+		"    __commandry_last_history",
+		"    __commandry_hist_seeded=1",
+		"  fi",
+		"  __commandry_armed=1",
+		"  return $__it_exit",
 		"}",
 		"trap '__commandry_preexec' DEBUG",
-		"if [ -n \"${PROMPT_COMMAND:-}\" ]; then",
-		"  case \";$PROMPT_COMMAND;\" in",
-		"    *\";__commandry_apply_ps1_prefix;\"*) ;;",
-		"    *) PROMPT_COMMAND=\"__commandry_apply_ps1_prefix; $PROMPT_COMMAND\" ;;",
-		"  esac",
-		"else",
-		"  PROMPT_COMMAND=\"__commandry_apply_ps1_prefix\"",
+		"if [[ \"$(declare -p PROMPT_COMMAND 2>/dev/null)\" == \"declare -a\"* ]]; then",
+		"  if [[ \" ${PROMPT_COMMAND[*]} \" != *\" __commandry_precmd \"* ]]; then",
+		"    PROMPT_COMMAND=(__commandry_precmd \"${PROMPT_COMMAND[@]}\" __commandry_arm)",
+		"  fi",
+		"elif [[ \"${PROMPT_COMMAND:-}\" != *__commandry_precmd* ]]; then",
+		"  PROMPT_COMMAND=\"__commandry_precmd${PROMPT_COMMAND:+$'\\n'$PROMPT_COMMAND}\"$'\\n'\"__commandry_arm\"",
 		"fi",
 		"__commandry_hook_ready=1",
 		"__commandry_apply_ps1_prefix",
@@ -294,24 +240,34 @@ func bashHookBlock(executablePath string) string {
 	}, "\n")
 }
 
+// zshHookBlock mirrors bashHookBlock with native preexec/precmd hooks.
 func zshHookBlock(executablePath string) string {
 	exe := shellSingleQuote(executablePath)
 	return strings.Join([]string{
 		zshHookBeginMarker,
+		"# Identifies this terminal for `cmdry start --this-terminal`; nested shells keep it.",
+		"[ -n \"${COMMANDRY_SHELL_TOKEN:-}\" ] || export COMMANDRY_SHELL_TOKEN=\"$$-$RANDOM$RANDOM\"",
 		"autoload -Uz add-zsh-hook",
+		"zmodload zsh/datetime 2>/dev/null",
 		"typeset -g __commandry_hook_active=0",
 		"typeset -g __commandry_hook_ready=0",
-		"__commandry_should_prefix() {",
-		"  local __it_root",
+		"typeset -g __commandry_cmd=\"\"",
+		"typeset -gi __commandry_start_ms=0",
+		"typeset -gi __commandry_now=0",
+		"__commandry_state_root() {",
 		"  if [[ -n \"${APPDATA:-}\" ]]; then",
-		"    __it_root=\"$APPDATA/commandry\"",
+		"    __commandry_root=\"$APPDATA/commandry\"",
 		"  elif [[ -n \"${XDG_CONFIG_HOME:-}\" ]]; then",
-		"    __it_root=\"$XDG_CONFIG_HOME/commandry\"",
+		"    __commandry_root=\"$XDG_CONFIG_HOME/commandry\"",
 		"  elif [[ \"$(uname -s 2>/dev/null)\" == \"Darwin\" ]]; then",
-		"    __it_root=\"$HOME/Library/Application Support/commandry\"",
+		"    __commandry_root=\"$HOME/Library/Application Support/commandry\"",
 		"  else",
-		"    __it_root=\"$HOME/.config/commandry\"",
+		"    __commandry_root=\"$HOME/.config/commandry\"",
 		"  fi",
+		"}",
+		"__commandry_should_prefix() {",
+		"  __commandry_state_root",
+		"  local __it_root=\"$__commandry_root\"",
 		"  local __it_state=\"$__it_root/hooks_state.json\"",
 		"  local __it_active=\"$__it_root/active_session.json\"",
 		"  [[ -f \"$__it_state\" ]] || return 1",
@@ -331,8 +287,29 @@ func zshHookBlock(executablePath string) string {
 		"    esac",
 		"  fi",
 		"}",
+		"__commandry_now_ms() {",
+		"  if (( ${+epochtime} )); then",
+		"    __commandry_now=$(( epochtime[1] * 1000 + epochtime[2] / 1000000 ))",
+		"  else",
+		"    __commandry_now=$(( SECONDS * 1000 ))",
+		"  fi",
+		"}",
+		"__commandry_hookd_send() {",
+		"  __commandry_state_root",
+		"  local __it_sock=\"$__commandry_root/hookd.sock\"",
+		"  [[ -S \"$__it_sock\" ]] || return 1",
+		"  zmodload zsh/net/socket 2>/dev/null || return 1",
+		"  zsocket \"$__it_sock\" 2>/dev/null || return 1",
+		"  local __it_fd=$REPLY __it_reply=\"\"",
+		"  local __it_c=\"${1//\\\\/\\\\\\\\}\" __it_d=\"${PWD//\\\\/\\\\\\\\}\"",
+		"  __it_c=\"${__it_c//$'\\n'/\\\\n}\"",
+		"  __it_d=\"${__it_d//$'\\n'/\\\\n}\"",
+		"  printf 'command: %s\\nexit-code: %s\\nduration-ms: %s\\ncwd: %s\\nshell-token: %s\\n\\n' \"$__it_c\" \"$2\" \"$3\" \"$__it_d\" \"${COMMANDRY_SHELL_TOKEN:-}\" >&$__it_fd 2>/dev/null",
+		"  read -r -t 2 -u $__it_fd __it_reply 2>/dev/null",
+		"  exec {__it_fd}>&-",
+		"  [[ \"$__it_reply\" == ok* || \"$__it_reply\" == skipped* ]]",
+		"}",
 		"__commandry_hook_record() {",
-		"  local __it_exit=$?",
 		"  if [[ \"$__commandry_hook_active\" == \"1\" ]]; then return; fi",
 		"  local __it_cmd=\"$1\"",
 		"  [[ -z \"$__it_cmd\" ]] && return",
@@ -340,7 +317,9 @@ func zshHookBlock(executablePath string) string {
 		"    cmdry*|cmdr*|it*) return ;;",
 		"  esac",
 		"  __commandry_hook_active=1",
-		fmt.Sprintf("  '%s' hook record --command \"$__it_cmd\" --exit-code \"$__it_exit\" --duration-ms 0 --cwd \"$PWD\" >/dev/null 2>&1 || true", exe),
+		"  if ! __commandry_hookd_send \"$__it_cmd\" \"$2\" \"$3\"; then",
+		fmt.Sprintf("    '%s' hook record --command \"$__it_cmd\" --exit-code \"$2\" --duration-ms \"$3\" --cwd \"$PWD\" >/dev/null 2>&1 || true", exe),
+		"  fi",
 		"  __commandry_hook_active=0",
 		"}",
 		"__commandry_preexec() {",
@@ -349,10 +328,22 @@ func zshHookBlock(executablePath string) string {
 		"  case \"$__it_cmd\" in",
 		"    __commandry_*|\"[ \"*|\"exit\"|\"logout\"|\"\") return ;;",
 		"  esac",
-		"  __commandry_hook_record \"$__it_cmd\"",
+		"  __commandry_cmd=\"$__it_cmd\"",
+		"  __commandry_now_ms",
+		"  __commandry_start_ms=$__commandry_now",
 		"}",
 		"__commandry_precmd() {",
+		"  local __it_exit=$?",
+		"  if [[ -n \"$__commandry_cmd\" ]]; then",
+		"    local __it_cmd=\"$__commandry_cmd\"",
+		"    __commandry_cmd=\"\"",
+		"    __commandry_now_ms",
+		"    local __it_elapsed=$(( __commandry_now - __commandry_start_ms ))",
+		"    (( __it_elapsed < 0 )) && __it_elapsed=0",
+		"    __commandry_hook_record \"$__it_cmd\" \"$__it_exit\" \"$__it_elapsed\"",
+		"  fi",
 		"  __commandry_apply_prompt_prefix",
+		"  return $__it_exit",
 		"}",
 		"add-zsh-hook preexec __commandry_preexec",
 		"add-zsh-hook precmd __commandry_precmd",
@@ -1,8 +1,18 @@
 package cli
 
 import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
 	"testing"
+
+	"github.com/fixi2/Commandry/internal/hooks"
 )
 
 func TestUpsertBashHookBlock(t *testing.T) {
@@ -92,6 +102,12 @@ func TestHookBlocksUseAbsolutePath(t *testing.T) {
 	if !strings.Contains(zshBlock, "__commandry_should_prefix") {
 		t.Fatalf("expected conditional REC helper in zsh block: %s", zshBlock)
 	}
+	if !strings.Contains(bashBlock, "UNIX-CONNECT:") || !strings.Contains(bashBlock, "/hookd.sock") {
+		t.Fatalf("expected bash block to try the hookd socket: %s", bashBlock)
+	}
+	if !strings.Contains(zshBlock, "zsocket") || !strings.Contains(zshBlock, "/hookd.sock") {
+		t.Fatalf("expected zsh block to try the hookd socket: %s", zshBlock)
+	}
 	if !strings.Contains(bashBlock, "trap '__commandry_preexec' DEBUG") {
 		t.Fatalf("expected bash DEBUG trap preexec hook: %s", bashBlock)
 	}
@@ -162,3 +178,228 @@ func TestUpsertHookBlockReplacesLegacyMarkers(t *testing.T) {
 		t.Fatalf("new markers missing: %s", updated)
 	}
 }
+
+func TestPosixHookBlocksRecordAfterCompletion(t *testing.T) {
+	t.Parallel()
+
+	bashBlock := bashHookBlock("/usr/local/bin/cmdry")
+	zshBlock := zshHookBlock("/usr/local/bin/cmdry")
+	for name, block := range map[string]string{"bash": bashBlock, "zsh": zshBlock} {
+		if strings.Contains(block, "--duration-ms 0") {
+			t.Fatalf("expected %s block to pass measured duration: %s", name, block)
+		}
+		if !strings.Contains(block, "__commandry_precmd() {\n  local __it_exit=$?") {
+			t.Fatalf("expected %s block to read the exit code in precmd: %s", name, block)
+		}
+	}
+	if !strings.Contains(bashBlock, "EPOCHREALTIME") || !strings.Contains(zshBlock, "epochtime") {
+		t.Fatal("expected hook blocks to measure elapsed milliseconds")
+	}
+	if !strings.Contains(zshBlock, "add-zsh-hook precmd __commandry_precmd") {
+		t.Fatalf("expected zsh precmd hook: %s", zshBlock)
+	}
+}
+
+func TestBashHookBlockRecordsExitCodeAndDuration(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("bash hook integration test requires a POSIX shell")
+	}
+	bashPath, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not installed")
+	}
+	t.Parallel()
+
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "record.log")
+	fake := filepath.Join(dir, "fake-cmdry")
+	script := "#!/bin/sh\nprintf '%s|' \"$@\" >> '" + logPath + "'\necho >> '" + logPath + "'\n"
+	if err := os.WriteFile(fake, []byte(script), 0o755); err != nil {
+		t.Fatalf("write fake recorder: %v", err)
+	}
+	rc := filepath.Join(dir, "bashrc")
+	if err := os.WriteFile(rc, []byte(bashHookBlock(fake)+"\n"), 0o644); err != nil {
+		t.Fatalf("write rc: %v", err)
+	}
+
+	cmd := exec.Command(bashPath, "--rcfile", rc, "-i")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "HOME="+dir, "HISTFILE="+filepath.Join(dir, "history"))
+	cmd.Stdin = strings.NewReader("sleep 0.2\necho a | grep -q b\nexit 0\n")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("run bash: %v\n%s", err, out)
+	}
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("read record log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 recorded commands, got %q", lines)
+	}
+	first := strings.Split(lines[0], "|")
+	if first[3] != "sleep 0.2" || first[5] != "0" {
+		t.Fatalf("unexpected first record: %q", lines[0])
+	}
+	if ms, err := strconv.Atoi(first[7]); err != nil || ms < 150 {
+		t.Fatalf("expected measured duration for sleep, got %q", first[7])
+	}
+	if !strings.HasPrefix(lines[1], "hook|record|--command|echo a | grep -q b|--exit-code|1|") {
+		t.Fatalf("expected whole pipeline with its exit code, got %q", lines[1])
+	}
+}
+
+func TestBashHookBlockSendsToHookdSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("bash hook integration test requires a POSIX shell")
+	}
+	bashPath, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not installed")
+	}
+	t.Parallel()
+
+	dir := t.TempDir()
+	// Short socket path: sun_path is limited to ~104 bytes on macOS.
+	configDir, err := os.MkdirTemp("", "cmdry")
+	if err != nil {
+		t.Fatalf("mkdir temp: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(configDir) })
+	if err := os.MkdirAll(filepath.Join(configDir, "commandry"), 0o700); err != nil {
+		t.Fatalf("mkdir root: %v", err)
+	}
+	ln, err := net.Listen("unix", filepath.Join(configDir, "commandry", "hookd.sock"))
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	// A fake socat stands in for the client; the listener only has to exist.
+	binDir := filepath.Join(dir, "bin")
+	if err := os.MkdirAll(binDir, 0o755); err != nil {
+		t.Fatalf("mkdir bin: %v", err)
+	}
+	sentPath := filepath.Join(dir, "sent.log")
+	socat := "#!/bin/sh\ncat >> '" + sentPath + "'\necho ok\n"
+	if err := os.WriteFile(filepath.Join(binDir, "socat"), []byte(socat), 0o755); err != nil {
+		t.Fatalf("write fake socat: %v", err)
+	}
+	logPath := filepath.Join(dir, "record.log")
+	fake := filepath.Join(dir, "fake-cmdry")
+	if err := os.WriteFile(fake, []byte("#!/bin/sh\necho \"$@\" >> '"+logPath+"'\n"), 0o755); err != nil {
+		t.Fatalf("write fake recorder: %v", err)
+	}
+	rc := filepath.Join(dir, "bashrc")
+	if err := os.WriteFile(rc, []byte(bashHookBlock(fake)+"\n"), 0o644); err != nil {
+		t.Fatalf("write rc: %v", err)
+	}
+
+	cmd := exec.Command(bashPath, "--rcfile", rc, "-i")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"HOME="+dir,
+		"HISTFILE="+filepath.Join(dir, "history"),
+		"COMMANDRY_SHELL_TOKEN=",
+		"APPDATA=",
+		"XDG_CONFIG_HOME="+configDir,
+		"PATH="+binDir+string(os.PathListSeparator)+os.Getenv("PATH"),
+	)
+	cmd.Stdin = strings.NewReader("printf 'a\\\\b\\n' | grep -q zz\nexit 0\n")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("run bash: %v\n%s", err, out)
+	}
+
+	if _, err := os.Stat(logPath); err == nil {
+		t.Fatal("expected no one-shot fallback while hookd answers")
+	}
+	sent, err := os.ReadFile(sentPath)
+	if err != nil {
+		t.Fatalf("read sent log: %v", err)
+	}
+	input, err := hooks.DecodeRecordRequest(bufio.NewReader(strings.NewReader(string(sent))))
+	if err != nil {
+		t.Fatalf("decode sent request %q: %v", sent, err)
+	}
+	if input.Command != `printf 'a\\b\n' | grep -q zz` || input.ExitCode != 1 || input.CWD != dir || input.ShellToken == "" {
+		t.Fatalf("unexpected request: %#v", input)
+	}
+}
+
+func TestHooksInstallCustomProfileLifecycle(t *testing.T) {
+	configRoot := setupCLIEnv(t)
+	home := os.Getenv("HOME")
+	custom := filepath.Join(home, ".bash_profile")
+
+	mustExecuteCLI(t, "hooks", "install", "bash", "--profile", "~/.bash_profile")
+	content, err := os.ReadFile(custom)
+	if err != nil {
+		t.Fatalf("read custom profile: %v", err)
+	}
+	if !strings.Contains(string(content), bashHookBeginMarker) {
+		t.Fatalf("expected bash block in %s: %s", custom, content)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".bashrc")); !os.IsNotExist(err) {
+		t.Fatalf("expected default profile untouched, stat err=%v", err)
+	}
+
+	state, err := hooks.NewFileStateStore(configRoot).Load(context.Background())
+	if err != nil {
+		t.Fatalf("load hooks state: %v", err)
+	}
+	if got := state.Profiles["bash"]; len(got) != 1 || got[0] != custom {
+		t.Fatalf("expected recorded custom profile, got %v", state.Profiles)
+	}
+
+	out := mustExecuteCLI(t, "hooks", "status")
+	if !strings.Contains(out, "Bash hook installed: enabled") || !strings.Contains(out, "- "+custom+": installed") {
+		t.Fatalf("expected custom profile in status: %s", out)
+	}
+
+	out = mustExecuteCLI(t, "hooks", "uninstall", "bash")
+	if !strings.Contains(out, "Removed hooks from "+custom) {
+		t.Fatalf("expected uninstall to find the custom profile: %s", out)
+	}
+	content, err = os.ReadFile(custom)
+	if err != nil {
+		t.Fatalf("read custom profile: %v", err)
+	}
+	if strings.Contains(string(content), bashHookBeginMarker) {
+		t.Fatalf("expected bash block removed from %s", custom)
+	}
+	state, err = hooks.NewFileStateStore(configRoot).Load(context.Background())
+	if err != nil {
+		t.Fatalf("load hooks state: %v", err)
+	}
+	if len(state.Profiles) != 0 {
+		t.Fatalf("expected profiles forgotten after uninstall, got %v", state.Profiles)
+	}
+}
+
+func TestHooksInstallPrintAndZdotdir(t *testing.T) {
+	setupCLIEnv(t)
+	home := os.Getenv("HOME")
+
+	out := mustExecuteCLI(t, "hooks", "install", "zsh", "--print")
+	if !strings.HasPrefix(out, zshHookBeginMarker) || !strings.Contains(out, zshHookEndMarker) {
+		t.Fatalf("expected zsh block on stdout: %s", out)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".zshrc")); !os.IsNotExist(err) {
+		t.Fatalf("--print must not write a profile, stat err=%v", err)
+	}
+	if _, err := executeCLI(t, "hooks", "install", "zsh", "--print", "--profile", "x"); err == nil {
+		t.Fatal("expected --print with --profile to fail")
+	}
+
+	zdotdir := filepath.Join(home, "zsh")
+	t.Setenv("ZDOTDIR", zdotdir)
+	path, err := zshProfilePath()
+	if err != nil || path != filepath.Join(zdotdir, ".zshrc") {
+		t.Fatalf("zshProfilePath() = %q, %v; want file in ZDOTDIR", path, err)
+	}
+	mustExecuteCLI(t, "hooks", "install", "zsh")
+	if _, err := os.Stat(filepath.Join(zdotdir, ".zshrc")); err != nil {
+		t.Fatalf("expected .zshrc in ZDOTDIR: %v", err)
+	}
+}
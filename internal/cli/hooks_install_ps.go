@@ -10,6 +10,7 @@ import (
 	"runtime"
 	"strings"
 
+	"github.com/fixi2/Commandry/internal/hooks"
 	"github.com/fixi2/Commandry/internal/textblock"
 	"github.com/spf13/cobra"
 )
@@ -22,21 +23,31 @@ const (
 	legacyPSHookEndMarker   = "# <<< infratrack hooks <<<"
 )
 
-func newHooksInstallCmd() *cobra.Command {
+func newHooksInstallCmd(stateStore hooks.StateStore) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "install",
 		Short: "Install shell hooks",
 	}
-	cmd.AddCommand(newHooksInstallPowerShellCmd(), newHooksInstallBashCmd(), newHooksInstallZshCmd())
+	cmd.AddCommand(
+		newHooksInstallPowerShellCmd(),
+		newProfileHookInstallCmd(bashProfileHook, stateStore),
+		newProfileHookInstallCmd(zshProfileHook, stateStore),
+		newProfileHookInstallCmd(fishProfileHook, stateStore),
+	)
 	return cmd
 }
 
-func newHooksUninstallCmd() *cobra.Command {
+func newHooksUninstallCmd(stateStore hooks.StateStore) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "uninstall",
 		Short: "Uninstall shell hooks",
 	}
-	cmd.AddCommand(newHooksUninstallPowerShellCmd(), newHooksUninstallBashCmd(), newHooksUninstallZshCmd())
+	cmd.AddCommand(
+		newHooksUninstallPowerShellCmd(),
+		newProfileHookUninstallCmd(bashProfileHook, stateStore),
+		newProfileHookUninstallCmd(zshProfileHook, stateStore),
+		newProfileHookUninstallCmd(fishProfileHook, stateStore),
+	)
 	return cmd
 }
 
@@ -47,9 +58,6 @@ func newHooksInstallPowerShellCmd() *cobra.Command {
 		Use:   "powershell",
 		Short: "Install PowerShell profile hook",
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			if runtime.GOOS != "windows" {
-				return errors.New("powershell hooks install is supported on Windows in this stage")
-			}
 			candidates := powerShellProfileCandidates()
 			if len(candidates) == 0 {
 				return errors.New("cannot resolve PowerShell profile path")
@@ -100,10 +108,6 @@ func newHooksUninstallPowerShellCmd() *cobra.Command {
 		Use:   "powershell",
 		Short: "Remove PowerShell profile hook",
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			if runtime.GOOS != "windows" {
-				return errors.New("powershell hooks uninstall is supported on Windows in this stage")
-			}
-
 			foundAny := false
 			removedAny := false
 			for _, path := range powerShellProfileCandidates() {
@@ -182,10 +186,24 @@ func powerShellProfileCandidates() []string {
 	if err != nil || home == "" {
 		return nil
 	}
-	return []string{
-		filepath.Join(home, "Documents", "PowerShell", "Microsoft.PowerShell_profile.ps1"),
-		filepath.Join(home, "Documents", "WindowsPowerShell", "Microsoft.PowerShell_profile.ps1"),
+	return powerShellProfilePaths(runtime.GOOS, home, strings.TrimSpace(os.Getenv("XDG_CONFIG_HOME")))
+}
+
+// powerShellProfilePaths lists the CurrentUserCurrentHost profiles: pwsh and
+// Windows PowerShell under Documents on Windows, and the XDG config location
+// pwsh uses on Linux and macOS.
+func powerShellProfilePaths(goos, home, xdgConfigHome string) []string {
+	if goos == "windows" {
+		return []string{
+			filepath.Join(home, "Documents", "PowerShell", "Microsoft.PowerShell_profile.ps1"),
+			filepath.Join(home, "Documents", "WindowsPowerShell", "Microsoft.PowerShell_profile.ps1"),
+		}
+	}
+	configDir := xdgConfigHome
+	if configDir == "" {
+		configDir = filepath.Join(home, ".config")
 	}
+	return []string{filepath.Join(configDir, "powershell", "Microsoft.PowerShell_profile.ps1")}
 }
 
 func hooksHomeDir() (string, error) {
@@ -278,6 +296,9 @@ func powerShellHookBlock(executablePath string) string {
 		"if (-not (Get-Variable -Name CommandryLastHistoryId -Scope Global -ErrorAction SilentlyContinue)) {",
 		"  $global:CommandryLastHistoryId = -1",
 		"}",
+		"if (-not $env:COMMANDRY_SHELL_TOKEN) {",
+		"  $env:COMMANDRY_SHELL_TOKEN = \"$PID-$(Get-Random)\"",
+		"}",
 		"function global:prompt {",
 		"  $commandryCwd = (Get-Location).Path",
 		"  $commandryExit = $LASTEXITCODE",
@@ -285,12 +306,20 @@ func powerShellHookBlock(executablePath string) string {
 		"  if ($commandryHist -and $commandryHist.Id -ne $global:CommandryLastHistoryId) {",
 		"    $global:CommandryLastHistoryId = $commandryHist.Id",
 		"    if ($commandryHist.CommandLine -notmatch '^\\s*(cmdry(\\.exe)?|cmdr|it)\\b') {",
-		fmt.Sprintf("      & '%s' hook record --command $commandryHist.CommandLine --exit-code $commandryExit --duration-ms 0 --cwd $commandryCwd 2>$null", escapedPath),
+		fmt.Sprintf("      & '%s' hook record --command $commandryHist.CommandLine --exit-code $commandryExit --cwd $commandryCwd 2>$null", escapedPath),
 		"    }",
 		"  }",
 		"  $commandryPrefix = \"\"",
 		"  try {",
-		"    $commandryRoot = Join-Path $env:APPDATA \"commandry\"",
+		"    if ($env:APPDATA) {",
+		"      $commandryRoot = Join-Path $env:APPDATA \"commandry\"",
+		"    } elseif ($env:XDG_CONFIG_HOME) {",
+		"      $commandryRoot = Join-Path $env:XDG_CONFIG_HOME \"commandry\"",
+		"    } elseif ($IsMacOS) {",
+		"      $commandryRoot = Join-Path $HOME \"Library/Application Support/commandry\"",
+		"    } else {",
+		"      $commandryRoot = Join-Path $HOME \".config/commandry\"",
+		"    }",
 		"    $commandryStatePath = Join-Path $commandryRoot \"hooks_state.json\"",
 		"    $commandryActivePath = Join-Path $commandryRoot \"active_session.json\"",
 		"    if ((Test-Path $commandryStatePath -PathType Leaf) -and (Test-Path $commandryActivePath -PathType Leaf)) {",
@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -75,6 +76,25 @@ func TestHooksHomeDirOverride(t *testing.T) {
 	}
 }
 
+func TestPowerShellProfilePaths(t *testing.T) {
+	t.Parallel()
+
+	windows := powerShellProfilePaths("windows", `C:\Users\ops`, "")
+	if len(windows) != 2 || !strings.Contains(windows[0], "PowerShell") || !strings.Contains(windows[1], "WindowsPowerShell") {
+		t.Fatalf("unexpected windows profile paths: %v", windows)
+	}
+
+	linux := powerShellProfilePaths("linux", "/home/ops", "")
+	if want := filepath.Join("/home/ops", ".config", "powershell", "Microsoft.PowerShell_profile.ps1"); len(linux) != 1 || linux[0] != want {
+		t.Fatalf("linux profile paths = %v, want [%s]", linux, want)
+	}
+
+	xdg := powerShellProfilePaths("darwin", "/Users/ops", "/Users/ops/.xdg")
+	if want := filepath.Join("/Users/ops/.xdg", "powershell", "Microsoft.PowerShell_profile.ps1"); len(xdg) != 1 || xdg[0] != want {
+		t.Fatalf("xdg profile paths = %v, want [%s]", xdg, want)
+	}
+}
+
 func TestPowerShellHookBlockUsesAbsolutePath(t *testing.T) {
 	t.Parallel()
 	block := powerShellHookBlock("C:\\Commandry\\cmdry.exe")
@@ -0,0 +1,276 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fixi2/Commandry/internal/hooks"
+	"github.com/spf13/cobra"
+)
+
+// profileHook describes a shell whose hook block lives in a text profile.
+type profileHook struct {
+	shell       string // key in hooks state and subcommand name
+	label       string
+	profileName string // used in help and errors ("bash profile", "fish conf.d file")
+	begin       string
+	end         string
+	defaultPath func() (string, error)
+	block       func(executablePath string) string
+	// ownsDefaultFile drops the default file when uninstall leaves it empty.
+	ownsDefaultFile bool
+}
+
+var (
+	bashProfileHook = profileHook{
+		shell:       "bash",
+		label:       "Bash",
+		profileName: "bash profile",
+		begin:       bashHookBeginMarker,
+		end:         bashHookEndMarker,
+		defaultPath: bashProfilePath,
+		block:       bashHookBlock,
+	}
+	zshProfileHook = profileHook{
+		shell:       "zsh",
+		label:       "Zsh",
+		profileName: "zsh profile",
+		begin:       zshHookBeginMarker,
+		end:         zshHookEndMarker,
+		defaultPath: zshProfilePath,
+		block:       zshHookBlock,
+	}
+	fishProfileHook = profileHook{
+		shell:           "fish",
+		label:           "Fish",
+		profileName:     "fish conf.d file",
+		begin:           fishHookBeginMarker,
+		end:             fishHookEndMarker,
+		defaultPath:     fishConfPath,
+		block:           fishHookBlock,
+		ownsDefaultFile: true,
+	}
+)
+
+func newProfileHookInstallCmd(h profileHook, stateStore hooks.StateStore) *cobra.Command {
+	var (
+		profile   string
+		printOnly bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   h.shell,
+		Short: fmt.Sprintf("Install %s hook", h.profileName),
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if printOnly && profile != "" {
+				return errors.New("--print and --profile cannot be used together")
+			}
+			exe, err := os.Executable()
+			if err != nil {
+				return fmt.Errorf("resolve executable path: %w", err)
+			}
+			block := h.block(exe)
+			if printOnly {
+				fmt.Fprintln(cmd.OutOrStdout(), block)
+				return nil
+			}
+
+			path, err := h.targetPath(profile)
+			if err != nil {
+				return err
+			}
+			if err := installPosixHook(cmd, path, h.begin, h.end, block); err != nil {
+				return err
+			}
+			return updateHookProfiles(cmd, stateStore, func(state *hooks.State) {
+				state.AddProfile(h.shell, path)
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&profile, "profile", "", fmt.Sprintf("Write the hook to this file instead of the default %s", h.profileName))
+	cmd.Flags().BoolVar(&printOnly, "print", false, "Print the hook block to stdout instead of editing a profile")
+	return cmd
+}
+
+func newProfileHookUninstallCmd(h profileHook, stateStore hooks.StateStore) *cobra.Command {
+	var profile string
+
+	cmd := &cobra.Command{
+		Use:   h.shell,
+		Short: fmt.Sprintf("Remove %s hook", h.profileName),
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			var paths []string
+			if profile != "" {
+				path, err := h.targetPath(profile)
+				if err != nil {
+					return err
+				}
+				paths = []string{path}
+			} else {
+				var err error
+				if paths, err = h.knownPaths(cmd, stateStore); err != nil {
+					return err
+				}
+			}
+
+			removed := 0
+			for _, path := range paths {
+				changed, err := h.removeBlock(path)
+				if err != nil {
+					return err
+				}
+				if changed {
+					removed++
+					printOK(cmd.OutOrStdout(), "Removed hooks from %s", path)
+				}
+			}
+			if removed == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "No hook block found.")
+			}
+			return updateHookProfiles(cmd, stateStore, func(state *hooks.State) {
+				for _, path := range paths {
+					state.RemoveProfile(h.shell, path)
+				}
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&profile, "profile", "", "Only remove the hook from this file")
+	return cmd
+}
+
+// installStatus reports the default profile and every profile recorded at
+// install time, one "- path: state" line each.
+func (h profileHook) installStatus(state hooks.State) (bool, string) {
+	paths := state.Profiles[h.shell]
+	if path, err := h.defaultPath(); err == nil {
+		paths = appendUniquePath([]string{path}, paths...)
+	}
+
+	installed := false
+	lines := make([]string, 0, len(paths))
+	for _, path := range paths {
+		status := "not found"
+		if content, err := readTextFile(path); err == nil {
+			legacyBegin, legacyEnd := legacyPosixMarkers(h.begin, h.end)
+			if (strings.Contains(content, h.begin) && strings.Contains(content, h.end)) ||
+				(legacyBegin != "" && strings.Contains(content, legacyBegin) && strings.Contains(content, legacyEnd)) {
+				status = "installed"
+				installed = true
+			} else {
+				status = "present (no commandry block)"
+			}
+		}
+		lines = append(lines, fmt.Sprintf("- %s: %s", path, status))
+	}
+	return installed, strings.Join(lines, "\n")
+}
+
+func (h profileHook) targetPath(profile string) (string, error) {
+	if profile == "" {
+		return h.defaultPath()
+	}
+	path, err := expandHomePath(profile)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(path)
+}
+
+// knownPaths returns the default profile followed by the recorded ones.
+func (h profileHook) knownPaths(cmd *cobra.Command, stateStore hooks.StateStore) ([]string, error) {
+	state, err := stateStore.Load(cmd.Context())
+	if err != nil {
+		return nil, fmt.Errorf("load hooks state: %w", err)
+	}
+	path, err := h.defaultPath()
+	if err != nil {
+		return nil, err
+	}
+	return appendUniquePath([]string{path}, state.Profiles[h.shell]...), nil
+}
+
+func (h profileHook) removeBlock(path string) (bool, error) {
+	current, err := readTextFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("read profile: %w", err)
+	}
+	updated, changed, err := replaceBetweenMarkers(current, h.begin, h.end, "")
+	if err != nil {
+		return false, err
+	}
+	if !changed {
+		if legacyBegin, legacyEnd := legacyPosixMarkers(h.begin, h.end); legacyBegin != "" {
+			if updated, changed, err = replaceBetweenMarkers(current, legacyBegin, legacyEnd, ""); err != nil {
+				return false, err
+			}
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+
+	// The default fish conf.d snippet is ours; drop it instead of leaving an
+	// empty file behind. Custom profiles may belong to a dotfile manager.
+	if h.ownsDefaultFile && strings.TrimSpace(updated) == "" {
+		if defaultPath, err := h.defaultPath(); err == nil && defaultPath == path {
+			if err := os.Remove(path); err != nil {
+				return false, fmt.Errorf("remove %s: %w", h.profileName, err)
+			}
+			return true, nil
+		}
+	}
+	if err := writeTextFileAtomic(path, updated); err != nil {
+		return false, fmt.Errorf("write profile: %w", err)
+	}
+	return true, nil
+}
+
+func updateHookProfiles(cmd *cobra.Command, stateStore hooks.StateStore, update func(*hooks.State)) error {
+	state, err := stateStore.Load(cmd.Context())
+	if err != nil {
+		return fmt.Errorf("load hooks state: %w", err)
+	}
+	update(&state)
+	if err := stateStore.Save(cmd.Context(), state); err != nil {
+		return fmt.Errorf("save hooks state: %w", err)
+	}
+	return nil
+}
+
+func appendUniquePath(paths []string, more ...string) []string {
+	for _, candidate := range more {
+		seen := false
+		for _, path := range paths {
+			if filepath.Clean(path) == filepath.Clean(candidate) {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			paths = append(paths, candidate)
+		}
+	}
+	return paths
+}
+
+// expandHomePath expands a leading ~ to the hooks home directory.
+func expandHomePath(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") && !strings.HasPrefix(path, `~\`) {
+		return path, nil
+	}
+	home, err := hooksHomeDir()
+	if err != nil || home == "" {
+		return "", errors.New("cannot resolve home directory")
+	}
+	return filepath.Join(home, path[1:]), nil
+}
@@ -0,0 +1,305 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/fixi2/Commandry/internal/hooks"
+	"github.com/fixi2/Commandry/internal/store"
+	"github.com/spf13/cobra"
+)
+
+const (
+	selfTestTimeout   = 30 * time.Second
+	selfTestOKCommand = "echo commandry-selftest"
+	selfTestFailing   = "sh -c 'exit 3'"
+	selfTestSelf      = "cmdry version"
+)
+
+// selfTestScript is fed to the shell on stdin. Every line must be valid in
+// bash, zsh and fish.
+var selfTestScript = strings.Join([]string{
+	selfTestOKCommand,
+	selfTestFailing,
+	selfTestSelf,
+	"exit 0",
+	"",
+}, "\n")
+
+type hookCheck struct {
+	Name   string
+	Passed bool
+	Detail string
+}
+
+func newHooksTestCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "test",
+		Short: "Run installed-hook self-test in a throwaway shell",
+		Long: "Start the shell interactively on a scripted stdin with a temporary HOME and config root,\n" +
+			"load the generated hook block, and check which commands were recorded.\n" +
+			"Your real profile, hooks state and sessions are not touched.",
+	}
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		cmd.AddCommand(newHooksTestShellCmd(shell))
+	}
+	return cmd
+}
+
+func newHooksTestShellCmd(shell string) *cobra.Command {
+	return &cobra.Command{
+		Use:   shell,
+		Short: fmt.Sprintf("Self-test the %s hook", shell),
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if runtime.GOOS == "windows" {
+				return errors.New("hooks test supports bash, zsh and fish on Linux and macOS")
+			}
+			shellPath, err := exec.LookPath(shell)
+			if err != nil {
+				return fmt.Errorf("%s not found in PATH", shell)
+			}
+			exe, err := os.Executable()
+			if err != nil {
+				return fmt.Errorf("resolve executable path: %w", err)
+			}
+
+			ctx, cancel := context.WithTimeout(cmd.Context(), selfTestTimeout)
+			defer cancel()
+			checks, err := runHookSelfTest(ctx, shell, shellPath, exe)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Shell: %s (%s)\n\n", shell, shellPath)
+			failed := printHookChecks(cmd.OutOrStdout(), checks)
+			if failed > 0 {
+				return &ExitError{Code: 1, Err: fmt.Errorf("%d of %d hook check(s) failed", failed, len(checks))}
+			}
+			printOK(cmd.OutOrStdout(), "%s hook works", shell)
+			return nil
+		},
+	}
+}
+
+// runHookSelfTest records the self-test script through the hook block of
+// shell into a fresh session under a temporary HOME and returns the checks.
+func runHookSelfTest(ctx context.Context, shell, shellPath, exe string) ([]hookCheck, error) {
+	tmp, err := os.MkdirTemp("", "cmdry-hooks-test-")
+	if err != nil {
+		return nil, fmt.Errorf("create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	home := filepath.Join(tmp, "home")
+	work := filepath.Join(tmp, "work")
+	bin := filepath.Join(tmp, "bin")
+	for _, dir := range []string{home, work, bin} {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, fmt.Errorf("create %s: %w", dir, err)
+		}
+	}
+	// `cmdry` on PATH resolves to this binary so the self-invocation filter
+	// sees a real, successful command.
+	if err := os.Symlink(exe, filepath.Join(bin, "cmdry")); err != nil {
+		return nil, fmt.Errorf("link cmdry into temp PATH: %w", err)
+	}
+
+	// Mirrors os.UserConfigDir with XDG_CONFIG_HOME unset, which is also what
+	// the hook blocks fall back to.
+	root := filepath.Join(home, ".config", "commandry")
+	if runtime.GOOS == "darwin" {
+		root = filepath.Join(home, "Library", "Application Support", "commandry")
+	}
+	sessionStore := store.NewJSONStore(root)
+	if err := sessionStore.Init(ctx); err != nil {
+		return nil, fmt.Errorf("initialize temp store: %w", err)
+	}
+	stateStore := hooks.NewFileStateStore(root)
+	state, err := stateStore.Load(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load hooks state: %w", err)
+	}
+	state.Enabled = true
+	state.RemindEvery = 0
+	if err := stateStore.Save(ctx, state); err != nil {
+		return nil, fmt.Errorf("save hooks state: %w", err)
+	}
+	if _, err := sessionStore.StartSessionWithOptions(ctx, store.StartOptions{
+		Title:     "hooks self-test",
+		StartedAt: time.Now().UTC(),
+		StartDir:  work,
+	}); err != nil {
+		return nil, fmt.Errorf("start temp session: %w", err)
+	}
+
+	args, err := writeSelfTestProfile(shell, home, exe)
+	if err != nil {
+		return nil, err
+	}
+	run := exec.CommandContext(ctx, shellPath, args...)
+	run.Dir = work
+	run.Env = selfTestEnv(home, bin)
+	run.Stdin = strings.NewReader(selfTestScript)
+	output, runErr := run.CombinedOutput()
+	if ctx.Err() != nil {
+		runErr = fmt.Errorf("timed out after %s", selfTestTimeout)
+	}
+
+	active, err := sessionStore.GetActiveSession(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("read temp session: %w", err)
+	}
+	return evaluateHookSelfTest(active.Steps, runErr, string(output)), nil
+}
+
+// writeSelfTestProfile writes the hook block where shell loads it and returns
+// the shell arguments.
+func writeSelfTestProfile(shell, home, exe string) ([]string, error) {
+	var (
+		path  string
+		block string
+		args  []string
+	)
+	switch shell {
+	case "bash":
+		path = filepath.Join(home, ".bashrc")
+		block = bashHookBlock(exe)
+		args = []string{"--noprofile", "--rcfile", path, "-i"}
+	case "zsh":
+		path = filepath.Join(home, ".zshrc")
+		block = zshHookBlock(exe)
+		args = []string{"-i"}
+	case "fish":
+		path = filepath.Join(home, ".config", "fish", "conf.d", "commandry.fish")
+		block = fishHookBlock(exe)
+		args = []string{"-i"}
+	default:
+		return nil, fmt.Errorf("unsupported shell %q", shell)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("create profile dir: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(block+"\n"), 0o600); err != nil {
+		return nil, fmt.Errorf("write temp profile: %w", err)
+	}
+	return args, nil
+}
+
+// selfTestEnv drops variables that would point the shell or the recorder at
+// the user's real profile, config root, hookd socket or terminal token.
+func selfTestEnv(home, bin string) []string {
+	drop := map[string]bool{
+		"HOME": true, "ZDOTDIR": true, "XDG_CONFIG_HOME": true, "APPDATA": true,
+		"COMMANDRY_HOME_DIR": true, "INFRATRACK_HOME_DIR": true, hooks.ShellTokenEnv: true,
+		"HISTFILE": true, "PROMPT_COMMAND": true, "BASH_ENV": true, "ENV": true, "PATH": true,
+	}
+	env := make([]string, 0, len(os.Environ())+4)
+	for _, kv := range os.Environ() {
+		key, _, _ := strings.Cut(kv, "=")
+		if !drop[key] {
+			env = append(env, kv)
+		}
+	}
+	return append(env,
+		"HOME="+home,
+		"ZDOTDIR="+home,
+		"HISTFILE="+filepath.Join(home, ".history"),
+		"PATH="+bin+string(os.PathListSeparator)+os.Getenv("PATH"),
+	)
+}
+
+func evaluateHookSelfTest(steps []store.Step, runErr error, output string) []hookCheck {
+	checks := make([]hookCheck, 0, 5)
+
+	started := hookCheck{Name: "shell ran the script", Passed: runErr == nil}
+	if runErr != nil {
+		started.Detail = runErr.Error()
+		if last := lastLine(output); last != "" {
+			started.Detail += ": " + last
+		}
+	}
+	checks = append(checks, started)
+
+	find := func(command string) *store.Step {
+		for i := range steps {
+			if strings.TrimSpace(steps[i].Command) == command {
+				return &steps[i]
+			}
+		}
+		return nil
+	}
+	stepCheck := func(name, command string, wantExit int, wantStatus string) hookCheck {
+		step := find(command)
+		if step == nil {
+			return hookCheck{Name: name, Detail: fmt.Sprintf("%q not recorded", command)}
+		}
+		exit := -1
+		if step.ExitCode != nil {
+			exit = *step.ExitCode
+		}
+		return hookCheck{
+			Name:   name,
+			Passed: exit == wantExit && step.Status == wantStatus,
+			Detail: fmt.Sprintf("%s, exit %d", step.Status, exit),
+		}
+	}
+	checks = append(checks,
+		stepCheck("records successful command", selfTestOKCommand, 0, "OK"),
+		stepCheck("records failing command with exit code", selfTestFailing, 3, "FAILED"),
+	)
+
+	self := hookCheck{Name: "skips cmdry self-invocation", Passed: find(selfTestSelf) == nil}
+	if !self.Passed {
+		self.Detail = fmt.Sprintf("%q was recorded", selfTestSelf)
+	}
+	checks = append(checks, self)
+
+	unexpected := make([]string, 0)
+	for _, step := range steps {
+		switch strings.TrimSpace(step.Command) {
+		case selfTestOKCommand, selfTestFailing:
+		default:
+			unexpected = append(unexpected, step.Command)
+		}
+	}
+	extra := hookCheck{Name: "records nothing else", Passed: len(unexpected) == 0}
+	if !extra.Passed {
+		extra.Detail = strings.Join(unexpected, "; ")
+	}
+	return append(checks, extra)
+}
+
+func printHookChecks(w io.Writer, checks []hookCheck) int {
+	width := len("CHECK")
+	for _, check := range checks {
+		if len(check.Name) > width {
+			width = len(check.Name)
+		}
+	}
+	failed := 0
+	fmt.Fprintf(w, "%-*s  %-6s  %s\n", width, "CHECK", "RESULT", "DETAIL")
+	for _, check := range checks {
+		result := "PASS"
+		if !check.Passed {
+			result = "FAIL"
+			failed++
+		}
+		fmt.Fprintf(w, "%-*s  %-6s  %s\n", width, check.Name, result, check.Detail)
+	}
+	fmt.Fprintln(w)
+	return failed
+}
+
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/fixi2/Commandry/internal/store"
+)
+
+func TestEvaluateHookSelfTest(t *testing.T) {
+	t.Parallel()
+
+	passing := []store.Step{
+		{Command: selfTestOKCommand, Status: "OK", ExitCode: intPtr(0)},
+		{Command: selfTestFailing, Status: "FAILED", ExitCode: intPtr(3)},
+	}
+	for _, check := range evaluateHookSelfTest(passing, nil, "") {
+		if !check.Passed {
+			t.Fatalf("expected %q to pass: %+v", check.Name, check)
+		}
+	}
+
+	broken := []store.Step{
+		{Command: selfTestOKCommand, Status: "OK", ExitCode: intPtr(0)},
+		{Command: selfTestFailing, Status: "OK", ExitCode: intPtr(0)},
+		{Command: selfTestSelf, Status: "OK", ExitCode: intPtr(0)},
+	}
+	checks := evaluateHookSelfTest(broken, errors.New("exit status 2"), "prompt\nbash: syntax error\n")
+	var out bytes.Buffer
+	if failed := printHookChecks(&out, checks); failed != 4 {
+		t.Fatalf("expected 4 failed checks, got %d:\n%s", failed, out.String())
+	}
+	for _, want := range []string{
+		"shell ran the script                    FAIL    exit status 2: bash: syntax error",
+		"records successful command              PASS    OK, exit 0",
+		"records failing command with exit code  FAIL    OK, exit 0",
+		"skips cmdry self-invocation             FAIL",
+		"records nothing else                    FAIL    cmdry version",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected %q in table:\n%s", want, out.String())
+		}
+	}
+
+	checks = evaluateHookSelfTest(nil, nil, "")
+	if checks[1].Passed || !strings.Contains(checks[1].Detail, "not recorded") {
+		t.Fatalf("expected missing step to fail, got %+v", checks[1])
+	}
+}
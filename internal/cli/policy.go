@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/fixi2/Commandry/internal/policy"
+	"github.com/fixi2/Commandry/internal/store"
+	"github.com/fixi2/Commandry/internal/util"
+	"github.com/spf13/cobra"
+)
+
+func newPolicyCmd(s store.SessionStore, policies *policy.Resolver) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "policy",
+		Short: "Inspect how the sanitization policy treats commands",
+	}
+	cmd.AddCommand(newPolicyTestCmd(s, policies), newPolicyAuditCmd(s))
+	return cmd
+}
+
+func newPolicyTestCmd(s store.SessionStore, policies *policy.Resolver) *cobra.Command {
+	var (
+		env         string
+		shellScript string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "test [--shell '<script>'] -- <command> [args...]",
+		Short: "Show which denylist and redaction rules match a command",
+		Long: "Sanitize a command the way `cmdry run` would, without running or recording it,\n" +
+			"and list every denylist pattern and redactor that matched.\n" +
+			"--shell checks a whole shell line the way `cmdry run --shell` and the shell hooks do,\n" +
+			"including commands inside subshells, $( ... ) and backticks.\n" +
+			"The policy profile is picked by --env, or by the active session env.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			useShell := cmd.Flags().Changed("shell")
+			if useShell && len(args) > 0 {
+				return errors.New("use either --shell '<script>' or -- <command> [args...], not both")
+			}
+			if !useShell && len(args) == 0 {
+				return errors.New("usage: cmdry policy test -- <command> [args...]")
+			}
+			if !cmd.Flags().Changed("env") {
+				active, err := s.GetActiveSession(cmd.Context())
+				switch {
+				case err == nil:
+					env = active.Env
+				case !errors.Is(err, store.ErrNoActiveSession) && !errors.Is(err, store.ErrNotInitialized):
+					return fmt.Errorf("check active session: %w", err)
+				}
+			}
+			p := policies.ForEnv(env)
+			var (
+				rawCommand string
+				result     policy.Result
+				trace      policy.Trace
+			)
+			if useShell {
+				rawCommand = shellScript
+				result, trace = p.ApplyShellWithTrace(shellScript)
+			} else {
+				rawCommand = util.JoinCommand(args)
+				result, trace = p.ApplyWithTrace(rawCommand, args)
+			}
+
+			out := cmd.OutOrStdout()
+			if profile, ok := policies.Profile(env); ok {
+				fmt.Fprintf(out, "Profile:   %s\n", profile)
+			}
+			if p.Mode() == policy.ModeAllowlist {
+				fmt.Fprintf(out, "Mode:      %s\n", policy.ModeAllowlist)
+			}
+			fmt.Fprintf(out, "Input:     %s\n", rawCommand)
+			fmt.Fprintf(out, "Sanitized: %s\n\n", result.Command)
+			printPolicyMatches(out, trace.Matches)
+
+			switch {
+			case !result.Denied:
+				printOK(out, "Not denied: `cmdry run` records the sanitized command")
+			case p.EnforceDenylist():
+				printWarn(out, "Denied and blocked: enforce_denylist is true, so `cmdry run` does not execute it")
+			default:
+				printWarn(out, "Denied but not blocked: enforce_denylist is false, so `cmdry run` executes it and records %s", policy.DeniedPlaceholder)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&env, "env", "", "Session env whose policy profile applies (default: the active session env)")
+	cmd.Flags().StringVar(&shellScript, "shell", "", "Check a shell line (pipelines, &&, ;, subshells) instead of a single command")
+	return cmd
+}
+
+func printPolicyMatches(w io.Writer, matches []policy.Match) {
+	if len(matches) == 0 {
+		fmt.Fprintln(w, "No rules matched.")
+		fmt.Fprintln(w)
+		return
+	}
+
+	rows := make([][4]string, 0, len(matches)+1)
+	rows = append(rows, [4]string{"KIND", "RULE", "SPAN", "MATCH"})
+	for _, match := range matches {
+		span := "-"
+		if match.Start >= 0 {
+			span = strconv.Itoa(match.Start) + "-" + strconv.Itoa(match.End)
+		}
+		rows = append(rows, [4]string{match.Kind, match.Rule, span, strconv.Quote(match.Text)})
+	}
+	var widths [3]int
+	for _, row := range rows {
+		for i := range widths {
+			if len(row[i]) > widths[i] {
+				widths[i] = len(row[i])
+			}
+		}
+	}
+	for _, row := range rows {
+		fmt.Fprintf(w, "%-*s  %-*s  %-*s  %s\n", widths[0], row[0], widths[1], row[1], widths[2], row[2], row[3])
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestPolicyTestExplainsMatches(t *testing.T) {
+	setupCLIEnv(t)
+
+	out := mustExecuteCLI(t, "policy", "test", "--", "deploy", "--token=abc123", "--region", "eu")
+	for _, want := range []string{
+		"Sanitized: deploy --token=[REDACTED] --region eu",
+		"redact  keyword-flag  15-21  \"abc123\"",
+		"Not denied",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in output, got %q", want, out)
+		}
+	}
+
+	out = mustExecuteCLI(t, "policy", "test", "--", "cat", "server.pem")
+	if !strings.Contains(out, "deny  *.pem") || !strings.Contains(out, "enforce_denylist is false") {
+		t.Fatalf("expected denylist match without enforcement, got %q", out)
+	}
+}
+
+func TestPolicyTestShellChecksSubshells(t *testing.T) {
+	setupCLIEnv(t)
+
+	out := mustExecuteCLI(t, "policy", "test", "--shell", "echo ok && (kubectl get secret x -o yaml)")
+	if !strings.Contains(out, "builtin: kubectl get secret -o yaml|json") || !strings.Contains(out, "Sanitized: [REDACTED BY POLICY]") {
+		t.Fatalf("expected the subshell to be denied, got %q", out)
+	}
+	out = mustExecuteCLI(t, "policy", "test", "--shell", "echo ok | deploy --token=abc")
+	if !strings.Contains(out, "Sanitized: echo ok | deploy --token=[REDACTED]") || !strings.Contains(out, "keyword-flag  25-28") {
+		t.Fatalf("expected spans in the whole line, got %q", out)
+	}
+	if _, err := executeCLI(t, "policy", "test", "--shell", "ls", "--", "ls"); err == nil {
+		t.Fatalf("expected an error for --shell with a command")
+	}
+}
+
+func TestPolicyTestReportsEnforcement(t *testing.T) {
+	configRoot := setupCLIEnv(t)
+	mustExecuteCLI(t, "init")
+	config := "policy:\n  enforce_denylist: true\n"
+	if err := os.WriteFile(filepath.Join(configRoot, "config.yaml"), []byte(config), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	out := mustExecuteCLI(t, "policy", "test", "--", "printenv")
+	if !strings.Contains(out, "builtin: printenv") || !strings.Contains(out, "Denied and blocked") {
+		t.Fatalf("expected blocked printenv, got %q", out)
+	}
+	if _, err := executeCLI(t, "policy", "test"); err == nil {
+		t.Fatalf("expected usage error without a command")
+	}
+}
+
+func TestAllowlistModeBlocksUnlistedCommands(t *testing.T) {
+	configRoot := setupCLIEnv(t)
+	mustExecuteCLI(t, "init")
+	config := "policy:\n  mode: allowlist\n  allowlist: [git status]\n  enforce_denylist: true\n"
+	if err := os.WriteFile(filepath.Join(configRoot, "config.yaml"), []byte(config), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	out := mustExecuteCLI(t, "policy", "test", "--", "git", "status", "--short")
+	if !strings.Contains(out, "Mode:      allowlist") || !strings.Contains(out, "allow  git status") || !strings.Contains(out, "Not denied") {
+		t.Fatalf("expected git status to be allowlisted, got %q", out)
+	}
+
+	mustExecuteCLI(t, "start", "allowlist")
+	command := []string{"run", "--", "sh", "-c", "echo hi"}
+	if runtime.GOOS == "windows" {
+		command = []string{"run", "--", "cmd", "/c", "echo hi"}
+	}
+	out, err := executeCLI(t, command...)
+	var exitErr *ExitError
+	if !asExitErrorCLI(err, &exitErr) || exitErr.Code != 2 {
+		t.Fatalf("expected ExitError code 2, got err=%v", err)
+	}
+	if !strings.Contains(out, "Command blocked by policy (allowlist mode)") {
+		t.Fatalf("expected the allowlist block message, got %q", out)
+	}
+}
+
+func TestPolicyAuditSummarizesBlocksAndRedactions(t *testing.T) {
+	configRoot := setupCLIEnv(t)
+	mustExecuteCLI(t, "init")
+	if out := mustExecuteCLI(t, "policy", "audit"); !strings.Contains(out, "No policy blocks or redactions recorded") {
+		t.Fatalf("expected an empty audit summary, got %q", out)
+	}
+
+	mustExecuteCLI(t, "start", "audit")
+	mustExecuteCLI(t, "run", "--plan", "--", "printenv", "AUDIT_SENTINEL")
+	mustExecuteCLI(t, "run", "--plan", "--", "/usr/bin/printenv", "HOME")
+	config := "policy:\n  enforce_denylist: true\n"
+	if err := os.WriteFile(filepath.Join(configRoot, "config.yaml"), []byte(config), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if _, err := executeCLI(t, "run", "--", "cat", "deploy.pem"); err == nil {
+		t.Fatalf("expected cat deploy.pem to be blocked")
+	}
+
+	data, err := os.ReadFile(filepath.Join(configRoot, "audit.jsonl"))
+	if err != nil {
+		t.Fatalf("read audit log: %v", err)
+	}
+	if strings.Contains(string(data), "AUDIT_SENTINEL") || strings.Contains(string(data), "deploy.pem") {
+		t.Fatalf("audit log must not contain commands:\n%s", data)
+	}
+
+	out := mustExecuteCLI(t, "policy", "audit", "--since", "1h")
+	if !strings.Contains(out, ": 3 (blocked 1, redacted 2)") {
+		t.Fatalf("unexpected audit totals:\n%s", out)
+	}
+	lines := strings.Split(out, "\n")
+	if len(lines) < 5 || strings.Fields(lines[3])[0] != "builtin:" || !strings.HasSuffix(lines[3], "0         2") ||
+		!strings.HasPrefix(lines[4], "*.pem") || !strings.HasSuffix(lines[4], "1         0") {
+		t.Fatalf("unexpected audit rows:\n%s", out)
+	}
+	if out := mustExecuteCLI(t, "policy", "audit", "--since", "2999-01-01"); !strings.Contains(out, "No policy blocks or redactions since") {
+		t.Fatalf("expected --since to filter events, got %q", out)
+	}
+	if _, err := executeCLI(t, "policy", "audit", "--since", "yesterday"); err == nil {
+		t.Fatalf("expected an invalid --since error")
+	}
+}
@@ -0,0 +1,181 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/fixi2/Commandry/internal/policy"
+	"github.com/fixi2/Commandry/internal/store"
+	"github.com/fixi2/Commandry/internal/util"
+	"github.com/spf13/cobra"
+)
+
+func newSessionsResanitizeCmd(s store.SessionStore, policies *policy.Resolver) *cobra.Command {
+	var all bool
+
+	cmd := &cobra.Command{
+		Use:   "resanitize [--all | <id>]",
+		Short: "Re-apply the current policy to stored sessions",
+		Long: "Re-apply the current policy to the step commands of completed sessions, for example after adding a redaction rule for a leaked secret.\n\n" +
+			"Each session is sanitized with the policy profile for its env. A step changes only if every value redacted before stays redacted, and sessions.jsonl is replaced in one atomic write. " +
+			"Sessions already sanitized with the current policy are skipped; a session records the current policy only when one of its steps changed.",
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if all == (len(args) == 1) {
+				return errors.New("provide `--all` or a session id")
+			}
+			id := ""
+			if len(args) == 1 {
+				id = strings.TrimSpace(args[0])
+			}
+
+			var (
+				found    bool
+				upToDate int
+				reports  []resanitizeReport
+			)
+			_, err := s.RewriteSessions(cmd.Context(), func(session *store.Session) bool {
+				if id != "" && session.ID != id {
+					return false
+				}
+				found = true
+				p := policies.ForEnv(session.Env)
+				if session.PolicyFingerprint == p.Fingerprint() {
+					upToDate++
+					return false
+				}
+				report := resanitizeReport{session: session.ID, title: session.Title}
+				for i := range session.Steps {
+					if resanitizeStep(&session.Steps[i], p) {
+						report.steps = append(report.steps, i)
+						report.commands = append(report.commands, session.Steps[i].Command)
+					}
+				}
+				if len(report.steps) == 0 {
+					return false
+				}
+				reports = append(reports, report)
+				session.PolicyFingerprint = p.Fingerprint()
+				return true
+			})
+			if err != nil {
+				if errors.Is(err, store.ErrNotInitialized) {
+					return errors.New("Commandry is not initialized. Run `cmdry init` first")
+				}
+				if errors.Is(err, store.ErrNoSessions) {
+					return errors.New("no completed sessions found")
+				}
+				return fmt.Errorf("resanitize sessions: %w", err)
+			}
+			if id != "" && !found {
+				return fmt.Errorf("session %q not found", id)
+			}
+
+			out := cmd.OutOrStdout()
+			changedSteps := 0
+			for _, report := range reports {
+				changedSteps += len(report.steps)
+				printOK(out, "Session %s (%s): re-sanitized %d step(s)", report.session, report.title, len(report.steps))
+				// Only the new commands are shown; the old ones may hold the leak.
+				for i, step := range report.steps {
+					fmt.Fprintf(out, "  step %d: %s\n", step+1, report.commands[i])
+				}
+			}
+			if len(reports) == 0 {
+				printOK(out, "No stored commands changed under the current policy")
+			} else {
+				printOK(out, "Re-sanitized %d step(s) in %d session(s)", changedSteps, len(reports))
+			}
+			if upToDate > 0 {
+				printHint(out, "%d session(s) were already sanitized with the current policy.", upToDate)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&all, "all", false, "Re-sanitize every completed session")
+	return cmd
+}
+
+type resanitizeReport struct {
+	session  string
+	title    string
+	steps    []int
+	commands []string
+}
+
+// resanitizeStep re-applies p to the stored command of step and reports
+// whether it changed. Apart from a denylist match, the change is kept only
+// if every value redacted in the stored command is still inside a redacted
+// value of the new one, so a looser policy never rewrites what an earlier one
+// redacted.
+func resanitizeStep(step *store.Step, p *policy.Policy) bool {
+	if step.Command == policy.DeniedPlaceholder {
+		return false
+	}
+	var result policy.Result
+	// Hook lines are whole shell lines, sanitized like `run --shell`.
+	if step.Shell || step.Source == "hook" {
+		result = p.ApplyShell(step.Command)
+	} else {
+		result = p.Apply(step.Command, util.SplitArgs(step.Command))
+	}
+	if result.Command == step.Command {
+		return false
+	}
+	if !result.Denied && !keepsRedactions(step.Command, result.Command) {
+		return false
+	}
+
+	step.Command = result.Command
+	if result.Denied {
+		// Like `cmdry run`, planned steps stay PLANNED.
+		if step.Status != "PLANNED" {
+			step.Status = "REDACTED"
+		}
+		step.Reason = "policy_redacted"
+	}
+	return true
+}
+
+// keepsRedactions reports whether updated is old with some spans replaced by
+// policy.RedactedValue, and every redacted value of old lies within one of
+// those spans.
+func keepsRedactions(old, updated string) bool {
+	literals := strings.Split(updated, policy.RedactedValue)
+	var pattern strings.Builder
+	pattern.WriteString(`(?s)^`)
+	for i, literal := range literals {
+		if i > 0 {
+			pattern.WriteString(`(.*?)`)
+		}
+		pattern.WriteString(regexp.QuoteMeta(literal))
+	}
+	pattern.WriteString(`$`)
+	loc := regexp.MustCompile(pattern.String()).FindStringSubmatchIndex(old)
+	if loc == nil {
+		return false
+	}
+
+	for offset := 0; ; {
+		i := strings.Index(old[offset:], policy.RedactedValue)
+		if i < 0 {
+			return true
+		}
+		start := offset + i
+		end := start + len(policy.RedactedValue)
+		covered := false
+		for group := 1; 2*group < len(loc); group++ {
+			if loc[2*group] <= start && end <= loc[2*group+1] {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+		offset = end
+	}
+}
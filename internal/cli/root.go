@@ -27,11 +27,25 @@ func NewRootCommand() (*cobra.Command, error) {
 
 	s := store.NewJSONStore(rootDir)
 	policyPath := filepath.Join(rootDir, "config.yaml")
-	p, policyErr := policy.LoadFromConfigOrDefault(policyPath)
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("get working directory: %w", err)
+	}
+	loaded, loadErr := loadPolicyConfig(rootDir, cwd)
+	if loadErr != nil {
+		fallback := "built-in defaults"
+		if loaded.userPath != "" {
+			fallback = loaded.userPath + " only"
+		}
+		fmt.Fprintf(os.Stderr, "Warning: %v. Recording is disabled until it is fixed; other commands use %s.\n", loadErr, fallback)
+	}
+	policies, policyErr := policy.NewResolver(loaded.config)
 	if policyErr != nil {
-		fmt.Fprintf(os.Stderr, "Warning: failed to load policy config from %s (%v). Using defaults.\n", policyPath, policyErr)
-		p = policy.NewDefault()
+		fmt.Fprintf(os.Stderr, "Warning: failed to load policy config from %s (%v). Recording is disabled until it is fixed; other commands use defaults.\n", policyPath, policyErr)
+		policies = policy.NewDefaultResolver()
+		loadErr = fmt.Errorf("load policy config from %s: %w", policyPath, policyErr)
 	}
+	p := policies.Default()
 	hooksState := hooks.NewFileStateStore(rootDir)
 
 	rootCmd := &cobra.Command{
@@ -50,22 +64,44 @@ func NewRootCommand() (*cobra.Command, error) {
 	rootCmd.AddCommand(
 		newInitCmd(s),
 		newSetupCmd(),
-		newStartCmd(s),
+		newStartCmd(s, policies),
 		newStopCmd(s),
-		newStatusCmd(s),
+		newStatusCmd(s, policies),
 		newDoctorCmd(s),
-		newRunCmd(s, p),
-		newExportCmd(s),
-		newSessionsCmd(s),
+		newConfigCmd(s),
+		newPolicyCmd(s, policies),
+		newRunCmd(s, policies),
+		newExportCmd(s, p),
+		newSessionsCmd(s, policies),
 		newHooksCmd(s, hooksState),
-		newHookCmd(s, p, hooksState),
+		newHookCmd(s, policies, hooksState),
+		newHookdCmd(s, policies, hooksState),
 		newAliasCmd(),
 		newVersionCmd(),
 	)
+	requireValidConfig(rootCmd, loadErr)
 
 	return rootCmd, nil
 }
 
+// requireValidConfig makes the commands that record or rewrite commands fail
+// while the config is invalid. Falling back to defaults there would silently
+// drop the user's denylist, allowlist mode and redaction rules.
+func requireValidConfig(rootCmd *cobra.Command, configErr error) {
+	if configErr == nil {
+		return
+	}
+	for _, path := range [][]string{{"run"}, {"hook", "record"}, {"hookd"}, {"sessions", "resanitize"}} {
+		cmd, _, err := rootCmd.Find(path)
+		if err != nil {
+			continue
+		}
+		cmd.PreRunE = func(*cobra.Command, []string) error {
+			return fmt.Errorf("%w. Fix the config (see `cmdry config validate`) before recording", configErr)
+		}
+	}
+}
+
 func newInitCmd(s store.SessionStore) *cobra.Command {
 	return &cobra.Command{
 		Use:     "init",
@@ -82,8 +118,14 @@ func newInitCmd(s store.SessionStore) *cobra.Command {
 	}
 }
 
-func newStartCmd(s store.SessionStore) *cobra.Command {
-	var env string
+func newStartCmd(s store.SessionStore, policies *policy.Resolver) *cobra.Command {
+	var (
+		env          string
+		plan         bool
+		scope        string
+		noScope      bool
+		thisTerminal bool
+	)
 
 	cmd := &cobra.Command{
 		Use:     "start <title>",
@@ -96,8 +138,44 @@ func newStartCmd(s store.SessionStore) *cobra.Command {
 				return errors.New("title cannot be empty")
 			}
 
+			if noScope && cmd.Flags().Changed("scope") {
+				return errors.New("--scope and --no-scope cannot be used together")
+			}
+			if !noScope && scope == "" {
+				scope = policies.Default().ProjectRoot()
+			}
+			if !noScope && scope != "" {
+				resolved, err := resolveScopeDir(scope)
+				if err != nil {
+					return err
+				}
+				scope = resolved
+			} else {
+				scope = ""
+			}
+			shellToken := ""
+			if thisTerminal {
+				shellToken = strings.TrimSpace(os.Getenv(hooks.ShellTokenEnv))
+				if shellToken == "" {
+					return fmt.Errorf("%s is not set in this shell. Install hooks with `cmdry hooks install` and open a new terminal", hooks.ShellTokenEnv)
+				}
+			}
+
+			// Best effort: without it the export anchors cd lines on the first step.
+			startDir, _ := os.Getwd()
+
 			startedAt := time.Now().UTC()
-			session, err := s.StartSession(cmd.Context(), title, env, startedAt)
+			session, err := s.StartSessionWithOptions(cmd.Context(), store.StartOptions{
+				Title:      title,
+				Env:        env,
+				StartedAt:  startedAt,
+				Plan:       plan,
+				Scope:      scope,
+				ShellToken: shellToken,
+				StartDir:   startDir,
+
+				PolicyFingerprint: policies.ForEnv(env).Fingerprint(),
+			})
 			if err != nil {
 				if errors.Is(err, store.ErrNotInitialized) {
 					return errors.New("Commandry is not initialized. Run `cmdry init` first")
@@ -116,17 +194,51 @@ func newStartCmd(s store.SessionStore) *cobra.Command {
 					session.Env,
 					session.StartedAt.Format(time.RFC3339),
 				)
-				return nil
+			} else {
+				printOK(cmd.OutOrStdout(), "Started session %q at %s", session.Title, session.StartedAt.Format(time.RFC3339))
+			}
+			if session.Plan {
+				printHint(cmd.OutOrStdout(), "Plan mode: `cmdry run` records steps as PLANNED without executing them.")
+			}
+			if session.Scope != "" {
+				printHint(cmd.OutOrStdout(), "Hooks record only commands run inside %s.", session.Scope)
+			}
+			if session.ShellToken != "" {
+				printHint(cmd.OutOrStdout(), "Hooks record only commands from this terminal.")
 			}
-			printOK(cmd.OutOrStdout(), "Started session %q at %s", session.Title, session.StartedAt.Format(time.RFC3339))
 			return nil
 		},
 	}
 
 	cmd.Flags().StringVarP(&env, "env", "e", "", "Optional environment label (for example: staging, prod)")
+	cmd.Flags().BoolVar(&plan, "plan", false, "Record steps without executing them (dry-run session)")
+	cmd.Flags().StringVar(&scope, "scope", "", "Only record hook commands run inside this directory (default: hooks.project_root from config)")
+	cmd.Flags().BoolVar(&noScope, "no-scope", false, "Ignore hooks.project_root and record hook commands from any directory")
+	cmd.Flags().BoolVar(&thisTerminal, "this-terminal", false, "Only record hook commands from the terminal running this command")
 	return cmd
 }
 
+// resolveScopeDir expands a leading ~ and returns the absolute path of an
+// existing directory.
+func resolveScopeDir(dir string) (string, error) {
+	dir, err := expandHomePath(dir)
+	if err != nil {
+		return "", fmt.Errorf("resolve scope: %w", err)
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("resolve scope %s: %w", dir, err)
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return "", fmt.Errorf("scope directory %s: %w", abs, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("scope %s is not a directory", abs)
+	}
+	return abs, nil
+}
+
 func newStopCmd(s store.SessionStore) *cobra.Command {
 	return &cobra.Command{
 		Use:     "stop",
@@ -153,7 +265,7 @@ func newStopCmd(s store.SessionStore) *cobra.Command {
 	}
 }
 
-func newStatusCmd(s store.SessionStore) *cobra.Command {
+func newStatusCmd(s store.SessionStore, policies *policy.Resolver) *cobra.Command {
 	return &cobra.Command{
 		Use:   "status",
 		Short: "Show current Commandry session status",
@@ -184,6 +296,22 @@ func newStatusCmd(s store.SessionStore) *cobra.Command {
 			if active.Env != "" {
 				fmt.Fprintf(cmd.OutOrStdout(), "Env: %s\n", active.Env)
 			}
+			if profile, ok := policies.Profile(active.Env); ok {
+				fmt.Fprintf(cmd.OutOrStdout(), "Policy profile: %s\n", profile)
+			}
+			if active.Plan {
+				fmt.Fprintln(cmd.OutOrStdout(), "Mode: plan (steps are not executed)")
+			}
+			if active.Scope != "" {
+				fmt.Fprintf(cmd.OutOrStdout(), "Scope: %s\n", active.Scope)
+			}
+			if active.ShellToken != "" {
+				where := "another terminal"
+				if os.Getenv(hooks.ShellTokenEnv) == active.ShellToken {
+					where = "this terminal"
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Terminal: hooks record from one terminal only (%s)\n", where)
+			}
 			fmt.Fprintf(cmd.OutOrStdout(), "Started: %s\n", active.StartedAt.Format(time.RFC3339))
 			fmt.Fprintf(cmd.OutOrStdout(), "Recorded steps: %d\n", len(active.Steps))
 
@@ -192,31 +320,68 @@ func newStatusCmd(s store.SessionStore) *cobra.Command {
 	}
 }
 
-func newRunCmd(s store.SessionStore, p *policy.Policy) *cobra.Command {
-	return &cobra.Command{
+func newRunCmd(s store.SessionStore, policies *policy.Resolver) *cobra.Command {
+	var (
+		shellScript string
+		trackPaths  []string
+		yes         bool
+		plan        bool
+		expectExit  []int
+		expectFail  bool
+	)
+
+	cmd := &cobra.Command{
 		Use:     "run -- <command> [args...]",
 		Aliases: []string{"r"},
 		Short:   "Execute a command and capture sanitized metadata for the active session",
 		Args:    cobra.ArbitraryArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if len(args) == 0 {
-				return errors.New("usage: cmdry run -- <command> [args...]")
+			useShell := cmd.Flags().Changed("shell")
+			if useShell && len(args) > 0 {
+				return errors.New("use either `--shell '<script>'` or `-- <command> [args...]`, not both")
+			}
+			if useShell && strings.TrimSpace(shellScript) == "" {
+				return errors.New("shell script cannot be empty")
+			}
+			if !useShell && len(args) == 0 {
+				return errors.New("usage: cmdry run -- <command> [args...] or cmdry run --shell '<script>'")
 			}
 
-			if _, err := s.GetActiveSession(cmd.Context()); err != nil {
+			active, err := s.GetActiveSession(cmd.Context())
+			if err != nil {
 				if errors.Is(err, store.ErrNoActiveSession) {
 					return errors.New("no active session. Run `cmdry start \"<title>\"` before `cmdry run`")
 				}
 				return fmt.Errorf("check active session: %w", err)
 			}
+			p := policies.ForEnv(active.Env)
+			if expectFail && len(expectExit) > 0 {
+				return errors.New("use either `--expect-exit` or `--expect-fail`, not both")
+			}
+			planned := plan || active.Plan
+			if planned && len(trackPaths) > 0 {
+				return errors.New("`--track` cannot be used for planned steps: the command is not executed")
+			}
 
-			rawCommand := util.JoinCommand(args)
-			sanitized := p.Apply(rawCommand, args)
+			var (
+				rawCommand string
+				sanitized  policy.Result
+			)
+			if useShell {
+				rawCommand = shellScript
+				sanitized = p.ApplyShell(shellScript)
+				args = capture.ShellCommand(shellScript)
+			} else {
+				rawCommand = util.JoinCommand(args)
+				sanitized = p.Apply(rawCommand, args)
+			}
 
 			cwd, err := os.Getwd()
 			if err != nil {
 				return fmt.Errorf("get working directory: %w", err)
 			}
+			// Under enforce_denylist a denied command is blocked even when it
+			// is only planned, so the runbook never lists it as a step to run.
 			if sanitized.Denied && p.EnforceDenylist() {
 				step := store.Step{
 					Timestamp:  time.Now().UTC(),
@@ -225,14 +390,106 @@ func newRunCmd(s store.SessionStore, p *policy.Policy) *cobra.Command {
 					Reason:     "policy_blocked",
 					DurationMS: 0,
 					CWD:        cwd,
+					Shell:      useShell,
 				}
 				if err := s.AddStep(cmd.Context(), step); err != nil {
 					return fmt.Errorf("record blocked step: %w", err)
 				}
-				printWarn(cmd.ErrOrStderr(), "Command blocked by policy denylist. Step recorded as %s.", policy.DeniedPlaceholder)
+				recordAudit(cmd, s, active.ID, step, sanitized)
+				blockedBy := "policy denylist"
+				if p.Mode() == policy.ModeAllowlist {
+					blockedBy = "policy (allowlist mode)"
+				}
+				printWarn(cmd.ErrOrStderr(), "Command blocked by %s. Step recorded as %s.", blockedBy, policy.DeniedPlaceholder)
 				return &ExitError{
 					Code: 2,
-					Err:  errors.New("command blocked by policy denylist"),
+					Err:  fmt.Errorf("command blocked by %s", blockedBy),
+				}
+			}
+
+			if planned {
+				step := store.Step{
+					Timestamp:    time.Now().UTC(),
+					Command:      sanitized.Command,
+					Status:       "PLANNED",
+					DurationMS:   0,
+					CWD:          cwd,
+					Shell:        useShell,
+					ExpectedExit: expectExit,
+					ExpectFail:   expectFail,
+				}
+				if sanitized.Denied {
+					step.Reason = "policy_redacted"
+				}
+				if err := s.AddStep(cmd.Context(), step); err != nil {
+					return fmt.Errorf("record planned step: %w", err)
+				}
+				if sanitized.Denied {
+					recordAudit(cmd, s, active.ID, step, sanitized)
+				}
+				printOK(cmd.OutOrStdout(), "Recorded planned step (not executed)")
+				return nil
+			}
+
+			var (
+				rule    string
+				guarded bool
+			)
+			if useShell {
+				rule, guarded = p.GuardShell(shellScript, active.Env)
+			} else {
+				rule, guarded = p.Guard(args, active.Env)
+			}
+			var guard *store.GuardCheck
+			if guarded {
+				guard = &store.GuardCheck{Rule: rule, Confirmed: true, Via: "yes_flag"}
+				if !yes {
+					confirmed, confirmErr := confirmGuardedCommand(cmd, rule, active.Env, sanitized.Command)
+					if confirmErr != nil && !errors.Is(confirmErr, errGuardConfirmationRequired) {
+						return confirmErr
+					}
+					guard.Confirmed = confirmed
+					guard.Via = "prompt"
+					reason := "guard_declined"
+					if confirmErr != nil {
+						// Nobody was there to answer; do not claim a person declined.
+						guard.Via = "non_interactive"
+						reason = "guard_unconfirmed"
+					}
+					if !confirmed {
+						step := store.Step{
+							Timestamp:  time.Now().UTC(),
+							Command:    sanitized.Command,
+							Status:     "ABORTED",
+							Reason:     reason,
+							DurationMS: 0,
+							CWD:        cwd,
+							Shell:      useShell,
+							Guard:      guard,
+						}
+						if err := s.AddStep(cmd.Context(), step); err != nil {
+							return fmt.Errorf("record aborted step: %w", err)
+						}
+						if confirmErr == nil {
+							confirmErr = errors.New("guarded command was not confirmed")
+						}
+						printWarn(cmd.ErrOrStderr(), "Command aborted. Step recorded as ABORTED (%s).", reason)
+						return &ExitError{
+							Code: 2,
+							Err:  confirmErr,
+						}
+					}
+				}
+			}
+
+			// A denied command is stored without its text, so the files it
+			// touched are not recorded either.
+			track := len(trackPaths) > 0 && !sanitized.Denied
+			var before capture.Snapshot
+			if track {
+				before, err = capture.SnapshotFiles(cwd, trackPaths)
+				if err != nil {
+					return fmt.Errorf("track files: %w", err)
 				}
 			}
 
@@ -245,18 +502,61 @@ func newRunCmd(s store.SessionStore, p *policy.Policy) *cobra.Command {
 				ExitCode:   result.ExitCode,
 				DurationMS: result.Duration.Milliseconds(),
 				CWD:        cwd,
+				Shell:      useShell,
+				Guard:      guard,
+			}
+			expected := expectExit
+			if !expectFail && len(expected) == 0 && !useShell {
+				expected = p.ExpectedExitCodes(args)
+			}
+			if result.ExitCode != nil && (expectFail || len(expected) > 0) {
+				step.Status, step.Reason = capture.ExpectedStatus(*result.ExitCode, expected, expectFail)
+				step.ExpectedExit = expected
+				step.ExpectFail = expectFail
 			}
 			if sanitized.Denied {
 				step.Status = "REDACTED"
 				step.Reason = "policy_redacted"
 			}
+			if track {
+				after, snapErr := capture.SnapshotFiles(cwd, trackPaths)
+				if snapErr != nil {
+					printWarn(cmd.ErrOrStderr(), "Failed to snapshot tracked files after the command (%v).", snapErr)
+				} else {
+					step.Files = toStoreFileChanges(capture.DiffSnapshots(before, after), p)
+				}
+			}
 
 			if err := s.AddStep(cmd.Context(), step); err != nil {
 				return fmt.Errorf("record step: %w", err)
 			}
+			if sanitized.Denied {
+				recordAudit(cmd, s, active.ID, step, sanitized)
+			}
 
+			// A nonzero exit recorded as OK is hidden from the caller only when
+			// --expect-exit or --expect-fail asked for it; under the
+			// capture.expected_exit_codes defaults `run -- grep` still exits
+			// with grep's code, so scripts can test it.
+			var childExit error
+			if step.Status == "OK" && runErr != nil {
+				if !expectFail && len(expectExit) == 0 {
+					childExit = &ExitError{
+						Code: result.CLIExitCode,
+						Err:  fmt.Errorf("command exited with code %d (recorded as OK: expected exit code)", result.CLIExitCode),
+					}
+				}
+				runErr = nil
+			}
+			if runErr == nil && step.Reason == "unexpected_success" {
+				printWarn(cmd.ErrOrStderr(), "Command succeeded but a failure was expected. Step recorded as UNEXPECTED.")
+				return &ExitError{
+					Code: 1,
+					Err:  errors.New("command succeeded but a failure was expected"),
+				}
+			}
 			if runErr != nil {
-				if result.Reason == "command_not_found" && runtime.GOOS == "windows" {
+				if result.Reason == "command_not_found" && runtime.GOOS == "windows" && !useShell {
 					if isWindowsShellBuiltin(args[0]) {
 						printHint(
 							cmd.ErrOrStderr(),
@@ -273,15 +573,46 @@ func newRunCmd(s store.SessionStore, p *policy.Policy) *cobra.Command {
 				}
 			}
 
+			if track {
+				printOK(
+					cmd.OutOrStdout(),
+					"Recorded step (%d ms, exit %s, %d file change(s))",
+					step.DurationMS,
+					formatExitCode(step.ExitCode),
+					len(step.Files),
+				)
+				return childExit
+			}
 			printOK(
 				cmd.OutOrStdout(),
 				"Recorded step (%d ms, exit %s)",
 				step.DurationMS,
 				formatExitCode(step.ExitCode),
 			)
-			return nil
+			return childExit
 		},
 	}
+
+	cmd.Flags().StringVar(&shellScript, "shell", "", "Run a shell script (pipelines, &&, ;) through the user's shell and record it as one step")
+	cmd.Flags().StringSliceVar(&trackPaths, "track", nil, "Record files created, modified or deleted under these paths (repeatable)")
+	cmd.Flags().BoolVar(&yes, "yes", false, "Run guarded commands without the typed confirmation prompt")
+	cmd.Flags().BoolVar(&plan, "plan", false, "Record the step as PLANNED without executing it")
+	cmd.Flags().IntSliceVar(&expectExit, "expect-exit", nil, "Exit codes that count as success (for example: 0,1)")
+	cmd.Flags().BoolVar(&expectFail, "expect-fail", false, "Expect the command to fail; a zero exit is recorded as UNEXPECTED")
+	return cmd
+}
+
+// toStoreFileChanges converts tracked file changes, sanitizing each path with
+// p.
+func toStoreFileChanges(changes []capture.FileChange, p *policy.Policy) []store.FileChange {
+	if len(changes) == 0 {
+		return nil
+	}
+	out := make([]store.FileChange, 0, len(changes))
+	for _, change := range changes {
+		out = append(out, store.FileChange{Path: p.SanitizePath(change.Path), Change: change.Change})
+	}
+	return out
 }
 
 func formatExitCode(code *int) string {
@@ -300,7 +631,7 @@ func isWindowsShellBuiltin(cmd string) bool {
 	}
 }
 
-func newExportCmd(s store.SessionStore) *cobra.Command {
+func newExportCmd(s store.SessionStore, p *policy.Policy) *cobra.Command {
 	var (
 		exportLast bool
 		exportMD   bool
@@ -308,6 +639,7 @@ func newExportCmd(s store.SessionStore) *cobra.Command {
 		sessionID  string
 		annotate   bool
 		noAnnotate bool
+		anonymize  bool
 	)
 
 	cmd := &cobra.Command{
@@ -328,7 +660,7 @@ func newExportCmd(s store.SessionStore) *cobra.Command {
 				exportFmt = "md"
 			}
 			if exportFmt == "" {
-				return errors.New("only markdown export is supported in MVP. Use `--md` or `--format md`")
+				exportFmt = p.Export().Format
 			}
 			if !strings.EqualFold(exportFmt, "md") {
 				return errors.New("unsupported format. MVP supports only markdown (`md`)")
@@ -364,12 +696,29 @@ func newExportCmd(s store.SessionStore) *cobra.Command {
 				return fmt.Errorf("get current directory: %w", err)
 			}
 
+			// The stored session stays as recorded; only the export is rewritten.
+			var anon *export.Anonymizer
+			if anonymize {
+				anon = export.NewAnonymizer(localIdentity())
+				session = anon.Session(session)
+			}
+
 			opts := export.MarkdownOptions{}
 			flagged := collectFlaggedSteps(session)
-			shouldPrompt := (annotate || (isInteractiveSession() && !noAnnotate)) && len(flagged) > 0
+			mode := p.Export().Annotate
+			if annotate {
+				mode = policy.AnnotateAlways
+			} else if noAnnotate {
+				mode = policy.AnnotateNever
+			}
+			shouldPrompt := len(flagged) > 0 &&
+				(mode == policy.AnnotateAlways || (mode == policy.AnnotateAuto && isInteractiveSession()))
 			if shouldPrompt {
 				opts = promptForExportAnnotations(cmd.InOrStdin(), cmd.OutOrStdout(), session)
 			}
+			if anon != nil {
+				opts = anon.Options(opts)
+			}
 
 			var outPath string
 			outPath, err = export.WriteMarkdownWithOptions(session, workingDir, opts)
@@ -378,6 +727,9 @@ func newExportCmd(s store.SessionStore) *cobra.Command {
 			}
 
 			printOK(cmd.OutOrStdout(), "Exported runbook: %s", outPath)
+			if anon != nil {
+				printPseudonyms(cmd.OutOrStdout(), anon.Mapping())
+			}
 			return nil
 		},
 	}
@@ -385,18 +737,19 @@ func newExportCmd(s store.SessionStore) *cobra.Command {
 	cmd.Flags().BoolVarP(&exportLast, "last", "l", false, "Export the most recent completed session")
 	cmd.Flags().StringVar(&sessionID, "session", "", "Export a specific completed session by id")
 	cmd.Flags().BoolVar(&exportMD, "md", false, "Export markdown output")
-	cmd.Flags().StringVarP(&exportFmt, "format", "f", "", "Export format (MVP: md)")
+	cmd.Flags().StringVarP(&exportFmt, "format", "f", "", "Export format (MVP: md; default: export.format from config)")
 	cmd.Flags().BoolVar(&annotate, "annotate", false, "Prompt for export comments on failed/redacted steps")
 	cmd.Flags().BoolVar(&noAnnotate, "no-annotate", false, "Skip export comment prompt")
+	cmd.Flags().BoolVar(&anonymize, "anonymize", false, "Replace hostnames, IPs, emails and the local username with pseudonyms")
 	return cmd
 }
 
-func newSessionsCmd(s store.SessionStore) *cobra.Command {
+func newSessionsCmd(s store.SessionStore, policies *policy.Resolver) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "sessions",
 		Short: "Inspect completed sessions",
 	}
-	cmd.AddCommand(newSessionsListCmd(s))
+	cmd.AddCommand(newSessionsListCmd(s), newSessionsResanitizeCmd(s, policies))
 	return cmd
 }
 
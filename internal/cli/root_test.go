@@ -2,11 +2,17 @@ package cli
 
 import (
 	"bytes"
+	"context"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"strings"
 	"testing"
+
+	"github.com/fixi2/Commandry/internal/hooks"
+	"github.com/fixi2/Commandry/internal/policy"
+	"github.com/fixi2/Commandry/internal/store"
 )
 
 func TestCommandAliases(t *testing.T) {
@@ -106,6 +112,14 @@ func TestHooksCommandsExist(t *testing.T) {
 	if hooksInstallZsh == nil || hooksInstallZsh.Name() != "zsh" {
 		t.Fatalf("hooks install zsh command not found")
 	}
+
+	hooksInstallFish, _, err := root.Find([]string{"hooks", "install", "fish"})
+	if err != nil {
+		t.Fatalf("root.Find(hooks install fish) failed: %v", err)
+	}
+	if hooksInstallFish == nil || hooksInstallFish.Name() != "fish" {
+		t.Fatalf("hooks install fish command not found")
+	}
 }
 
 func TestAliasCommandOutput(t *testing.T) {
@@ -191,6 +205,9 @@ func TestShortFlags(t *testing.T) {
 	if exportCmd.Flags().Lookup("no-annotate") == nil {
 		t.Fatalf("flag --no-annotate is not configured for export")
 	}
+	if exportCmd.Flags().Lookup("anonymize") == nil {
+		t.Fatalf("flag --anonymize is not configured for export")
+	}
 	if root.PersistentFlags().Lookup("no-color") == nil {
 		t.Fatalf("persistent flag --no-color is not configured")
 	}
@@ -314,6 +331,506 @@ func TestRunBlockedByEnforcedDenylist(t *testing.T) {
 	}
 }
 
+func TestRunUsesPolicyProfileForSessionEnv(t *testing.T) {
+	configRoot := setupCLIEnv(t)
+	mustExecuteCLI(t, "init")
+	cfg := strings.Join([]string{
+		"policy:",
+		"  profiles:",
+		"    Prod:",
+		"      denylist: [\"echo blocked\"]",
+		"      enforce_denylist: true",
+		"",
+	}, "\n")
+	if err := os.WriteFile(filepath.Join(configRoot, "config.yaml"), []byte(cfg), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	command := []string{"run", "--", "sh", "-c", "echo blocked"}
+	if runtime.GOOS == "windows" {
+		command = []string{"run", "--", "cmd", "/c", "echo blocked"}
+	}
+
+	mustExecuteCLI(t, "start", "sandbox", "--env", "sandbox")
+	mustExecuteCLI(t, command...)
+	mustExecuteCLI(t, "stop")
+
+	mustExecuteCLI(t, "start", "release", "--env", "prod")
+	if out := mustExecuteCLI(t, "status"); !strings.Contains(out, "Policy profile: Prod\n") {
+		t.Fatalf("expected status to name the profile, got %q", out)
+	}
+	_, err := executeCLI(t, command...)
+	var exitErr *ExitError
+	if !asExitErrorCLI(err, &exitErr) || exitErr.Code != 2 {
+		t.Fatalf("expected ExitError code 2 in the prod profile, got err=%v", err)
+	}
+	if out := mustExecuteCLI(t, "policy", "test", "--env", "", "--", "sh", "-c", "echo blocked"); !strings.Contains(out, "Not denied") {
+		t.Fatalf("expected --env to override the session env, got %q", out)
+	}
+}
+
+func TestInvalidConfigDisablesRecording(t *testing.T) {
+	configRoot := setupCLIEnv(t)
+	mustExecuteCLI(t, "init")
+	cfg := "policy:\n  denylist: [\"echo blocked\"]\n  enforce_denylist: true\nexport:\n  anotate: never\n"
+	if err := os.WriteFile(filepath.Join(configRoot, "config.yaml"), []byte(cfg), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	mustExecuteCLI(t, "start", "typo")
+
+	command := []string{"run", "--", "sh", "-c", "echo blocked"}
+	if runtime.GOOS == "windows" {
+		command = []string{"run", "--", "cmd", "/c", "echo blocked"}
+	}
+	for _, args := range [][]string{command, {"hook", "record", "--command", "echo blocked"}} {
+		out, err := executeCLI(t, args...)
+		if err == nil || !strings.Contains(err.Error(), "export.anotate") {
+			t.Fatalf("expected %v to refuse the invalid config, got err=%v\n%s", args, err, out)
+		}
+	}
+	active, err := store.NewJSONStore(configRoot).GetActiveSession(context.Background())
+	if err != nil {
+		t.Fatalf("read active session: %v", err)
+	}
+	if len(active.Steps) != 0 {
+		t.Fatalf("expected nothing recorded with an invalid config, got %+v", active.Steps)
+	}
+
+	// Once the typo is fixed the denylist is enforced again.
+	if err := os.WriteFile(filepath.Join(configRoot, "config.yaml"), []byte(strings.Replace(cfg, "anotate", "annotate", 1)), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	_, err = executeCLI(t, command...)
+	var exitErr *ExitError
+	if !asExitErrorCLI(err, &exitErr) || exitErr.Code != 2 {
+		t.Fatalf("expected ExitError code 2 from the enforced denylist, got err=%v", err)
+	}
+}
+
+func TestSessionsResanitizeAppliesNewRules(t *testing.T) {
+	configRoot := setupCLIEnv(t)
+	mustExecuteCLI(t, "init")
+	mustExecuteCLI(t, "start", "clean")
+	mustExecuteCLI(t, "run", "--plan", "--", "echo", "hello")
+	mustExecuteCLI(t, "stop")
+	mustExecuteCLI(t, "start", "leak")
+	mustExecuteCLI(t, "run", "--plan", "--", "deploy", "--key", "sk_live_abc123")
+	mustExecuteCLI(t, "run", "--plan", "--", "echo", "--token=abc")
+	mustExecuteCLI(t, "stop")
+
+	sessionStore := store.NewJSONStore(configRoot)
+	before, err := sessionStore.LastSession(context.Background())
+	if err != nil {
+		t.Fatalf("read last session: %v", err)
+	}
+	if before.PolicyFingerprint == "" {
+		t.Fatalf("expected start to record a policy fingerprint")
+	}
+
+	cfg := "policy:\n  redaction_rules:\n    - name: stripe\n      pattern: 'sk_live_\\w+'\n"
+	if err := os.WriteFile(filepath.Join(configRoot, "config.yaml"), []byte(cfg), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if _, err := executeCLI(t, "sessions", "resanitize"); err == nil {
+		t.Fatalf("expected an error without --all or an id")
+	}
+	if _, err := executeCLI(t, "sessions", "resanitize", "nope"); err == nil || !strings.Contains(err.Error(), `session "nope" not found`) {
+		t.Fatalf("expected unknown id error, got %v", err)
+	}
+
+	out := mustExecuteCLI(t, "sessions", "resanitize", "--all")
+	if !strings.Contains(out, "step 1: deploy --key [REDACTED]\n") || !strings.Contains(out, "Re-sanitized 1 step(s) in 1 session(s)") || strings.Contains(out, "sk_live") {
+		t.Fatalf("unexpected resanitize output:\n%s", out)
+	}
+	after, err := sessionStore.SessionByID(context.Background(), before.ID)
+	if err != nil {
+		t.Fatalf("read session: %v", err)
+	}
+	if after.Steps[0].Command != "deploy --key [REDACTED]" || after.Steps[1].Command != before.Steps[1].Command {
+		t.Fatalf("unexpected stored steps: %+v", after.Steps)
+	}
+	if after.PolicyFingerprint == before.PolicyFingerprint || after.PolicyFingerprint == "" {
+		t.Fatalf("expected the fingerprint to follow the new policy, got %q", after.PolicyFingerprint)
+	}
+	sessions, err := sessionStore.ListSessions(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("list sessions: %v", err)
+	}
+	for _, session := range sessions {
+		if session.Title == "clean" && session.PolicyFingerprint != before.PolicyFingerprint {
+			t.Fatalf("expected an unchanged session to keep its fingerprint, got %q", session.PolicyFingerprint)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(configRoot, "sessions.jsonl"))
+	if err != nil || strings.Contains(string(data), "sk_live") {
+		t.Fatalf("expected the secret to be gone from sessions.jsonl (err=%v)", err)
+	}
+
+	if out := mustExecuteCLI(t, "sessions", "resanitize", before.ID); !strings.Contains(out, "1 session(s) were already sanitized with the current policy") {
+		t.Fatalf("expected the session to be up to date, got:\n%s", out)
+	}
+}
+
+func TestResanitizeStepTreatsHookLinesAsShell(t *testing.T) {
+	t.Parallel()
+
+	p := policy.NewDefault()
+	line := "cd /srv && mysql -u root -pHunter2x db"
+
+	hook := store.Step{Command: line, Status: "OK", Source: "hook"}
+	if !resanitizeStep(&hook, p) || hook.Command != "cd /srv && mysql -u root -p[REDACTED] db" {
+		t.Fatalf("expected the hook line to be sanitized per segment, got %q", hook.Command)
+	}
+	run := store.Step{Command: line, Status: "OK"}
+	if resanitizeStep(&run, p) {
+		t.Fatalf("expected a run step to be sanitized as one argv, got %q", run.Command)
+	}
+}
+
+func TestKeepsRedactions(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		old     string
+		updated string
+		want    bool
+	}{
+		{"deploy --key sk_live_abc", "deploy --key [REDACTED]", true},
+		{"deploy --key [REDACTED] --user bob", "deploy --key [REDACTED] --user [REDACTED]", true},
+		{"login [REDACTED] now", "[REDACTED] now", true},
+		// The marker moved from the secret to a harmless token.
+		{"deploy --key [REDACTED] --name api", "deploy --key *** --name [REDACTED]", false},
+		{"deploy [REDACTED] [REDACTED]", "deploy [REDACTED] x", false},
+		{"deploy --key [REDACTED]", "deploy --key [REDACTED] --extra", false},
+	} {
+		if got := keepsRedactions(tc.old, tc.updated); got != tc.want {
+			t.Fatalf("keepsRedactions(%q, %q) = %v, want %v", tc.old, tc.updated, got, tc.want)
+		}
+	}
+}
+
+func TestRunShellRecordsPipeline(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("posix shell pipeline test")
+	}
+	configRoot := setupCLIEnv(t)
+	t.Setenv("SHELL", "/bin/sh")
+
+	mustExecuteCLI(t, "init")
+	mustExecuteCLI(t, "start", "shell-pipeline")
+	mustExecuteCLI(t, "run", "--shell", "echo --token=abc | cat")
+
+	active, err := store.NewJSONStore(configRoot).GetActiveSession(context.Background())
+	if err != nil {
+		t.Fatalf("read active session: %v", err)
+	}
+	if len(active.Steps) != 1 {
+		t.Fatalf("expected 1 step, got %d", len(active.Steps))
+	}
+	step := active.Steps[0]
+	if step.Command != "echo --token=[REDACTED] | cat" {
+		t.Fatalf("unexpected recorded command: %q", step.Command)
+	}
+	if !step.Shell || step.Status != "OK" {
+		t.Fatalf("unexpected shell step: %+v", step)
+	}
+
+	if _, err := executeCLI(t, "run", "--shell", "echo hi", "--", "echo", "hi"); err == nil {
+		t.Fatalf("expected error when combining --shell with positional command")
+	}
+}
+
+func TestRunGuardedCommandRequiresConfirmation(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("posix shell guard test")
+	}
+	configRoot := setupCLIEnv(t)
+
+	mustExecuteCLI(t, "init")
+	cfg := strings.Join([]string{
+		"policy:",
+		"  guarded:",
+		"    - pattern: echo guarded*",
+		"      envs: [prod]",
+		"",
+	}, "\n")
+	if err := os.WriteFile(filepath.Join(configRoot, "config.yaml"), []byte(cfg), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	mustExecuteCLI(t, "start", "guarded", "--env", "prod")
+
+	_, err := executeCLI(t, "run", "--", "sh", "-c", "echo guarded-noinput")
+	var exitErr *ExitError
+	if err == nil || !asExitErrorCLI(err, &exitErr) || exitErr.Code != 2 {
+		t.Fatalf("expected ExitError code 2 without confirmation, got err=%v", err)
+	}
+	if _, err := executeCLIWithInput(t, "no\n", "run", "--", "sh", "-c", "echo guarded-declined"); err == nil {
+		t.Fatalf("expected declined confirmation to abort")
+	}
+	if out, err := executeCLIWithInput(t, "yes\n", "run", "--", "sh", "-c", "echo guarded-typed"); err != nil {
+		t.Fatalf("expected typed confirmation to run command: %v\n%s", err, out)
+	}
+	mustExecuteCLI(t, "run", "--yes", "--", "sh", "-c", "echo guarded-flag")
+	mustExecuteCLI(t, "run", "--yes", "--shell", "true && echo guarded-shell")
+	mustExecuteCLI(t, "run", "--", "sh", "-c", "echo unguarded")
+	mustExecuteCLI(t, "run", "--", "printf", "%s\n", "echo guarded-argument")
+
+	active, err := store.NewJSONStore(configRoot).GetActiveSession(context.Background())
+	if err != nil {
+		t.Fatalf("read active session: %v", err)
+	}
+	if len(active.Steps) != 7 {
+		t.Fatalf("expected 7 steps, got %d", len(active.Steps))
+	}
+	for i, want := range []struct {
+		status    string
+		reason    string
+		confirmed bool
+		via       string
+	}{
+		{status: "ABORTED", reason: "guard_unconfirmed", via: "non_interactive"},
+		{status: "ABORTED", reason: "guard_declined", via: "prompt"},
+		{status: "OK", confirmed: true, via: "prompt"},
+		{status: "OK", confirmed: true, via: "yes_flag"},
+		{status: "OK", confirmed: true, via: "yes_flag"},
+	} {
+		step := active.Steps[i]
+		if step.Status != want.status || step.Reason != want.reason || step.Guard == nil ||
+			step.Guard.Confirmed != want.confirmed || step.Guard.Via != want.via || step.Guard.Rule != "echo guarded*" {
+			t.Fatalf("step %d mismatch: %+v guard=%+v", i, step, step.Guard)
+		}
+	}
+	for _, step := range active.Steps[5:] {
+		if step.Guard != nil {
+			t.Fatalf("expected unguarded command %q to have no guard record", step.Command)
+		}
+	}
+}
+
+func TestRunDeniedStepOmitsTrackedFiles(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	configRoot := setupCLIEnv(t)
+	dir := t.TempDir()
+
+	mustExecuteCLI(t, "init")
+	mustExecuteCLI(t, "start", "track")
+	mustExecuteCLI(t, "run", "--track", dir, "--", "sh", "-c", "echo x > "+filepath.Join(dir, "server.key"))
+
+	active, err := store.NewJSONStore(configRoot).GetActiveSession(context.Background())
+	if err != nil {
+		t.Fatalf("read active session: %v", err)
+	}
+	if step := active.Steps[0]; step.Status != "REDACTED" || len(step.Files) != 0 {
+		t.Fatalf("expected a denied step without tracked files, got %+v", step)
+	}
+}
+
+func TestRunSanitizesTrackedFilePaths(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses touch")
+	}
+	configRoot := setupCLIEnv(t)
+	dir := t.TempDir()
+
+	mustExecuteCLI(t, "init")
+	mustExecuteCLI(t, "start", "track")
+	mustExecuteCLI(t, "run", "--track", dir, "--", "touch", filepath.Join(dir, "prod-db-password.txt"))
+
+	active, err := store.NewJSONStore(configRoot).GetActiveSession(context.Background())
+	if err != nil {
+		t.Fatalf("read active session: %v", err)
+	}
+	files := active.Steps[0].Files
+	if len(files) != 1 || strings.Contains(files[0].Path, "password") || !strings.HasSuffix(files[0].Path, "[REDACTED]") {
+		t.Fatalf("expected the tracked path to be sanitized, got %+v", files)
+	}
+}
+
+func TestRunPlanRecordsWithoutExecuting(t *testing.T) {
+	configRoot := setupCLIEnv(t)
+	marker := filepath.Join(t.TempDir(), "executed")
+
+	mustExecuteCLI(t, "init")
+	mustExecuteCLI(t, "start", "plan")
+	mustExecuteCLI(t, "run", "--plan", "--", "touch", marker)
+	if _, err := executeCLI(t, "run", "--plan", "--track", ".", "--", "touch", marker); err == nil {
+		t.Fatalf("expected --track to be rejected for planned steps")
+	}
+	mustExecuteCLI(t, "stop")
+
+	mustExecuteCLI(t, "start", "plan session", "--plan")
+	mustExecuteCLI(t, "run", "--", "touch", marker)
+
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Fatalf("planned command must not be executed, stat err=%v", err)
+	}
+
+	s := store.NewJSONStore(configRoot)
+	active, err := s.GetActiveSession(context.Background())
+	if err != nil {
+		t.Fatalf("read active session: %v", err)
+	}
+	if !active.Plan || len(active.Steps) != 1 || active.Steps[0].Status != "PLANNED" {
+		t.Fatalf("expected plan session with one PLANNED step, got %+v", active)
+	}
+	last, err := s.LastSession(context.Background())
+	if err != nil {
+		t.Fatalf("read last session: %v", err)
+	}
+	if len(last.Steps) != 1 || last.Steps[0].Status != "PLANNED" || last.Steps[0].ExitCode != nil {
+		t.Fatalf("expected run --plan to record one PLANNED step, got %+v", last.Steps)
+	}
+}
+
+func TestRunPlanEnforcesDenylist(t *testing.T) {
+	configRoot := setupCLIEnv(t)
+	mustExecuteCLI(t, "init")
+	cfg := strings.Join([]string{
+		"policy:",
+		"  denylist: [\"echo blocked\"]",
+		"  enforce_denylist: true",
+		"",
+	}, "\n")
+	if err := os.WriteFile(filepath.Join(configRoot, "config.yaml"), []byte(cfg), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	mustExecuteCLI(t, "start", "plan", "--plan")
+	_, err := executeCLI(t, "run", "--", "echo", "blocked")
+	var exitErr *ExitError
+	if err == nil || !asExitErrorCLI(err, &exitErr) || exitErr.Code != 2 {
+		t.Fatalf("expected planned denied command to be blocked with code 2, got err=%v", err)
+	}
+	if _, err := executeCLI(t, "run", "--plan", "--shell", "true && echo blocked"); err == nil {
+		t.Fatalf("expected planned denied shell line to be blocked")
+	}
+
+	active, err := store.NewJSONStore(configRoot).GetActiveSession(context.Background())
+	if err != nil {
+		t.Fatalf("read active session: %v", err)
+	}
+	if len(active.Steps) != 2 {
+		t.Fatalf("expected 2 steps, got %d", len(active.Steps))
+	}
+	for i, step := range active.Steps {
+		if step.Status != "REDACTED" || step.Reason != "policy_blocked" || step.Command != policy.DeniedPlaceholder {
+			t.Fatalf("step %d: expected blocked placeholder, got %+v", i, step)
+		}
+	}
+}
+
+func TestRunExpectedExitCodes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("posix shell expectation test")
+	}
+	configRoot := setupCLIEnv(t)
+
+	mustExecuteCLI(t, "init")
+	mustExecuteCLI(t, "start", "probes")
+	mustExecuteCLI(t, "run", "--expect-exit", "0,3", "--", "sh", "-c", "exit 3")
+	mustExecuteCLI(t, "run", "--expect-fail", "--", "sh", "-c", "exit 4")
+	if _, err := executeCLI(t, "run", "--expect-fail", "--", "sh", "-c", "exit 0"); err == nil {
+		t.Fatalf("expected unexpected success to fail the run")
+	}
+	if _, err := executeCLI(t, "run", "--expect-exit", "0", "--", "sh", "-c", "exit 5"); err == nil {
+		t.Fatalf("expected unlisted exit code to fail the run")
+	}
+	if _, err := executeCLI(t, "run", "--expect-exit", "1", "--expect-fail", "--", "true"); err == nil {
+		t.Fatalf("expected --expect-exit with --expect-fail to be rejected")
+	}
+	// The grep default records exit 1 as OK but still returns it.
+	haystack := filepath.Join(t.TempDir(), "haystack")
+	if err := os.WriteFile(haystack, []byte("hay\n"), 0o600); err != nil {
+		t.Fatalf("write haystack: %v", err)
+	}
+	_, err := executeCLI(t, "run", "--", "grep", "needle", haystack)
+	var exitErr *ExitError
+	if err == nil || !asExitErrorCLI(err, &exitErr) || exitErr.Code != 1 {
+		t.Fatalf("expected grep's exit code 1 to be returned, got err=%v", err)
+	}
+
+	active, err := store.NewJSONStore(configRoot).GetActiveSession(context.Background())
+	if err != nil {
+		t.Fatalf("read active session: %v", err)
+	}
+	want := []struct{ status, reason string }{
+		{"OK", ""},
+		{"OK", ""},
+		{"UNEXPECTED", "unexpected_success"},
+		{"UNEXPECTED", "unexpected_exit"},
+		{"OK", ""},
+	}
+	if len(active.Steps) != len(want) {
+		t.Fatalf("expected %d steps, got %d", len(want), len(active.Steps))
+	}
+	for i, w := range want {
+		if got := active.Steps[i]; got.Status != w.status || got.Reason != w.reason {
+			t.Fatalf("step %d: got %s (%s), want %s (%s)", i, got.Status, got.Reason, w.status, w.reason)
+		}
+	}
+	if !reflect.DeepEqual(active.Steps[0].ExpectedExit, []int{0, 3}) || !active.Steps[1].ExpectFail {
+		t.Fatalf("expectations not stored on steps: %+v", active.Steps[:2])
+	}
+}
+
+// setupCLIEnv points the config root and home directory at a temp dir and
+// returns the Commandry config root used by NewRootCommand.
+func setupCLIEnv(t *testing.T) string {
+	t.Helper()
+
+	rootBase := t.TempDir()
+	appData := filepath.Join(rootBase, "appdata")
+	home := filepath.Join(rootBase, "home")
+	for _, dir := range []string{appData, home} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+	}
+	t.Setenv("APPDATA", appData)
+	t.Setenv("XDG_CONFIG_HOME", appData)
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("COMMANDRY_HOME_DIR", home)
+
+	configRoot, err := store.DefaultRootDir()
+	if err != nil {
+		t.Fatalf("resolve config root: %v", err)
+	}
+	return configRoot
+}
+
+func executeCLI(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	return executeCLIWithInput(t, "", args...)
+}
+
+func executeCLIWithInput(t *testing.T, input string, args ...string) (string, error) {
+	t.Helper()
+
+	root, err := NewRootCommand()
+	if err != nil {
+		t.Fatalf("NewRootCommand failed: %v", err)
+	}
+	var out bytes.Buffer
+	root.SetOut(&out)
+	root.SetErr(&out)
+	root.SetIn(strings.NewReader(input))
+	root.SetArgs(args)
+	err = root.Execute()
+	return out.String(), err
+}
+
+func mustExecuteCLI(t *testing.T, args ...string) string {
+	t.Helper()
+
+	out, err := executeCLI(t, args...)
+	if err != nil {
+		t.Fatalf("%v failed: %v\n%s", args, err, out)
+	}
+	return out
+}
+
 func asExitErrorCLI(err error, target **ExitError) bool {
 	e, ok := err.(*ExitError)
 	if !ok {
@@ -322,3 +839,87 @@ func asExitErrorCLI(err error, target **ExitError) bool {
 	*target = e
 	return true
 }
+
+func TestHookRecordDebugExplainsSkips(t *testing.T) {
+	setupCLIEnv(t)
+	mustExecuteCLI(t, "init")
+	mustExecuteCLI(t, "hooks", "enable")
+	mustExecuteCLI(t, "start", "hook-debug")
+
+	out := mustExecuteCLI(t, "hook", "record", "--command", "git status", "--debug")
+	if !strings.Contains(out, "skipped: ignored_command (rule: git status*)") {
+		t.Fatalf("expected ignore explanation, got %q", out)
+	}
+	out = mustExecuteCLI(t, "hook", "record", "--command", "terrafrom plan", "--exit-code", "127", "--debug")
+	if !strings.Contains(out, "skipped: command_not_found") {
+		t.Fatalf("expected command-not-found explanation, got %q", out)
+	}
+	out = mustExecuteCLI(t, "hook", "record", "--command", "make deploy", "--exit-code", "2", "--debug")
+	if !strings.Contains(out, "recorded: make deploy (FAILED)") {
+		t.Fatalf("expected recorded explanation, got %q", out)
+	}
+	out = mustExecuteCLI(t, "hook", "record", "--command", "ls")
+	if strings.TrimSpace(out) != "" {
+		t.Fatalf("expected silent output without --debug, got %q", out)
+	}
+}
+
+func TestStartScopeAndThisTerminalLimitHookRecording(t *testing.T) {
+	configRoot := setupCLIEnv(t)
+	mustExecuteCLI(t, "init")
+	mustExecuteCLI(t, "hooks", "enable")
+
+	project := t.TempDir()
+	t.Setenv(hooks.ShellTokenEnv, "")
+	if out, err := executeCLI(t, "start", "scoped", "--this-terminal"); err == nil || !strings.Contains(err.Error(), hooks.ShellTokenEnv) {
+		t.Fatalf("expected missing token error, got %v\n%s", err, out)
+	}
+	if _, err := executeCLI(t, "start", "scoped", "--scope", filepath.Join(project, "missing")); err == nil {
+		t.Fatal("expected error for missing scope directory")
+	}
+
+	t.Setenv(hooks.ShellTokenEnv, "100-200")
+	out := mustExecuteCLI(t, "start", "scoped", "--scope", project, "--this-terminal")
+	if !strings.Contains(out, "inside "+project) || !strings.Contains(out, "from this terminal") {
+		t.Fatalf("expected scope hints, got %q", out)
+	}
+	out = mustExecuteCLI(t, "status")
+	if !strings.Contains(out, "Scope: "+project) || !strings.Contains(out, "(this terminal)") {
+		t.Fatalf("expected scope in status, got %q", out)
+	}
+
+	out = mustExecuteCLI(t, "hook", "record", "--command", "make build", "--cwd", configRoot, "--debug")
+	if !strings.Contains(out, "skipped: out_of_scope") {
+		t.Fatalf("expected out_of_scope skip, got %q", out)
+	}
+	out = mustExecuteCLI(t, "hook", "record", "--command", "make build", "--cwd", project, "--shell-token", "300-400", "--debug")
+	if !strings.Contains(out, "skipped: other_terminal") {
+		t.Fatalf("expected other_terminal skip, got %q", out)
+	}
+	out = mustExecuteCLI(t, "hook", "record", "--command", "make build", "--cwd", project, "--debug")
+	if !strings.Contains(out, "recorded: make build") {
+		t.Fatalf("expected step recorded from this terminal, got %q", out)
+	}
+}
+
+func TestStartUsesConfiguredProjectRoot(t *testing.T) {
+	configRoot := setupCLIEnv(t)
+	mustExecuteCLI(t, "init")
+
+	project := t.TempDir()
+	config := "hooks:\n  project_root: " + project + "\n"
+	if err := os.WriteFile(filepath.Join(configRoot, "config.yaml"), []byte(config), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	out := mustExecuteCLI(t, "start", "configured")
+	if !strings.Contains(out, "inside "+project) {
+		t.Fatalf("expected configured scope, got %q", out)
+	}
+	mustExecuteCLI(t, "stop")
+
+	out = mustExecuteCLI(t, "start", "anywhere", "--no-scope")
+	if strings.Contains(out, "Hooks record only") {
+		t.Fatalf("expected --no-scope to drop the configured scope, got %q", out)
+	}
+}
@@ -0,0 +1,306 @@
+package export
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/fixi2/Commandry/internal/store"
+)
+
+// Pseudonym kinds, in the order overlapping matches are resolved.
+const (
+	PseudonymEmail = "email"
+	PseudonymHost  = "host"
+	PseudonymIP    = "ip"
+	PseudonymUser  = "user"
+)
+
+// Pseudonym is one value an Anonymizer replaced.
+type Pseudonym struct {
+	Kind        string
+	Original    string
+	Replacement string
+}
+
+// commonTLDs are the top-level domains a bare two-label name such as
+// `acme.com` needs to count as a host; longer names need no known TLD.
+var commonTLDs = map[string]bool{
+	"com": true, "net": true, "org": true, "io": true, "dev": true, "app": true, "cloud": true,
+	"co": true, "ai": true, "biz": true, "info": true, "us": true, "uk": true, "de": true, "eu": true,
+}
+
+// fileExtensions keep file names such as `values.prod.yaml` from being taken
+// for hostnames.
+var fileExtensions = map[string]bool{
+	"bak": true, "bat": true, "cfg": true, "conf": true, "crt": true, "csv": true, "db": true,
+	"env": true, "gz": true, "hcl": true, "html": true, "ini": true, "js": true, "json": true,
+	"key": true, "lock": true, "log": true, "md": true, "mod": true, "pem": true, "ps": true,
+	"py": true, "rb": true, "rs": true, "service": true, "sh": true, "sql": true, "sum": true,
+	"tar": true, "tf": true, "tfvars": true, "tgz": true, "toml": true, "ts": true, "txt": true,
+	"xml": true, "yaml": true, "yml": true, "zip": true, "go": true, "java": true, "ps1": true,
+}
+
+var (
+	emailAddress = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}\b`)
+	urlHost      = regexp.MustCompile(`(?i)\b[a-z][a-z0-9+.\-]*://(?:[^/\s@]*@)?(?:\[([0-9a-f:.]+)\]|([^/\s:?#'"\[]+))`)
+	sshHost      = regexp.MustCompile(`(?:^|[\s'"])[A-Za-z0-9._\-]+@([A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*)`)
+	hostFlag     = regexp.MustCompile(`(?:^|\s)--(?:host|hostname|server)(?:=|\s+)['"]?([^\s'"]+)`)
+	dbClientHost = regexp.MustCompile(`\b(?:psql|pg_dump|pg_restore|mysql|mysqldump|mariadb|redis-cli)\b.*?\s-h\s*['"]?([^\s'"]+)`)
+	internalHost = regexp.MustCompile(`(?i)\b[a-z0-9][a-z0-9\-]*(?:\.[a-z0-9\-]+)*\.(?:internal|local|localdomain|lan|corp|intranet|svc)\b`)
+	dottedHost   = regexp.MustCompile(`(?i)(?:^|[^A-Za-z0-9_.\-/])((?:[a-z0-9](?:[a-z0-9\-]*[a-z0-9])?\.)+([a-z]{2,63}))`)
+	ipv4Address  = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+	ipv6Address  = regexp.MustCompile(`(?i)[0-9a-f]{0,4}(?::[0-9a-f]{0,4}){2,7}`)
+)
+
+// Anonymizer replaces hostnames, IP addresses, email addresses and the local
+// username with pseudonyms such as `host-1`, `192.0.2.1`, `user-1@example.com`
+// and `user`. The same value always gets the same pseudonym, so one
+// Anonymizer per export keeps a runbook readable.
+//
+// IP pseudonyms come from the documentation ranges of RFC 5737 and RFC 3849.
+// Loopback and unspecified addresses and `localhost` are kept.
+type Anonymizer struct {
+	username *regexp.Regexp
+	userAt   *regexp.Regexp
+	hostname *regexp.Regexp
+	names    map[string]string
+	counts   map[string]int
+	mapping  []Pseudonym
+}
+
+// NewAnonymizer returns an Anonymizer that also replaces username where it is
+// a path element, as in /home/<username>, or the user of a `<username>@host`
+// login, and the local hostname wherever it appears as a whole word. Either
+// may be empty.
+func NewAnonymizer(username, hostname string) *Anonymizer {
+	return &Anonymizer{
+		username: wordPattern(`[/\\]`, username, `[/\\\s'"]`),
+		userAt:   loginPattern(username),
+		hostname: wordPattern(`[^A-Za-z0-9_.\-]`, hostname, `[^A-Za-z0-9_\-]`),
+		names:    make(map[string]string),
+		counts:   make(map[string]int),
+	}
+}
+
+// Session returns an anonymized copy of session. Commands, the title,
+// working directories, the hook scope and changed file paths are rewritten;
+// session itself is left untouched.
+func (a *Anonymizer) Session(session *store.Session) *store.Session {
+	out := *session
+	out.Title = a.Text(session.Title)
+	out.Scope = a.Text(session.Scope)
+	out.StartDir = a.Text(session.StartDir)
+	out.Steps = make([]store.Step, len(session.Steps))
+	for i, step := range session.Steps {
+		step.Command = a.Text(step.Command)
+		step.CWD = a.Text(step.CWD)
+		if len(step.Files) > 0 {
+			files := make([]store.FileChange, len(step.Files))
+			for j, file := range step.Files {
+				file.Path = a.Text(file.Path)
+				files[j] = file
+			}
+			step.Files = files
+		}
+		out.Steps[i] = step
+	}
+	return &out
+}
+
+// Options anonymizes reviewer comments.
+func (a *Anonymizer) Options(opts MarkdownOptions) MarkdownOptions {
+	out := MarkdownOptions{GlobalComments: a.texts(opts.GlobalComments)}
+	if opts.StepComments != nil {
+		out.StepComments = make(map[int][]string, len(opts.StepComments))
+		for step, comments := range opts.StepComments {
+			out.StepComments[step] = a.texts(comments)
+		}
+	}
+	return out
+}
+
+func (a *Anonymizer) texts(in []string) []string {
+	if in == nil {
+		return nil
+	}
+	out := make([]string, len(in))
+	for i, s := range in {
+		out[i] = a.Text(s)
+	}
+	return out
+}
+
+// Mapping lists every replacement made so far, in order of first use.
+func (a *Anonymizer) Mapping() []Pseudonym {
+	return append([]Pseudonym(nil), a.mapping...)
+}
+
+type anonSpan struct {
+	start, end int
+	kind       string
+}
+
+// Text anonymizes s. Overlapping matches go to the earlier kind, so the host
+// of an email address is not counted as a host on its own.
+func (a *Anonymizer) Text(s string) string {
+	if s == "" {
+		return s
+	}
+	var spans []anonSpan
+	add := func(kind string, locs [][]int, group int) {
+		for _, loc := range locs {
+			start, end := loc[2*group], loc[2*group+1]
+			if start < 0 || start >= end || !a.hides(kind, s[start:end]) {
+				continue
+			}
+			spans = append(spans, anonSpan{start: start, end: end, kind: kind})
+		}
+	}
+	add(PseudonymEmail, emailAddress.FindAllStringSubmatchIndex(s, -1), 0)
+	add(PseudonymIP, urlHost.FindAllStringSubmatchIndex(s, -1), 1)
+	add(PseudonymHost, urlHost.FindAllStringSubmatchIndex(s, -1), 2)
+	for _, re := range []*regexp.Regexp{sshHost, hostFlag, dbClientHost} {
+		add(PseudonymHost, re.FindAllStringSubmatchIndex(s, -1), 1)
+	}
+	add(PseudonymHost, internalHost.FindAllStringSubmatchIndex(s, -1), 0)
+	if a.hostname != nil {
+		add(PseudonymHost, a.hostname.FindAllStringSubmatchIndex(s, -1), 1)
+	}
+	add(PseudonymHost, dottedHosts(s), 1)
+	add(PseudonymIP, ipv4Address.FindAllStringSubmatchIndex(s, -1), 0)
+	add(PseudonymIP, ipv6Address.FindAllStringSubmatchIndex(s, -1), 0)
+	if a.username != nil {
+		add(PseudonymUser, a.username.FindAllStringSubmatchIndex(s, -1), 1)
+		add(PseudonymUser, a.userAt.FindAllStringSubmatchIndex(s, -1), 1)
+	}
+
+	// Earlier kinds win; spans were appended in priority order.
+	kept := make([]anonSpan, 0, len(spans))
+	for _, span := range spans {
+		overlaps := false
+		for _, k := range kept {
+			if span.start < k.end && k.start < span.end {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			kept = append(kept, span)
+		}
+	}
+	sort.Slice(kept, func(i, j int) bool { return kept[i].start < kept[j].start })
+
+	var b strings.Builder
+	last := 0
+	for _, span := range kept {
+		value := s[span.start:span.end]
+		b.WriteString(s[last:span.start])
+		b.WriteString(a.pseudonym(span.kind, value))
+		last = span.end
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+const hostNameChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_-"
+
+// dottedHosts finds bare names such as `api.acme.com`. A name of two labels
+// needs a common TLD, and names ending in a file extension are skipped.
+func dottedHosts(s string) [][]int {
+	var locs [][]int
+	for _, loc := range dottedHost.FindAllStringSubmatchIndex(s, -1) {
+		if end := loc[3]; end < len(s) && strings.ContainsRune(hostNameChars, rune(s[end])) {
+			continue
+		}
+		tld := strings.ToLower(s[loc[4]:loc[5]])
+		if fileExtensions[tld] {
+			continue
+		}
+		if strings.Count(s[loc[2]:loc[3]], ".") < 2 && !commonTLDs[tld] {
+			continue
+		}
+		locs = append(locs, loc)
+	}
+	return locs
+}
+
+// wordPattern matches word in group 1 when it is at the end of s or followed
+// by after, and at the start of s or preceded by before. It returns nil for
+// an empty word.
+func wordPattern(before, word, after string) *regexp.Regexp {
+	if word = strings.TrimSpace(word); word == "" {
+		return nil
+	}
+	return regexp.MustCompile(`(?i)(?:^|` + before + `)(` + regexp.QuoteMeta(word) + `)(?:$|` + after + `)`)
+}
+
+// loginPattern matches username in group 1 where it is followed by `@`, as
+// in `ssh <username>@host`. It returns nil for an empty username.
+func loginPattern(username string) *regexp.Regexp {
+	if username = strings.TrimSpace(username); username == "" {
+		return nil
+	}
+	return regexp.MustCompile(`(?i)(?:^|[\s'"/:])(` + regexp.QuoteMeta(username) + `)@`)
+}
+
+// hides reports whether a match of kind is worth replacing.
+func (a *Anonymizer) hides(kind, value string) bool {
+	switch kind {
+	case PseudonymHost:
+		if ip := net.ParseIP(value); ip != nil {
+			return !ip.IsLoopback() && !ip.IsUnspecified()
+		}
+		return !strings.EqualFold(value, "localhost")
+	case PseudonymIP:
+		ip := net.ParseIP(value)
+		return ip != nil && !ip.IsLoopback() && !ip.IsUnspecified()
+	}
+	return true
+}
+
+// pseudonym returns the stable replacement for value. Hosts given as IP
+// addresses share the IP pseudonyms.
+func (a *Anonymizer) pseudonym(kind, value string) string {
+	if kind == PseudonymHost && net.ParseIP(value) != nil {
+		kind = PseudonymIP
+	}
+
+	key := kind + "\x00" + strings.ToLower(value)
+	if replacement, ok := a.names[key]; ok {
+		return replacement
+	}
+	a.counts[kind]++
+	n := a.counts[kind]
+	var replacement string
+	switch kind {
+	case PseudonymEmail:
+		replacement = fmt.Sprintf("user-%d@example.com", n)
+	case PseudonymHost:
+		replacement = fmt.Sprintf("host-%d", n)
+	case PseudonymUser:
+		replacement = "user"
+	case PseudonymIP:
+		replacement = documentationIP(value, n)
+	}
+	a.names[key] = replacement
+	a.mapping = append(a.mapping, Pseudonym{Kind: kind, Original: value, Replacement: replacement})
+	return replacement
+}
+
+// documentationIP returns the n-th address of the documentation ranges:
+// 192.0.2.0/24, 198.51.100.0/24 and 203.0.113.0/24 for IPv4 and 2001:db8::/32
+// for IPv6.
+func documentationIP(value string, n int) string {
+	if net.ParseIP(value).To4() == nil {
+		return fmt.Sprintf("2001:db8::%x", n)
+	}
+	nets := []string{"192.0.2", "198.51.100", "203.0.113"}
+	block := (n - 1) / 254
+	if block >= len(nets) {
+		return fmt.Sprintf("ip-%d", n)
+	}
+	return fmt.Sprintf("%s.%d", nets[block], (n-1)%254+1)
+}
@@ -0,0 +1,106 @@
+package export
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fixi2/Commandry/internal/store"
+)
+
+func TestAnonymizerText(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"ssh deploy@bastion.corp.example.com uptime", "ssh user-1@example.com uptime"},
+		{"curl https://api.internal.example.net:8443/health", "curl https://host-1:8443/health"},
+		{"ping 10.20.30.40 && ping 10.20.30.40", "ping 192.0.2.1 && ping 192.0.2.1"},
+		{"curl http://[fd00::12]/", "curl http://[2001:db8::2]/"},
+		{"psql -h db-primary -U app", "psql -h host-2 -U app"},
+		{"kubectl --server=https://k8s.corp:6443 get pods", "kubectl --server=https://host-3:6443 get pods"},
+		{"nslookup vault.svc", "nslookup host-4"},
+		{"git log --author=ops@acme.io", "git log --author=user-2@example.com"},
+		{"ls /home/alice/src", "ls /home/user/src"},
+		{"cat C:\\Users\\Alice\\notes.txt", "cat C:\\Users\\user\\notes.txt"},
+		{"ssh root@workstation-7", "ssh root@host-5"},
+		{"echo alice on workstation-7", "echo alice on host-5"},
+		{"curl http://localhost:8080 http://127.0.0.1:9090", "curl http://localhost:8080 http://127.0.0.1:9090"},
+		{"go test ./... -run TestX", "go test ./... -run TestX"},
+	}
+	a := NewAnonymizer("alice", "workstation-7")
+	for _, tc := range cases {
+		if got := a.Text(tc.in); got != tc.want {
+			t.Fatalf("Text(%q)\n got: %q\nwant: %q", tc.in, got, tc.want)
+		}
+	}
+
+	mapping := a.Mapping()
+	if len(mapping) != 10 {
+		t.Fatalf("expected 10 pseudonyms, got %d: %#v", len(mapping), mapping)
+	}
+	if first := mapping[0]; first != (Pseudonym{Kind: PseudonymEmail, Original: "deploy@bastion.corp.example.com", Replacement: "user-1@example.com"}) {
+		t.Fatalf("unexpected first pseudonym: %#v", first)
+	}
+}
+
+func TestAnonymizerBareHostsAndLogins(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"ssh bastion.acme.com", "ssh host-1"},
+		{"ping db01.prod.acme.io && ping db01.prod.acme.io", "ping host-2 && ping host-2"},
+		{"dig api.acme.com acme.com", "dig host-3 host-4"},
+		{"curl -s api.acme.com/health", "curl -s host-3/health"},
+		{"ssh alice@10.1.2.3", "ssh user@192.0.2.1"},
+		{"scp notes.txt alice@bastion.acme.com:/tmp", "scp notes.txt user-1@example.com:/tmp"},
+		{"helm upgrade api ./chart -f values.prod.yaml", "helm upgrade api ./chart -f values.prod.yaml"},
+		{"cat /etc/nginx/sites.d/api.conf deploy.sh", "cat /etc/nginx/sites.d/api.conf deploy.sh"},
+		{"python -m http.server", "python -m http.server"},
+		{"echo alice", "echo alice"},
+	}
+	a := NewAnonymizer("alice", "")
+	for _, tc := range cases {
+		if got := a.Text(tc.in); got != tc.want {
+			t.Fatalf("Text(%q)\n got: %q\nwant: %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestAnonymizerSessionLeavesOriginalUntouched(t *testing.T) {
+	t.Parallel()
+
+	session := &store.Session{
+		ID:        "1",
+		Title:     "Restart api on 10.1.2.3",
+		StartDir:  "/home/alice/ops",
+		StartedAt: time.Date(2026, 2, 3, 10, 0, 0, 0, time.UTC),
+		Steps: []store.Step{
+			{Command: "ssh 10.1.2.3 sudo systemctl restart api", Status: "OK", ExitCode: intPtr(0), CWD: "/home/alice/ops"},
+			{Command: "vim notes.md", Status: "OK", ExitCode: intPtr(0), CWD: "/home/alice/ops/api", Files: []store.FileChange{{Path: "/home/alice/ops/api/notes.md", Change: "modified"}}},
+		},
+	}
+	original := *session
+	original.Steps = append([]store.Step(nil), session.Steps...)
+
+	a := NewAnonymizer("alice", "")
+	got := a.Session(session)
+	if !reflect.DeepEqual(*session, original) || session.Steps[1].Files[0].Path != "/home/alice/ops/api/notes.md" {
+		t.Fatalf("Session modified its input: %#v", session)
+	}
+	if got.Title != "Restart api on 192.0.2.1" || got.StartDir != "/home/user/ops" || got.Steps[0].Command != "ssh 192.0.2.1 sudo systemctl restart api" ||
+		got.Steps[1].CWD != "/home/user/ops/api" || got.Steps[1].Files[0].Path != "/home/user/ops/api/notes.md" {
+		t.Fatalf("unexpected anonymized session: %#v", got)
+	}
+
+	md := RenderMarkdownWithOptions(got, a.Options(MarkdownOptions{GlobalComments: []string{"Checked from 10.1.2.3"}}))
+	if strings.Contains(md, "alice") || strings.Contains(md, "10.1.2.3") || !strings.Contains(md, "(in ./api)") {
+		t.Fatalf("unexpected markdown:\n%s", md)
+	}
+}
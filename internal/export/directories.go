@@ -0,0 +1,112 @@
+package export
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/fixi2/Commandry/internal/store"
+	"github.com/fixi2/Commandry/internal/util"
+)
+
+// changesDirectory lists commands whose recorded CWD is not where they ran:
+// hooks report the directory after a `cd`, so the next step sets it instead.
+var changesDirectory = map[string]bool{
+	"cd":           true,
+	"pushd":        true,
+	"popd":         true,
+	"set-location": true,
+	"sl":           true,
+	"chdir":        true,
+}
+
+// stepDirectories returns where each step ran, for steps outside the
+// session start directory, as "./infra/prod" relative to it. Every such step
+// is labelled on its own, so a reader who skips or reorders steps still runs
+// each one in the right place. A cd-like step ran in the directory before
+// its recorded CWD, which is the previous step's. Sessions without a
+// StartDir get absolute directories, and only when not all steps share one.
+func stepDirectories(session *store.Session) map[int]string {
+	base := cleanDir(session.StartDir)
+	ran := make(map[int]string)
+	previous := base
+	for i, step := range session.Steps {
+		dir := cleanDir(step.CWD)
+		if dir == "" {
+			continue
+		}
+		if isDirectoryChange(step) {
+			if previous != "" {
+				ran[i] = previous
+			}
+		} else {
+			ran[i] = dir
+		}
+		previous = dir
+	}
+
+	labels := make(map[int]string)
+	if base == "" {
+		first := ""
+		for _, dir := range ran {
+			if first == "" {
+				first = dir
+			} else if !samePath(dir, first) {
+				first = "*"
+			}
+		}
+		if first != "*" {
+			return labels
+		}
+		for i, dir := range ran {
+			labels[i] = filepath.ToSlash(dir)
+		}
+		return labels
+	}
+	for i, dir := range ran {
+		if !samePath(dir, base) {
+			labels[i] = relativeDir(base, dir)
+		}
+	}
+	return labels
+}
+
+func isDirectoryChange(step store.Step) bool {
+	if step.Shell {
+		return false
+	}
+	args := util.SplitArgs(step.Command)
+	return len(args) > 0 && changesDirectory[strings.ToLower(args[0])]
+}
+
+// relativeDir returns to relative to from as "./sub" or "../other", or the
+// absolute to when there is no relative path.
+func relativeDir(from, to string) string {
+	rel, err := filepath.Rel(from, to)
+	if err != nil {
+		return filepath.ToSlash(to)
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return rel
+	}
+	return "./" + rel
+}
+
+func cleanDir(dir string) string {
+	if strings.TrimSpace(dir) == "" {
+		return ""
+	}
+	return filepath.Clean(dir)
+}
+
+func samePath(a, b string) bool {
+	if filepath.Separator == '\\' {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// startDirLabel names the start directory without leaking its full path.
+func startDirLabel(session *store.Session) string {
+	return filepath.Base(cleanDir(session.StartDir))
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/fixi2/Commandry/internal/buildinfo"
 	"github.com/fixi2/Commandry/internal/store"
+	"github.com/fixi2/Commandry/internal/util"
 )
 
 var nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
@@ -70,6 +71,7 @@ func RenderMarkdownWithOptions(session *store.Session, opts MarkdownOptions) str
 	var b strings.Builder
 
 	summary := buildStepSummary(session.Steps)
+	directories := stepDirectories(session)
 
 	b.WriteString("# ")
 	b.WriteString(session.Title)
@@ -78,10 +80,23 @@ func RenderMarkdownWithOptions(session *store.Session, opts MarkdownOptions) str
 	b.WriteString("## Summary\n")
 	b.WriteString("This runbook was generated from an explicit Commandry session.\n")
 	b.WriteString(fmt.Sprintf("Recorded %d step(s).\n", len(session.Steps)))
-	b.WriteString(fmt.Sprintf("Results: OK %d | FAILED %d | REDACTED %d\n", summary.ok, summary.failed, summary.redacted))
+	b.WriteString(fmt.Sprintf("Results: OK %d | FAILED %d | REDACTED %d", summary.ok, summary.failed, summary.redacted))
+	if summary.unexpected > 0 {
+		b.WriteString(fmt.Sprintf(" | UNEXPECTED %d", summary.unexpected))
+	}
+	if summary.aborted > 0 {
+		b.WriteString(fmt.Sprintf(" | ABORTED %d", summary.aborted))
+	}
+	if summary.planned > 0 {
+		b.WriteString(fmt.Sprintf(" | PLANNED %d", summary.planned))
+	}
+	b.WriteString("\n")
 	b.WriteString(fmt.Sprintf("Total duration: %d ms\n\n", summary.totalDurationMS))
 
 	b.WriteString("## Before You Run\n")
+	if len(directories) > 0 && session.StartDir != "" {
+		b.WriteString(fmt.Sprintf("- [ ] Open a shell in the session start directory (`%s`); steps marked `(in ./dir)` run in that directory relative to it.\n", startDirLabel(session)))
+	}
 	for _, precondition := range detectPreconditions(session.Steps) {
 		b.WriteString("- [ ] ")
 		b.WriteString(precondition)
@@ -98,7 +113,16 @@ func RenderMarkdownWithOptions(session *store.Session, opts MarkdownOptions) str
 	} else {
 		for i, step := range session.Steps {
 			status, reason := normalizeResult(step)
-			b.WriteString(fmt.Sprintf("%d. [%s] %s\n\n", i+1, status, stepTitleSnippet(step.Command)))
+			marker := status
+			if status == "PLANNED" {
+				// Planned steps are a checklist for whoever runs the change.
+				marker = " "
+			}
+			title := stepTitleSnippet(step.Command)
+			if dir, ok := directories[i]; ok {
+				title += fmt.Sprintf(" (in %s)", dir)
+			}
+			b.WriteString(fmt.Sprintf("%d. [%s] %s\n\n", i+1, marker, title))
 			b.WriteString("```sh\n")
 			b.WriteString(step.Command)
 			b.WriteString("\n```\n")
@@ -110,7 +134,24 @@ func RenderMarkdownWithOptions(session *store.Session, opts MarkdownOptions) str
 			if step.ExitCode != nil {
 				b.WriteString(fmt.Sprintf("Exit code: %d\n", *step.ExitCode))
 			}
-			b.WriteString(fmt.Sprintf("Duration: %d ms\n\n", step.DurationMS))
+			if line := expectationLine(step); line != "" {
+				b.WriteString(line)
+			}
+			if step.Guard != nil {
+				b.WriteString(guardLine(step.Guard))
+			}
+			if status == "PLANNED" {
+				b.WriteString("\n")
+			} else {
+				b.WriteString(fmt.Sprintf("Duration: %d ms\n\n", step.DurationMS))
+			}
+			if len(step.Files) > 0 {
+				b.WriteString("Files changed:\n")
+				for _, file := range step.Files {
+					b.WriteString(fmt.Sprintf("- %s: `%s`\n", file.Change, file.Path))
+				}
+				b.WriteString("\n")
+			}
 			if comments := opts.StepComments[i]; len(comments) > 0 {
 				if len(comments) == 1 {
 					b.WriteString("Reviewer note:\n")
@@ -166,6 +207,9 @@ type stepSummary struct {
 	ok              int
 	failed          int
 	redacted        int
+	unexpected      int
+	aborted         int
+	planned         int
 	totalDurationMS int64
 }
 
@@ -180,17 +224,51 @@ func buildStepSummary(steps []store.Step) stepSummary {
 			s.failed++
 		case "REDACTED":
 			s.redacted++
+		case "UNEXPECTED":
+			s.unexpected++
+		case "ABORTED":
+			s.aborted++
+		case "PLANNED":
+			s.planned++
 		}
 		if status != "REDACTED" && hasInlineRedaction(step.Command) {
 			s.redacted++
 		}
-		if step.DurationMS > 0 {
+		if status != "PLANNED" && step.DurationMS > 0 {
 			s.totalDurationMS += step.DurationMS
 		}
 	}
 	return s
 }
 
+func expectationLine(step store.Step) string {
+	if step.ExpectFail {
+		return "Expected: failure (nonzero exit)\n"
+	}
+	if len(step.ExpectedExit) == 0 {
+		return ""
+	}
+	codes := make([]string, 0, len(step.ExpectedExit))
+	for _, code := range step.ExpectedExit {
+		codes = append(codes, fmt.Sprintf("%d", code))
+	}
+	return fmt.Sprintf("Expected exit: %s\n", strings.Join(codes, ", "))
+}
+
+func guardLine(guard *store.GuardCheck) string {
+	if !guard.Confirmed {
+		if guard.Via == "non_interactive" {
+			return fmt.Sprintf("Guard: not confirmed, no interactive input (rule `%s`)\n", guard.Rule)
+		}
+		return fmt.Sprintf("Guard: declined (rule `%s`)\n", guard.Rule)
+	}
+	via := "prompt"
+	if guard.Via == "yes_flag" {
+		via = "--yes"
+	}
+	return fmt.Sprintf("Guard: confirmed via %s (rule `%s`)\n", via, guard.Rule)
+}
+
 func hasInlineRedaction(command string) bool {
 	return strings.Contains(command, "[REDACTED]")
 }
@@ -250,16 +328,14 @@ func detectPreconditions(steps []store.Step) []string {
 	hasCloudCLI := false
 	hasDBCLI := false
 	for _, step := range steps {
-		cmd := guidanceCommand(step.Command)
-		if cmd == "" {
-			continue
+		for _, cmd := range guidanceCommands(step) {
+			hasKubectl = hasKubectl || kubectlWord.MatchString(cmd)
+			hasHelm = hasHelm || helmWord.MatchString(cmd)
+			hasDocker = hasDocker || dockerWord.MatchString(cmd)
+			hasTerraform = hasTerraform || terraformWord.MatchString(cmd)
+			hasCloudCLI = hasCloudCLI || awsWord.MatchString(cmd) || gcloudWord.MatchString(cmd) || azWord.MatchString(cmd)
+			hasDBCLI = hasDBCLI || psqlWord.MatchString(cmd) || mysqlWord.MatchString(cmd)
 		}
-		hasKubectl = hasKubectl || kubectlWord.MatchString(cmd)
-		hasHelm = hasHelm || helmWord.MatchString(cmd)
-		hasDocker = hasDocker || dockerWord.MatchString(cmd)
-		hasTerraform = hasTerraform || terraformWord.MatchString(cmd)
-		hasCloudCLI = hasCloudCLI || awsWord.MatchString(cmd) || gcloudWord.MatchString(cmd) || azWord.MatchString(cmd)
-		hasDBCLI = hasDBCLI || psqlWord.MatchString(cmd) || mysqlWord.MatchString(cmd)
 	}
 
 	preconditions := make([]string, 0, 10)
@@ -316,12 +392,10 @@ func detectVerificationChecks(steps []store.Step) []string {
 	hasKubectlRolloutStatus := false
 
 	for _, step := range steps {
-		cmd := guidanceCommand(step.Command)
-		if cmd == "" {
-			continue
+		for _, cmd := range guidanceCommands(step) {
+			hasKubectlApply = hasKubectlApply || kubectlApply.MatchString(cmd)
+			hasKubectlRolloutStatus = hasKubectlRolloutStatus || kubectlRolloutStatus.MatchString(cmd)
 		}
-		hasKubectlApply = hasKubectlApply || kubectlApply.MatchString(cmd)
-		hasKubectlRolloutStatus = hasKubectlRolloutStatus || kubectlRolloutStatus.MatchString(cmd)
 	}
 
 	if hasKubectlApply || hasKubectlRolloutStatus {
@@ -341,22 +415,20 @@ func detectRollback(steps []store.Step) (string, []string) {
 	seen := make(map[string]struct{})
 
 	for _, step := range steps {
-		if !isSuccessfulStep(step) {
-			continue
-		}
-		cmd := guidanceCommand(step.Command)
-		if cmd == "" {
-			continue
-		}
-		name := extractDeploymentName(cmd)
-		if name == "" {
+		if !isSuccessfulStep(step) && !strings.EqualFold(step.Status, "PLANNED") {
 			continue
 		}
-		if _, ok := seen[name]; ok {
-			continue
+		for _, cmd := range guidanceCommands(step) {
+			name := extractDeploymentName(cmd)
+			if name == "" {
+				continue
+			}
+			if _, ok := seen[name]; ok {
+				continue
+			}
+			seen[name] = struct{}{}
+			deployments = append(deployments, name)
 		}
-		seen[name] = struct{}{}
-		deployments = append(deployments, name)
 	}
 
 	if len(deployments) == 0 {
@@ -395,6 +467,27 @@ func extractDeploymentName(cmd string) string {
 	return ""
 }
 
+// guidanceCommands returns the lowercased commands of a step that guidance
+// should inspect. Shell steps and hook lines are split into their pipeline
+// segments.
+func guidanceCommands(step store.Step) []string {
+	if !step.Shell && step.Source != "hook" {
+		if cmd := guidanceCommand(step.Command); cmd != "" {
+			return []string{cmd}
+		}
+		return nil
+	}
+
+	segments := util.SplitShellScript(step.Command)
+	cmds := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		if cmd := guidanceCommand(segment.Command); cmd != "" {
+			cmds = append(cmds, cmd)
+		}
+	}
+	return cmds
+}
+
 func guidanceCommand(command string) string {
 	cmd := strings.TrimSpace(strings.ToLower(command))
 	if cmd == "" {
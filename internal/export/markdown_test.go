@@ -134,6 +134,18 @@ func TestDetectRollback(t *testing.T) {
 				"Document the rollback command for this workflow before production use.",
 			},
 		},
+		{
+			name: "rollback suggested from shell segment",
+			steps: []store.Step{
+				{Command: "kubectl rollout restart deployment/api && kubectl rollout status deployment/worker", Status: "OK", ExitCode: intPtr(0), Shell: true},
+			},
+			wantTitle: "Rollback",
+			wantItems: []string{
+				"Verify root cause and deployment revision before undoing changes.",
+				"`kubectl rollout undo deployment/api`",
+				"`kubectl rollout undo deployment/worker`",
+			},
+		},
 		{
 			name: "no rollback for echoed kubectl rollout",
 			steps: []store.Step{
@@ -268,3 +280,184 @@ func TestRenderMarkdownSummaryCountsInlineRedaction(t *testing.T) {
 		t.Fatalf("summary must count inline redaction: %s", got)
 	}
 }
+
+func TestRenderMarkdownListsChangedFiles(t *testing.T) {
+	t.Parallel()
+
+	session := &store.Session{
+		ID:        "1",
+		Title:     "Tracked files",
+		StartedAt: time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC),
+		Steps: []store.Step{
+			{
+				Command:    "helm template api ./chart",
+				Status:     "OK",
+				ExitCode:   intPtr(0),
+				DurationMS: 40,
+				Files: []store.FileChange{
+					{Path: "out/api.yaml", Change: "created"},
+					{Path: "values.yaml", Change: "modified"},
+				},
+			},
+		},
+	}
+
+	got := RenderMarkdown(session)
+	if !strings.Contains(got, "Duration: 40 ms\n\nFiles changed:\n- created: `out/api.yaml`\n- modified: `values.yaml`\n\n") {
+		t.Fatalf("missing files changed list under step: %s", got)
+	}
+}
+
+func TestRenderMarkdownPlannedSteps(t *testing.T) {
+	t.Parallel()
+
+	session := &store.Session{
+		ID:        "1",
+		Title:     "Change window",
+		Plan:      true,
+		StartedAt: time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC),
+		Steps: []store.Step{
+			{
+				Command:    "kubectl get pods",
+				Status:     "OK",
+				ExitCode:   intPtr(0),
+				DurationMS: 25,
+			},
+			{
+				Command: "kubectl apply -f deploy.yaml",
+				Status:  "PLANNED",
+			},
+		},
+	}
+
+	got := RenderMarkdown(session)
+	if !strings.Contains(got, "Results: OK 1 | FAILED 0 | REDACTED 0 | PLANNED 1\nTotal duration: 25 ms\n") {
+		t.Fatalf("summary must count planned steps separately: %s", got)
+	}
+	if !strings.Contains(got, "2. [ ] kubectl apply -f deploy.yaml\n\n```sh\nkubectl apply -f deploy.yaml\n```\nResult: PLANNED\n\n") {
+		t.Fatalf("planned step must render as unchecked checkbox without duration: %s", got)
+	}
+}
+
+func TestRenderMarkdownExpectedExitCodes(t *testing.T) {
+	t.Parallel()
+
+	session := &store.Session{
+		ID:        "1",
+		Title:     "Probes",
+		StartedAt: time.Date(2026, 3, 3, 10, 0, 0, 0, time.UTC),
+		Steps: []store.Step{
+			{
+				Command:      "grep -q ready app.log",
+				Status:       "OK",
+				ExitCode:     intPtr(1),
+				ExpectedExit: []int{0, 1},
+				DurationMS:   5,
+			},
+			{
+				Command:    "kubectl get ns legacy",
+				Status:     "UNEXPECTED",
+				Reason:     "unexpected_success",
+				ExitCode:   intPtr(0),
+				ExpectFail: true,
+				DurationMS: 7,
+			},
+		},
+	}
+
+	got := RenderMarkdown(session)
+	if !strings.Contains(got, "Results: OK 1 | FAILED 0 | REDACTED 0 | UNEXPECTED 1\n") {
+		t.Fatalf("summary must count unexpected steps: %s", got)
+	}
+	if !strings.Contains(got, "Exit code: 1\nExpected exit: 0, 1\n") {
+		t.Fatalf("missing expected exit codes line: %s", got)
+	}
+	if !strings.Contains(got, "Result: UNEXPECTED (unexpected_success)\nExit code: 0\nExpected: failure (nonzero exit)\n") {
+		t.Fatalf("missing expected failure line: %s", got)
+	}
+}
+
+func TestRenderMarkdownDirectoryTransitions(t *testing.T) {
+	t.Parallel()
+
+	start := filepath.Join(string(filepath.Separator)+"home", "ops", "api")
+	prod := filepath.Join(start, "infra", "prod")
+	session := &store.Session{
+		ID:        "1",
+		Title:     "Deploy",
+		StartDir:  start,
+		StartedAt: time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC),
+		Steps: []store.Step{
+			{Command: "make build", Status: "OK", ExitCode: intPtr(0), CWD: start},
+			{Command: "terraform plan", Status: "OK", ExitCode: intPtr(0), CWD: prod},
+			{Command: "terraform apply", Status: "OK", ExitCode: intPtr(0), CWD: prod},
+			{Command: "cd ../../web", Status: "OK", ExitCode: intPtr(0), CWD: filepath.Join(start, "web")},
+			{Command: "npm run deploy", Status: "OK", ExitCode: intPtr(0), CWD: filepath.Join(start, "web")},
+			{Command: "make smoke", Status: "OK", ExitCode: intPtr(0), CWD: start},
+		},
+	}
+
+	got := RenderMarkdown(session)
+	for _, want := range []string{
+		"## Before You Run\n- [ ] Open a shell in the session start directory (`api`); steps marked `(in ./dir)` run in that directory relative to it.\n",
+		"1. [OK] make build\n\n```sh\nmake build\n```",
+		"2. [OK] terraform plan (in ./infra/prod)\n\n```sh\nterraform plan\n```",
+		"3. [OK] terraform apply (in ./infra/prod)\n\n```sh\nterraform apply\n```",
+		// The cd ran where the previous step did, not in its recorded CWD.
+		"4. [OK] cd ../../web (in ./infra/prod)\n\n```sh\ncd ../../web\n```",
+		"5. [OK] npm run deploy (in ./web)\n\n```sh\nnpm run deploy\n```",
+		"6. [OK] make smoke\n\n```sh\nmake smoke\n```",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %q in markdown:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, start) {
+		t.Fatalf("markdown must not expose absolute session paths:\n%s", got)
+	}
+
+	session.StartDir = ""
+	session.Steps = session.Steps[1:3]
+	got = RenderMarkdown(session)
+	if strings.Contains(got, "(in ") || strings.Contains(got, "start directory") {
+		t.Fatalf("sessions in one directory must not be annotated:\n%s", got)
+	}
+}
+
+func TestRenderMarkdownDirectoryAfterCd(t *testing.T) {
+	t.Parallel()
+
+	start := filepath.Join(string(filepath.Separator)+"home", "ops", "api")
+	prod := filepath.Join(start, "infra", "prod")
+	session := &store.Session{
+		ID:        "1",
+		Title:     "Deploy",
+		StartDir:  start,
+		StartedAt: time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC),
+		Steps: []store.Step{
+			{Command: "cd infra/prod", Status: "OK", ExitCode: intPtr(0), CWD: prod},
+			{Command: "terraform apply", Status: "OK", ExitCode: intPtr(0), CWD: prod},
+			{Command: "cd ../..", Status: "OK", ExitCode: intPtr(0), CWD: start},
+			{Command: "make smoke", Status: "OK", ExitCode: intPtr(0), CWD: start},
+		},
+	}
+
+	got := RenderMarkdown(session)
+	for _, want := range []string{
+		"1. [OK] cd infra/prod\n\n",
+		"2. [OK] terraform apply (in ./infra/prod)\n\n",
+		"3. [OK] cd ../.. (in ./infra/prod)\n\n",
+		"4. [OK] make smoke\n\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %q in markdown:\n%s", want, got)
+		}
+	}
+
+	// Without a start directory the steps name absolute directories.
+	session.StartDir = ""
+	got = RenderMarkdown(session)
+	if want := "2. [OK] terraform apply (in " + filepath.ToSlash(prod) + ")\n\n"; !strings.Contains(got, want) {
+		t.Fatalf("expected %q in markdown:\n%s", want, got)
+	}
+}
@@ -83,6 +83,27 @@ func TestDetectPreconditions(t *testing.T) {
 				"Credentials and environment context are set for the target system.",
 			},
 		},
+		{
+			name: "shell pipeline segments",
+			steps: []store.Step{
+				{Command: `echo "building" && docker build -t app . | tee build.log`, Shell: true},
+			},
+			want: []string{
+				"Docker CLI is installed and Docker daemon is running.",
+				"Current user has permission to access Docker daemon.",
+				"Sensitive values are not exposed in command arguments.",
+			},
+		},
+		{
+			name: "shell pipeline ignores echoed segment",
+			steps: []store.Step{
+				{Command: `echo kubectl apply -f deploy.yaml | cat`, Shell: true},
+			},
+			want: []string{
+				"Required tools are installed and available in PATH.",
+				"Credentials and environment context are set for the target system.",
+			},
+		},
 		{
 			name: "ignore echoed kubectl command",
 			steps: []store.Step{
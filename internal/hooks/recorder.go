@@ -5,39 +5,51 @@ import (
 	"errors"
 	"fmt"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/fixi2/Commandry/internal/capture"
 	"github.com/fixi2/Commandry/internal/policy"
 	"github.com/fixi2/Commandry/internal/store"
+	"github.com/fixi2/Commandry/internal/util"
 )
 
+// ShellTokenEnv is exported by the installed hook blocks so a session can be
+// limited to the terminal that started it.
+const ShellTokenEnv = "COMMANDRY_SHELL_TOKEN"
+
 type RecordInput struct {
 	Command    string
 	CWD        string
 	ExitCode   int
-	DurationMS int64
+	DurationMS int64 // negative when the shell did not measure the command
 	Timestamp  time.Time
+	ShellToken string // COMMANDRY_SHELL_TOKEN of the recording shell
 }
 
 type RecordResult struct {
 	Recorded      bool
 	SkippedReason string
+	SkippedRule   string // hooks.ignore entry behind an ignore skip
 	Reminder      bool
 	Step          store.Step
+	// Warning reports a failure that did not stop the step from being
+	// recorded, such as a failed audit log write.
+	Warning string
 }
 
 type Recorder struct {
 	store      store.SessionStore
-	policy     *policy.Policy
+	policies   *policy.Resolver
 	stateStore StateStore
 }
 
-func NewRecorder(sessionStore store.SessionStore, pol *policy.Policy, stateStore StateStore) *Recorder {
+func NewRecorder(sessionStore store.SessionStore, policies *policy.Resolver, stateStore StateStore) *Recorder {
 	return &Recorder{
 		store:      sessionStore,
-		policy:     pol,
+		policies:   policies,
 		stateStore: stateStore,
 	}
 }
@@ -62,24 +74,47 @@ func (r *Recorder) Record(ctx context.Context, input RecordInput) (RecordResult,
 	if isSelfInvocation(args) {
 		return RecordResult{Recorded: false, SkippedReason: "self_command"}, nil
 	}
-
-	if _, err := r.store.GetActiveSession(ctx); err != nil {
+	active, err := r.store.GetActiveSession(ctx)
+	if err != nil {
 		if errors.Is(err, store.ErrNoActiveSession) || errors.Is(err, store.ErrNotInitialized) {
 			return RecordResult{Recorded: false, SkippedReason: "no_active_session"}, nil
 		}
 		return RecordResult{}, fmt.Errorf("check active session: %w", err)
 	}
+	if active.ShellToken != "" && active.ShellToken != strings.TrimSpace(input.ShellToken) {
+		return RecordResult{Recorded: false, SkippedReason: "other_terminal"}, nil
+	}
+	if active.Scope != "" && !WithinScope(input.CWD, active.Scope) {
+		return RecordResult{Recorded: false, SkippedReason: "out_of_scope", SkippedRule: active.Scope}, nil
+	}
 
-	sanitized := r.policy.Apply(raw, args)
+	pol := r.policies.ForEnv(active.Env)
+	// Hook lines are whole shell command lines, so every segment of a list or
+	// pipeline has to pass the policy, not just the first binary.
+	sanitized := pol.ApplyShell(raw)
+	// A denied line is always recorded and audited, so hooks.ignore (such as
+	// min_duration_ms for a fast `cat ~/.ssh/id_rsa`) cannot hide it.
+	// hooks.ignore is not part of a profile.
+	if !sanitized.Denied {
+		if match, ok := r.policies.Default().IgnoreHookEvent(raw, input.ExitCode, input.DurationMS); ok {
+			return RecordResult{Recorded: false, SkippedReason: match.Reason, SkippedRule: match.Rule}, nil
+		}
+	}
 	step := store.Step{
 		Timestamp:  normalizeTimestamp(input.Timestamp),
 		Command:    sanitized.Command,
 		DurationMS: clampDuration(input.DurationMS),
 		CWD:        input.CWD,
+		Source:     "hook",
 	}
 	if sanitized.Denied {
 		step.Status = "REDACTED"
 		step.Reason = "policy_redacted"
+	} else if expected := pol.ExpectedExitCodes(lastCommandArgs(raw)); len(expected) > 0 {
+		code := input.ExitCode
+		step.Status, step.Reason = capture.ExpectedStatus(code, expected, false)
+		step.ExpectedExit = expected
+		step.ExitCode = &code
 	} else if input.ExitCode == 0 {
 		code := 0
 		step.Status = "OK"
@@ -94,6 +129,21 @@ func (r *Recorder) Record(ctx context.Context, input RecordInput) (RecordResult,
 	if err := r.store.AddStep(ctx, step); err != nil {
 		return RecordResult{}, fmt.Errorf("record hook step: %w", err)
 	}
+	// The step is stored, so a failed audit write only warns: an error would
+	// make the shell hooks retry and record the step twice.
+	warning := ""
+	if sanitized.Denied {
+		if err := r.store.AppendAudit(ctx, store.AuditEvent{
+			Timestamp: step.Timestamp,
+			SessionID: active.ID,
+			Event:     step.Reason,
+			Rule:      sanitized.Rule,
+			Tool:      sanitized.Tool,
+			Source:    "hook",
+		}); err != nil {
+			warning = fmt.Sprintf("write audit log: %v", err)
+		}
+	}
 
 	reminder, err := r.bumpCounter(ctx)
 	if err != nil {
@@ -104,6 +154,7 @@ func (r *Recorder) Record(ctx context.Context, input RecordInput) (RecordResult,
 		Recorded: true,
 		Reminder: reminder,
 		Step:     step,
+		Warning:  warning,
 	}, nil
 }
 
@@ -125,6 +176,35 @@ func (r *Recorder) bumpCounter(ctx context.Context) (bool, error) {
 	return state.Enabled && state.RemindEvery > 0 && state.CommandCount%int64(state.RemindEvery) == 0, nil
 }
 
+// WithinScope reports whether dir is scope or one of its subdirectories. An
+// empty dir is never in scope.
+func WithinScope(dir, scope string) bool {
+	if strings.TrimSpace(dir) == "" {
+		return false
+	}
+	dir = filepath.Clean(dir)
+	scope = filepath.Clean(scope)
+	if runtime.GOOS == "windows" {
+		dir = strings.ToLower(dir)
+		scope = strings.ToLower(scope)
+	}
+	rel, err := filepath.Rel(scope, dir)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel))
+}
+
+// lastCommandArgs returns the args of the last command of a shell line, the
+// one whose exit status the shell reports for a pipeline.
+func lastCommandArgs(raw string) []string {
+	segments := util.SplitShellScript(raw)
+	if len(segments) == 0 {
+		return nil
+	}
+	return util.SplitArgs(segments[len(segments)-1].Command)
+}
+
 func splitCommand(raw string) []string {
 	parts := strings.Fields(raw)
 	if len(parts) == 0 {
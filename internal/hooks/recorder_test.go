@@ -3,6 +3,8 @@ package hooks
 import (
 	"context"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -31,7 +33,7 @@ func TestRecorderRecord(t *testing.T) {
 		t.Fatalf("save state: %v", err)
 	}
 
-	rec := NewRecorder(sessionStore, policy.NewDefault(), stateStore)
+	rec := NewRecorder(sessionStore, policy.NewDefaultResolver(), stateStore)
 	first, err := rec.Record(ctx, RecordInput{
 		Command:    "echo hello",
 		CWD:        root,
@@ -80,7 +82,7 @@ func TestRecorderNoReminderWhenDisabled(t *testing.T) {
 		t.Fatalf("save state: %v", err)
 	}
 
-	rec := NewRecorder(sessionStore, policy.NewDefault(), stateStore)
+	rec := NewRecorder(sessionStore, policy.NewDefaultResolver(), stateStore)
 	for i := 0; i < 5; i++ {
 		res, err := rec.Record(ctx, RecordInput{
 			Command:    "echo hello",
@@ -117,7 +119,7 @@ func TestRecorderSkipsSelfCommands(t *testing.T) {
 		t.Fatalf("save state: %v", err)
 	}
 
-	rec := NewRecorder(sessionStore, policy.NewDefault(), stateStore)
+	rec := NewRecorder(sessionStore, policy.NewDefaultResolver(), stateStore)
 	result, err := rec.Record(ctx, RecordInput{
 		Command:    "cmdry status",
 		ExitCode:   0,
@@ -199,7 +201,7 @@ func TestRecorderAppliesPolicy(t *testing.T) {
 		t.Fatalf("save state: %v", err)
 	}
 
-	rec := NewRecorder(sessionStore, policy.NewDefault(), stateStore)
+	rec := NewRecorder(sessionStore, policy.NewDefaultResolver(), stateStore)
 	if _, err := rec.Record(ctx, RecordInput{
 		Command:    `curl -H "Authorization: Bearer abc123" https://example.com`,
 		ExitCode:   1,
@@ -217,6 +219,49 @@ func TestRecorderAppliesPolicy(t *testing.T) {
 	}
 }
 
+func TestRecorderAppliesExpectedExitCodes(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newRetryTempDir(t)
+	sessionStore := store.NewJSONStore(root)
+	if err := sessionStore.Init(ctx); err != nil {
+		t.Fatalf("init store: %v", err)
+	}
+	if _, err := sessionStore.StartSession(ctx, "hooks", "", time.Now().UTC()); err != nil {
+		t.Fatalf("start session: %v", err)
+	}
+
+	rec := NewRecorder(sessionStore, policy.NewDefaultResolver(), nil)
+	for _, input := range []RecordInput{
+		{Command: "grep -q needle app.log", ExitCode: 1},
+		{Command: "grep -q needle missing.log", ExitCode: 2},
+		{Command: "grep needle app.log | wc -l", ExitCode: 1},
+		{Command: "cat app.log | sudo grep -q needle", ExitCode: 1},
+	} {
+		if _, err := rec.Record(ctx, input); err != nil {
+			t.Fatalf("record %q: %v", input.Command, err)
+		}
+	}
+
+	active, err := sessionStore.GetActiveSession(ctx)
+	if err != nil {
+		t.Fatalf("active session: %v", err)
+	}
+	if got := active.Steps[0]; got.Status != "OK" || got.Reason != "" || got.Source != "hook" {
+		t.Fatalf("expected grep exit 1 to be an OK hook step, got %+v", got)
+	}
+	if got := active.Steps[1]; got.Status != "UNEXPECTED" || got.Reason != "unexpected_exit" {
+		t.Fatalf("expected grep exit 2 to be UNEXPECTED, got %s (%s)", got.Status, got.Reason)
+	}
+	if got := active.Steps[2]; got.Status != "FAILED" || got.ExpectedExit != nil {
+		t.Fatalf("expected a pipeline ending in wc to use no grep defaults, got %+v", got)
+	}
+	if got := active.Steps[3]; got.Status != "OK" || got.Reason != "" {
+		t.Fatalf("expected a pipeline ending in grep to use grep defaults, got %s (%s)", got.Status, got.Reason)
+	}
+}
+
 func TestRecorderSkipsWhenHooksDisabled(t *testing.T) {
 	t.Parallel()
 
@@ -230,7 +275,7 @@ func TestRecorderSkipsWhenHooksDisabled(t *testing.T) {
 		t.Fatalf("start session: %v", err)
 	}
 
-	rec := NewRecorder(sessionStore, policy.NewDefault(), NewFileStateStore(root))
+	rec := NewRecorder(sessionStore, policy.NewDefaultResolver(), NewFileStateStore(root))
 	result, err := rec.Record(ctx, RecordInput{
 		Command:    "echo hi",
 		ExitCode:   0,
@@ -247,6 +292,164 @@ func TestRecorderSkipsWhenHooksDisabled(t *testing.T) {
 	}
 }
 
+func TestRecorderSkipsIgnoredCommands(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newRetryTempDir(t)
+	sessionStore := store.NewJSONStore(root)
+	if err := sessionStore.Init(ctx); err != nil {
+		t.Fatalf("init store: %v", err)
+	}
+	if _, err := sessionStore.StartSession(ctx, "hooks", "", time.Now().UTC()); err != nil {
+		t.Fatalf("start session: %v", err)
+	}
+
+	rec := NewRecorder(sessionStore, policy.NewDefaultResolver(), nil)
+	for _, tc := range []struct {
+		input  RecordInput
+		reason string
+		rule   string
+	}{
+		{input: RecordInput{Command: "ls -la"}, reason: "ignored_binary", rule: "ls"},
+		{input: RecordInput{Command: "git status"}, reason: "ignored_command", rule: "git status*"},
+		{input: RecordInput{Command: "kubeclt get pods", ExitCode: 127}, reason: "command_not_found", rule: "command_not_found"},
+		{input: RecordInput{Command: "cd /srv && ls"}, reason: "ignored_binary", rule: "cd"},
+	} {
+		result, err := rec.Record(ctx, tc.input)
+		if err != nil {
+			t.Fatalf("record %q: %v", tc.input.Command, err)
+		}
+		if result.Recorded || result.SkippedReason != tc.reason || result.SkippedRule != tc.rule {
+			t.Fatalf("%q: unexpected result %+v", tc.input.Command, result)
+		}
+	}
+	for _, command := range []string{"kubectl get pods", "cd /srv && kubectl rollout restart deploy/api"} {
+		if result, err := rec.Record(ctx, RecordInput{Command: command}); err != nil || !result.Recorded {
+			t.Fatalf("expected %q to be recorded, got %+v (%v)", command, result, err)
+		}
+	}
+
+	active, err := sessionStore.GetActiveSession(ctx)
+	if err != nil {
+		t.Fatalf("active session: %v", err)
+	}
+	if len(active.Steps) != 2 {
+		t.Fatalf("expected only the kubectl steps, got %+v", active.Steps)
+	}
+}
+
+func TestRecorderHonorsSessionScopeAndTerminal(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newRetryTempDir(t)
+	sessionStore := store.NewJSONStore(root)
+	if err := sessionStore.Init(ctx); err != nil {
+		t.Fatalf("init store: %v", err)
+	}
+	scope := filepath.Join(root, "project")
+	if _, err := sessionStore.StartSessionWithOptions(ctx, store.StartOptions{
+		Title:      "scoped",
+		StartedAt:  time.Now().UTC(),
+		Scope:      scope,
+		ShellToken: "111-222",
+	}); err != nil {
+		t.Fatalf("start session: %v", err)
+	}
+
+	rec := NewRecorder(sessionStore, policy.NewDefaultResolver(), nil)
+	for _, tc := range []struct {
+		input  RecordInput
+		reason string
+	}{
+		{input: RecordInput{Command: "make", CWD: filepath.Join(scope, "sub"), ShellToken: "111-222"}, reason: ""},
+		{input: RecordInput{Command: "make", CWD: scope + "-other", ShellToken: "111-222"}, reason: "out_of_scope"},
+		{input: RecordInput{Command: "make", CWD: "", ShellToken: "111-222"}, reason: "out_of_scope"},
+		{input: RecordInput{Command: "make", CWD: scope, ShellToken: "999-1"}, reason: "other_terminal"},
+		{input: RecordInput{Command: "make", CWD: scope}, reason: "other_terminal"},
+	} {
+		result, err := rec.Record(ctx, tc.input)
+		if err != nil {
+			t.Fatalf("record %+v: %v", tc.input, err)
+		}
+		if result.Recorded != (tc.reason == "") || result.SkippedReason != tc.reason {
+			t.Fatalf("%+v: unexpected result %+v", tc.input, result)
+		}
+	}
+}
+
+func TestRecorderUsesProfileForSessionEnv(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := policy.ParseConfig("policy:\n  profiles:\n    prod:\n      denylist: [\"terraform destroy\"]\n")
+	if err != nil {
+		t.Fatalf("parse config: %v", err)
+	}
+	policies, err := policy.NewResolver(cfg)
+	if err != nil {
+		t.Fatalf("new resolver: %v", err)
+	}
+
+	for _, tc := range []struct {
+		env    string
+		status string
+	}{
+		{env: "", status: "OK"},
+		{env: "prod", status: "REDACTED"},
+	} {
+		ctx := context.Background()
+		root := newRetryTempDir(t)
+		sessionStore := store.NewJSONStore(root)
+		if err := sessionStore.Init(ctx); err != nil {
+			t.Fatalf("init store: %v", err)
+		}
+		if _, err := sessionStore.StartSession(ctx, "hooks", tc.env, time.Now().UTC()); err != nil {
+			t.Fatalf("start session: %v", err)
+		}
+
+		result, err := NewRecorder(sessionStore, policies, nil).Record(ctx, RecordInput{Command: "terraform destroy -auto-approve"})
+		if err != nil {
+			t.Fatalf("record in env %q: %v", tc.env, err)
+		}
+		if !result.Recorded || result.Step.Status != tc.status {
+			t.Fatalf("env %q: expected status %s, got %+v", tc.env, tc.status, result)
+		}
+
+		events, err := sessionStore.AuditEvents(ctx, time.Time{})
+		if err != nil {
+			t.Fatalf("read audit log: %v", err)
+		}
+		if tc.status == "OK" && len(events) != 0 {
+			t.Fatalf("env %q: expected no audit events, got %+v", tc.env, events)
+		}
+		if tc.status == "REDACTED" && (len(events) != 1 || events[0].Event != "policy_redacted" || events[0].Rule != "terraform destroy" ||
+			events[0].Tool != "terraform" || events[0].Source != "hook" || events[0].SessionID == "") {
+			t.Fatalf("env %q: unexpected audit events %+v", tc.env, events)
+		}
+	}
+}
+
+func TestWithinScope(t *testing.T) {
+	t.Parallel()
+
+	scope := filepath.Join(string(filepath.Separator)+"srv", "app")
+	cases := map[string]bool{
+		scope:                                 true,
+		filepath.Join(scope, "deploy", "k8s"): true,
+		scope + string(filepath.Separator):    true,
+		scope + "-old":                        false,
+		filepath.Dir(scope):                   false,
+		filepath.Join(scope, "..", "other"):   false,
+		"":                                    false,
+	}
+	for dir, want := range cases {
+		if got := WithinScope(dir, scope); got != want {
+			t.Fatalf("WithinScope(%q, %q) = %v, want %v", dir, scope, got, want)
+		}
+	}
+}
+
 func newRetryTempDir(t *testing.T) string {
 	t.Helper()
 
@@ -259,3 +462,170 @@ func newRetryTempDir(t *testing.T) string {
 	})
 	return dir
 }
+
+func TestRecorderAuditOmitsAssignedValues(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newRetryTempDir(t)
+	sessionStore := store.NewJSONStore(root)
+	if err := sessionStore.Init(ctx); err != nil {
+		t.Fatalf("init store: %v", err)
+	}
+	if _, err := sessionStore.StartSession(ctx, "hooks", "", time.Now().UTC()); err != nil {
+		t.Fatalf("start session: %v", err)
+	}
+
+	rec := NewRecorder(sessionStore, policy.NewDefaultResolver(), nil)
+	for _, command := range []string{
+		"AWS_SECRET_ACCESS_KEY=hunter2 printenv",
+		"env DB_PASSWORD=hunter2 printenv",
+		"sudo -E TOKEN=hunter2 printenv",
+	} {
+		result, err := rec.Record(ctx, RecordInput{Command: command})
+		if err != nil {
+			t.Fatalf("record %q: %v", command, err)
+		}
+		if result.Step.Status != "REDACTED" {
+			t.Fatalf("expected %q to be denied, got %+v", command, result.Step)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, "audit.jsonl"))
+	if err != nil {
+		t.Fatalf("read audit log: %v", err)
+	}
+	if strings.Contains(string(data), "hunter2") || strings.Contains(strings.ToLower(string(data)), "secret_access_key") {
+		t.Fatalf("audit log holds an assigned value:\n%s", data)
+	}
+	events, err := sessionStore.AuditEvents(ctx, time.Time{})
+	if err != nil {
+		t.Fatalf("read audit events: %v", err)
+	}
+	for _, event := range events {
+		if event.Tool != "printenv" {
+			t.Fatalf("expected tool printenv, got %+v", event)
+		}
+	}
+}
+
+func TestRecorderWarnsWhenAuditWriteFails(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newRetryTempDir(t)
+	sessionStore := store.NewJSONStore(root)
+	if err := sessionStore.Init(ctx); err != nil {
+		t.Fatalf("init store: %v", err)
+	}
+	if _, err := sessionStore.StartSession(ctx, "hooks", "", time.Now().UTC()); err != nil {
+		t.Fatalf("start session: %v", err)
+	}
+	// A directory in place of audit.jsonl makes every append fail.
+	if err := os.Mkdir(filepath.Join(root, "audit.jsonl"), 0o700); err != nil {
+		t.Fatalf("create directory: %v", err)
+	}
+
+	result, err := NewRecorder(sessionStore, policy.NewDefaultResolver(), nil).Record(ctx, RecordInput{Command: "printenv"})
+	if err != nil {
+		t.Fatalf("expected the step to be recorded despite the audit failure, got %v", err)
+	}
+	if !result.Recorded || result.Warning == "" {
+		t.Fatalf("expected a recorded step with a warning, got %+v", result)
+	}
+	active, err := sessionStore.GetActiveSession(ctx)
+	if err != nil {
+		t.Fatalf("active session: %v", err)
+	}
+	if len(active.Steps) != 1 {
+		t.Fatalf("expected exactly one step, got %d", len(active.Steps))
+	}
+}
+
+func TestRecorderAllowlistChecksEverySegment(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := policy.ParseConfig("policy:\n  mode: allowlist\n  allowlist: [kubectl, git]\n")
+	if err != nil {
+		t.Fatalf("parse config: %v", err)
+	}
+	policies, err := policy.NewResolver(cfg)
+	if err != nil {
+		t.Fatalf("new resolver: %v", err)
+	}
+
+	for _, tc := range []struct {
+		command string
+		status  string
+	}{
+		{command: "kubectl get pods | grep web", status: "REDACTED"},
+		{command: "kubectl get pods; cat /etc/shadow", status: "REDACTED"},
+		{command: "git log && internal-tool --token x", status: "REDACTED"},
+		{command: "git fetch || kubectl get pods", status: "OK"},
+		{command: "git commit -m 'a; b'", status: "OK"},
+	} {
+		ctx := context.Background()
+		root := newRetryTempDir(t)
+		sessionStore := store.NewJSONStore(root)
+		if err := sessionStore.Init(ctx); err != nil {
+			t.Fatalf("init store: %v", err)
+		}
+		if _, err := sessionStore.StartSession(ctx, "hooks", "", time.Now().UTC()); err != nil {
+			t.Fatalf("start session: %v", err)
+		}
+
+		result, err := NewRecorder(sessionStore, policies, nil).Record(ctx, RecordInput{Command: tc.command})
+		if err != nil {
+			t.Fatalf("record %q: %v", tc.command, err)
+		}
+		if result.Step.Status != tc.status {
+			t.Fatalf("%q: expected status %s, got %+v", tc.command, tc.status, result.Step)
+		}
+		if tc.status == "REDACTED" && result.Step.Command != policy.DeniedPlaceholder {
+			t.Fatalf("%q: expected the command to be withheld, got %q", tc.command, result.Step.Command)
+		}
+	}
+}
+
+func TestRecorderNeverIgnoresDeniedLines(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := policy.ParseConfig("hooks:\n  ignore:\n    binaries: [cat]\n    min_duration_ms: 100\n")
+	if err != nil {
+		t.Fatalf("parse config: %v", err)
+	}
+	policies, err := policy.NewResolver(cfg)
+	if err != nil {
+		t.Fatalf("new resolver: %v", err)
+	}
+
+	ctx := context.Background()
+	root := newRetryTempDir(t)
+	sessionStore := store.NewJSONStore(root)
+	if err := sessionStore.Init(ctx); err != nil {
+		t.Fatalf("init store: %v", err)
+	}
+	if _, err := sessionStore.StartSession(ctx, "hooks", "", time.Now().UTC()); err != nil {
+		t.Fatalf("start session: %v", err)
+	}
+	rec := NewRecorder(sessionStore, policies, nil)
+
+	result, err := rec.Record(ctx, RecordInput{Command: "cat ~/.ssh/id_rsa", DurationMS: 5})
+	if err != nil {
+		t.Fatalf("record denied line: %v", err)
+	}
+	if !result.Recorded || result.Step.Status != "REDACTED" {
+		t.Fatalf("expected the denied line to be recorded, got %+v", result)
+	}
+	if result, err := rec.Record(ctx, RecordInput{Command: "cat notes.txt", DurationMS: 500}); err != nil || result.Recorded || result.SkippedReason != "ignored_binary" {
+		t.Fatalf("expected an allowed cat to be ignored, got %+v (%v)", result, err)
+	}
+
+	events, err := sessionStore.AuditEvents(ctx, time.Time{})
+	if err != nil {
+		t.Fatalf("audit events: %v", err)
+	}
+	if len(events) != 1 || events[0].Event != "policy_redacted" || events[0].Source != "hook" {
+		t.Fatalf("expected one hook audit event, got %+v", events)
+	}
+}
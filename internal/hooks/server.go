@@ -0,0 +1,246 @@
+package hooks
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	socketFileName     = "hookd.sock"
+	maxRequestBytes    = 1 << 20
+	connectionDeadline = 5 * time.Second
+)
+
+// ErrServerRunning is returned by Listen when another hookd answers on the socket.
+var ErrServerRunning = errors.New("hookd is already running")
+
+// SocketPath returns the per-user hookd socket inside the config root.
+func SocketPath(rootPath string) string {
+	return filepath.Join(rootPath, socketFileName)
+}
+
+// Listen opens the hookd Unix socket. A socket file left behind by a crashed
+// server is removed; a live server makes Listen fail with ErrServerRunning.
+func Listen(path string) (net.Listener, error) {
+	if _, err := os.Stat(path); err == nil {
+		conn, dialErr := net.DialTimeout("unix", path, time.Second)
+		if dialErr == nil {
+			conn.Close()
+			return nil, ErrServerRunning
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("remove stale socket: %w", err)
+		}
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("listen on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("restrict socket permissions: %w", err)
+	}
+	return ln, nil
+}
+
+// Server accepts record events from shell hooks and feeds them to a
+// long-lived Recorder, so each prompt avoids starting a cmdry process.
+//
+// One connection carries one event: `key: value` lines terminated by an
+// empty line, with backslashes and newlines in values escaped as \\ and \n.
+// The server answers with a single line: `ok`, `ok reminder`,
+// `skipped <reason>` or `error <message>`.
+type Server struct {
+	recorder *Recorder
+	log      io.Writer
+	// mu serializes Record calls: the reminder counter in the hooks state is
+	// a read-modify-write.
+	mu sync.Mutex
+}
+
+// NewServer returns a Server that records with recorder. Warnings about
+// recorded events, such as a failed audit log write, go to log; a nil log
+// discards them.
+func NewServer(recorder *Recorder, log io.Writer) *Server {
+	if log == nil {
+		log = io.Discard
+	}
+	return &Server{recorder: recorder, log: log}
+}
+
+// Serve handles connections until ctx is cancelled or ln fails. The listener
+// is closed on return.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			ln.Close()
+		case <-stop:
+		}
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			ln.Close()
+			return fmt.Errorf("accept: %w", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.handle(ctx, conn)
+		}()
+	}
+}
+
+func (s *Server) handle(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(connectionDeadline))
+
+	input, err := DecodeRecordRequest(bufio.NewReader(io.LimitReader(conn, maxRequestBytes)))
+	if err != nil {
+		fmt.Fprintf(conn, "error %s\n", escapeValue(err.Error()))
+		return
+	}
+	s.mu.Lock()
+	result, err := s.recorder.Record(ctx, input)
+	s.mu.Unlock()
+	if result.Warning != "" {
+		fmt.Fprintf(s.log, "hookd: %s\n", result.Warning)
+	}
+	switch {
+	case err != nil:
+		fmt.Fprintf(conn, "error %s\n", escapeValue(err.Error()))
+	case !result.Recorded:
+		fmt.Fprintf(conn, "skipped %s\n", result.SkippedReason)
+	case result.Reminder:
+		fmt.Fprintln(conn, "ok reminder")
+	default:
+		fmt.Fprintln(conn, "ok")
+	}
+}
+
+// EncodeRecordRequest renders input in the hookd wire format.
+func EncodeRecordRequest(input RecordInput) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "command: %s\n", escapeValue(input.Command))
+	fmt.Fprintf(&b, "exit-code: %d\n", input.ExitCode)
+	fmt.Fprintf(&b, "duration-ms: %d\n", input.DurationMS)
+	if input.CWD != "" {
+		fmt.Fprintf(&b, "cwd: %s\n", escapeValue(input.CWD))
+	}
+	if input.ShellToken != "" {
+		fmt.Fprintf(&b, "shell-token: %s\n", escapeValue(input.ShellToken))
+	}
+	if !input.Timestamp.IsZero() {
+		fmt.Fprintf(&b, "timestamp: %s\n", input.Timestamp.UTC().Format(time.RFC3339Nano))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// DecodeRecordRequest reads one event. Unknown keys are ignored so newer
+// hook blocks keep working against an older server; a missing duration-ms
+// means the duration was not measured.
+func DecodeRecordRequest(r *bufio.Reader) (RecordInput, error) {
+	var (
+		input      = RecordInput{DurationMS: -1}
+		hasCommand bool
+	)
+	for {
+		line, readErr := r.ReadString('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return RecordInput{}, fmt.Errorf("read request: %w", readErr)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return RecordInput{}, fmt.Errorf("malformed request line %q", line)
+		}
+		value = unescapeValue(strings.TrimPrefix(value, " "))
+		switch strings.TrimSpace(key) {
+		case "command":
+			input.Command = value
+			hasCommand = true
+		case "exit-code":
+			code, err := ParseExitCode(value)
+			if err != nil {
+				return RecordInput{}, err
+			}
+			input.ExitCode = code
+		case "duration-ms":
+			ms, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			if err != nil {
+				return RecordInput{}, fmt.Errorf("parse duration-ms: %w", err)
+			}
+			input.DurationMS = ms
+		case "cwd":
+			input.CWD = value
+		case "shell-token":
+			input.ShellToken = value
+		case "timestamp":
+			ts, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(value))
+			if err != nil {
+				return RecordInput{}, fmt.Errorf("parse timestamp: %w", err)
+			}
+			input.Timestamp = ts
+		}
+		if readErr != nil {
+			break
+		}
+	}
+	if !hasCommand {
+		return RecordInput{}, errors.New("request has no command")
+	}
+	return input, nil
+}
+
+func escapeValue(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	return strings.ReplaceAll(v, "\n", `\n`)
+}
+
+func unescapeValue(v string) string {
+	if !strings.Contains(v, `\`) {
+		return v
+	}
+	var b strings.Builder
+	for i := 0; i < len(v); i++ {
+		if v[i] == '\\' && i+1 < len(v) {
+			switch v[i+1] {
+			case '\\':
+				b.WriteByte('\\')
+				i++
+				continue
+			case 'n':
+				b.WriteByte('\n')
+				i++
+				continue
+			}
+		}
+		b.WriteByte(v[i])
+	}
+	return b.String()
+}
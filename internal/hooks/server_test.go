@@ -0,0 +1,142 @@
+package hooks
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fixi2/Commandry/internal/policy"
+	"github.com/fixi2/Commandry/internal/store"
+)
+
+func TestRecordRequestRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	in := RecordInput{
+		Command:    "printf 'a\\\\b'\necho done",
+		CWD:        `/srv/app\dir`,
+		ExitCode:   3,
+		DurationMS: 1250,
+		Timestamp:  time.Date(2026, 3, 4, 10, 0, 0, 123000000, time.UTC),
+		ShellToken: "4242-1337",
+	}
+	wire := EncodeRecordRequest(in)
+	if strings.Count(wire, "\n") != 7 {
+		t.Fatalf("expected escaped single-line fields, got %q", wire)
+	}
+
+	got, err := DecodeRecordRequest(bufio.NewReader(strings.NewReader(wire)))
+	if err != nil {
+		t.Fatalf("DecodeRecordRequest failed: %v", err)
+	}
+	if got.Command != in.Command || got.CWD != in.CWD || got.ExitCode != in.ExitCode ||
+		got.DurationMS != in.DurationMS || !got.Timestamp.Equal(in.Timestamp) || got.ShellToken != in.ShellToken {
+		t.Fatalf("round trip mismatch\n got: %#v\nwant: %#v", got, in)
+	}
+
+	if _, err := DecodeRecordRequest(bufio.NewReader(strings.NewReader("exit-code: 0\n\n"))); err == nil {
+		t.Fatal("expected error for request without command")
+	}
+	if _, err := DecodeRecordRequest(bufio.NewReader(strings.NewReader("command: ls\nexit-code: x\n\n"))); err == nil {
+		t.Fatal("expected error for invalid exit code")
+	}
+}
+
+func TestServerRecordsOverSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix socket test")
+	}
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	root := newRetryTempDir(t)
+	sessionStore := store.NewJSONStore(root)
+	if err := sessionStore.Init(ctx); err != nil {
+		t.Fatalf("init store: %v", err)
+	}
+	if _, err := sessionStore.StartSession(ctx, "hookd", "", time.Now().UTC()); err != nil {
+		t.Fatalf("start session: %v", err)
+	}
+	stateStore := NewFileStateStore(root)
+	state := defaultState()
+	state.Enabled = true
+	if err := stateStore.Save(ctx, state); err != nil {
+		t.Fatalf("save state: %v", err)
+	}
+
+	// Keep the socket path short: sun_path is limited to ~104 bytes on macOS.
+	sockDir, err := os.MkdirTemp("", "cmdry")
+	if err != nil {
+		t.Fatalf("mkdir temp: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(sockDir) })
+	sockPath := SocketPath(sockDir)
+
+	ln, err := Listen(sockPath)
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	if _, err := Listen(sockPath); !errors.Is(err, ErrServerRunning) {
+		t.Fatalf("expected ErrServerRunning for a live socket, got %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- NewServer(NewRecorder(sessionStore, policy.NewDefaultResolver(), stateStore), nil).Serve(ctx, ln)
+	}()
+
+	send := func(payload string) string {
+		t.Helper()
+		conn, err := net.Dial("unix", sockPath)
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		defer conn.Close()
+		if _, err := conn.Write([]byte(payload)); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		reply, err := bufio.NewReader(conn).ReadString('\n')
+		if err != nil {
+			t.Fatalf("read reply: %v", err)
+		}
+		return strings.TrimSpace(reply)
+	}
+
+	if reply := send(EncodeRecordRequest(RecordInput{Command: "make build", CWD: root, ExitCode: 2, DurationMS: 40})); reply != "ok" {
+		t.Fatalf("unexpected reply %q", reply)
+	}
+	if reply := send(EncodeRecordRequest(RecordInput{Command: "cmdry status"})); reply != "skipped self_command" {
+		t.Fatalf("unexpected reply for self command %q", reply)
+	}
+	if reply := send("garbage\n\n"); !strings.HasPrefix(reply, "error ") {
+		t.Fatalf("expected error reply, got %q", reply)
+	}
+
+	active, err := sessionStore.GetActiveSession(ctx)
+	if err != nil {
+		t.Fatalf("active session: %v", err)
+	}
+	if len(active.Steps) != 1 || active.Steps[0].Command != "make build" || active.Steps[0].DurationMS != 40 {
+		t.Fatalf("unexpected steps: %+v", active.Steps)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Serve returned error: %v", err)
+	}
+
+	// The socket file survives Serve; Listen must treat it as stale.
+	ln2, err := Listen(sockPath)
+	if err != nil {
+		t.Fatalf("Listen over stale socket failed: %v", err)
+	}
+	ln2.Close()
+}
@@ -19,6 +19,37 @@ type State struct {
 	RemindEvery  int       `json:"remind_every"`
 	CommandCount int64     `json:"command_count"`
 	UpdatedAt    time.Time `json:"updated_at"`
+	// Profiles maps a shell to the profile files its hook was installed into,
+	// so uninstall and status find custom --profile targets again.
+	Profiles map[string][]string `json:"profiles,omitempty"`
+}
+
+// AddProfile records that the hook for shell was written to path.
+func (s *State) AddProfile(shell, path string) {
+	for _, existing := range s.Profiles[shell] {
+		if existing == path {
+			return
+		}
+	}
+	if s.Profiles == nil {
+		s.Profiles = make(map[string][]string)
+	}
+	s.Profiles[shell] = append(s.Profiles[shell], path)
+}
+
+// RemoveProfile forgets path for shell.
+func (s *State) RemoveProfile(shell, path string) {
+	kept := s.Profiles[shell][:0]
+	for _, existing := range s.Profiles[shell] {
+		if existing != path {
+			kept = append(kept, existing)
+		}
+	}
+	if len(kept) == 0 {
+		delete(s.Profiles, shell)
+		return
+	}
+	s.Profiles[shell] = kept
 }
 
 type StateStore interface {
@@ -76,3 +76,32 @@ func TestFileStateStoreAllowsDisabledReminder(t *testing.T) {
 		t.Fatalf("expected remind every to stay disabled (0), got %d", got.RemindEvery)
 	}
 }
+
+func TestStateProfiles(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := NewFileStateStore(t.TempDir())
+	state := defaultState()
+	state.AddProfile("bash", "/home/u/.bashrc")
+	state.AddProfile("bash", "/home/u/.bash_profile")
+	state.AddProfile("bash", "/home/u/.bashrc")
+	if err := store.Save(ctx, state); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	loaded, err := store.Load(ctx)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if got := loaded.Profiles["bash"]; len(got) != 2 || got[1] != "/home/u/.bash_profile" {
+		t.Fatalf("unexpected profiles after round trip: %v", loaded.Profiles)
+	}
+
+	loaded.RemoveProfile("bash", "/home/u/.bashrc")
+	loaded.RemoveProfile("bash", "/home/u/.bash_profile")
+	loaded.RemoveProfile("zsh", "/home/u/.zshrc")
+	if len(loaded.Profiles) != 0 {
+		t.Fatalf("expected no profiles left, got %v", loaded.Profiles)
+	}
+}
@@ -0,0 +1,107 @@
+package policy
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Policy modes for `policy.mode`.
+const (
+	// ModeDenylist records every command except denylist matches.
+	ModeDenylist = "denylist"
+	// ModeAllowlist records only commands listed in `policy.allowlist`; the
+	// denylist still applies to those.
+	ModeAllowlist = "allowlist"
+)
+
+// allowRule is a `policy.allowlist` entry such as `kubectl get`: a binary
+// followed by the subcommand words its positional arguments must start with.
+// Every word may use `*` and `?` wildcards.
+type allowRule struct {
+	entry string
+	tool  string
+	words []string
+}
+
+func compileAllowlist(entries []string) ([]allowRule, error) {
+	rules := make([]allowRule, 0, len(entries))
+	for _, entry := range entries {
+		rule, err := compileAllowRule(entry)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func compileAllowRule(entry string) (allowRule, error) {
+	words := strings.Fields(entry)
+	if len(words) == 0 {
+		return allowRule{}, fmt.Errorf("allowlist entry cannot be empty")
+	}
+	rule := allowRule{entry: strings.Join(words, " "), tool: toolName(words[0]), words: words[1:]}
+	for _, word := range append([]string{rule.tool}, rule.words...) {
+		if _, err := path.Match(word, ""); err != nil {
+			return allowRule{}, fmt.Errorf("invalid allowlist entry %q: bad pattern %q", entry, word)
+		}
+	}
+	return rule, nil
+}
+
+// allows matches the rule against argv. Flags are skipped when matching
+// subcommand words, so `kubectl --context prod get` has the positional
+// arguments `prod get` and does not match `kubectl get`.
+func (r allowRule) allows(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(r.tool, toolName(args[0])); !ok {
+		return false
+	}
+	positional := make([]string, 0, len(args)-1)
+	for _, arg := range args[1:] {
+		if !strings.HasPrefix(arg, "-") {
+			positional = append(positional, arg)
+		}
+	}
+	if len(positional) < len(r.words) {
+		return false
+	}
+	for i, word := range r.words {
+		if ok, _ := path.Match(word, positional[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// allowed reports whether args pass the allowlist. It is always true in
+// denylist mode.
+func (p *Policy) allowed(args []string, trace *Trace) bool {
+	if p.mode != ModeAllowlist {
+		return true
+	}
+	text := ""
+	if len(args) > 0 {
+		text = args[0]
+	}
+	for _, rule := range p.allowlist {
+		if rule.allows(args) {
+			if trace != nil {
+				trace.add(Match{Kind: MatchAllow, Rule: rule.entry, Start: -1, End: -1, Text: text})
+			}
+			return true
+		}
+	}
+	if trace != nil {
+		trace.add(Match{Kind: MatchDeny, Rule: "allowlist: not listed", Start: -1, End: -1, Text: text})
+	}
+	return false
+}
+
+// Mode returns ModeDenylist or ModeAllowlist.
+func (p *Policy) Mode() string {
+	return p.mode
+}
@@ -1,15 +1,135 @@
 package policy
 
 import (
+	"bytes"
 	"fmt"
 	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Export annotate modes for `export.annotate`.
+const (
+	AnnotateAuto   = "auto"
+	AnnotateAlways = "always"
+	AnnotateNever  = "never"
 )
 
 type Config struct {
 	Denylist          []string
 	RedactionKeywords []string
-	EnforceDenylist   bool
+	// RedactionRules comes from `policy.redaction_rules`.
+	RedactionRules []RedactionRule
+	// Detectors comes from `policy.detectors` and holds every built-in
+	// detector with its on/off state.
+	Detectors       map[string]bool
+	EnforceDenylist bool
+	// Mode is `policy.mode`, ModeDenylist or ModeAllowlist.
+	Mode      string
+	Allowlist []string
+	Guarded   []GuardRule
+	// IncludeStdout and IncludeStderr are reserved: Commandry does not
+	// record command output yet.
+	IncludeStdout bool
+	IncludeStderr bool
+	// ExpectedExitCodes comes from `capture.expected_exit_codes`. Entries are
+	// merged over the defaults; an empty list removes a tool.
+	ExpectedExitCodes map[string][]int
+	// HookIgnore comes from `hooks.ignore`. A listed key replaces its default;
+	// an empty list clears it.
+	HookIgnore HookIgnore
+	// ProjectRoot is `hooks.project_root`, the default `cmdry start --scope`.
+	ProjectRoot string
+	// Export holds the `export` defaults used when no flag overrides them.
+	Export ExportSettings
+	// Profiles comes from `policy.profiles`, keyed by session env. See ForEnv.
+	Profiles map[string]PolicyProfile
+	// Project controls how a .commandry.yaml is merged. See MergeProject.
+	Project ProjectSettings
+}
+
+// ExportSettings are the `cmdry export` defaults.
+type ExportSettings struct {
+	// Format is used when neither --md nor --format is passed.
+	Format string
+	// Annotate is auto (prompt in interactive terminals), always or never.
+	Annotate string
+}
+
+var defaultExportSettings = ExportSettings{Format: "md", Annotate: AnnotateAuto}
+
+// ProjectSettings come from the `project` section of config.yaml.
+type ProjectSettings struct {
+	// Enabled merges the .commandry.yaml found from the working directory.
+	Enabled bool
+	// AllowWeaken lets that file turn off enforce_denylist and detectors.
+	AllowWeaken bool
+}
+
+// ConfigProblem is one schema or value error in config.yaml. Line is 0 when
+// the position is unknown.
+type ConfigProblem struct {
+	Line    int
+	Message string
+}
+
+func (p ConfigProblem) String() string {
+	if p.Line <= 0 {
+		return p.Message
+	}
+	return fmt.Sprintf("line %d: %s", p.Line, p.Message)
+}
+
+// ConfigError lists every problem found in a config file.
+type ConfigError struct {
+	Problems []ConfigProblem
+}
+
+func (e *ConfigError) Error() string {
+	parts := make([]string, 0, len(e.Problems))
+	for _, problem := range e.Problems {
+		parts = append(parts, problem.String())
+	}
+	return "invalid config: " + strings.Join(parts, "; ")
+}
+
+// DefaultConfig returns the built-in configuration used for missing keys.
+func DefaultConfig() Config {
+	return Config{
+		Denylist:          append([]string(nil), defaultDenylistPatterns...),
+		RedactionKeywords: append([]string(nil), defaultRedactionKeywords...),
+		Detectors:         defaultDetectorToggles(),
+		EnforceDenylist:   false,
+		Mode:              ModeDenylist,
+		Guarded:           cloneGuardRules(defaultGuardedRules),
+		ExpectedExitCodes: cloneExpectedExitCodes(defaultExpectedExitCodes),
+		HookIgnore:        cloneHookIgnore(defaultHookIgnore),
+		Export:            defaultExportSettings,
+		Project:           ProjectSettings{Enabled: true},
+	}
+}
+
+// Options converts the config into policy options.
+func (c Config) Options() Options {
+	return Options{
+		DenylistPatterns:  c.Denylist,
+		RedactionKeywords: c.RedactionKeywords,
+		RedactionRules:    c.RedactionRules,
+		Detectors:         c.Detectors,
+		EnforceDenylist:   c.EnforceDenylist,
+		Mode:              c.Mode,
+		Allowlist:         c.Allowlist,
+		Guarded:           c.Guarded,
+		ExpectedExitCodes: c.ExpectedExitCodes,
+		HookIgnore:        c.HookIgnore,
+		ProjectRoot:       c.ProjectRoot,
+		Export:            c.Export,
+	}
 }
 
 func ParseConfigFile(path string) (Config, error) {
@@ -20,115 +140,636 @@ func ParseConfigFile(path string) (Config, error) {
 	return ParseConfig(string(data))
 }
 
+// ParseConfig reads config.yaml content over the defaults. Unknown keys, wrong
+// value types and invalid values are reported together as a *ConfigError.
 func ParseConfig(content string) (Config, error) {
+	cfg := DefaultConfig()
+	var file fileConfig
+	lines, err := decodeConfig(content, &file)
+	if err != nil {
+		return Config{}, err
+	}
+	if problems := file.apply(&cfg, lines); len(problems) > 0 {
+		return Config{}, &ConfigError{Problems: problems}
+	}
+	return cfg, nil
+}
+
+// decodeConfig checks content against the type out points to and decodes it.
+// It returns the line of every key path for later value checks.
+func decodeConfig(content string, out any) (map[string]int, error) {
 	content = strings.TrimPrefix(content, "\ufeff")
-	cfg := Config{
-		Denylist:          append([]string(nil), defaultDenylistPatterns...),
-		RedactionKeywords: append([]string(nil), defaultRedactionKeywords...),
-		EnforceDenylist:   false,
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(content), &doc); err != nil {
+		return nil, &ConfigError{Problems: []ConfigProblem{yamlSyntaxProblem(err)}}
+	}
+	checker := schemaChecker{lines: make(map[string]int)}
+	if len(doc.Content) == 0 {
+		return checker.lines, nil
 	}
 
-	var (
-		inPolicy      bool
-		currentList   string
-		denylistSeen  bool
-		keywordsSeen  bool
-		parsedDeny    []string
-		parsedKeyword []string
-	)
-
-	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
-	for idx, raw := range lines {
-		raw = strings.TrimPrefix(raw, "\ufeff")
-		line := strings.TrimRight(raw, " \t")
-		trim := strings.TrimSpace(line)
-		if trim == "" || strings.HasPrefix(trim, "#") {
-			continue
+	checker.check(doc.Content[0], reflect.TypeOf(out).Elem(), "")
+	if len(checker.problems) > 0 {
+		return nil, &ConfigError{Problems: checker.problems}
+	}
+	if err := doc.Decode(out); err != nil {
+		return nil, fmt.Errorf("parse config: %w", err)
+	}
+	return checker.lines, nil
+}
+
+// FormatConfig renders cfg as config.yaml with every key spelled out.
+func FormatConfig(cfg Config) ([]byte, error) {
+	file := newFileConfig(cfg)
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(file); err != nil {
+		return nil, fmt.Errorf("format config: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("format config: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// fileConfig is the on-disk shape of config.yaml. Pointer fields tell a
+// missing key (keep the default) from an explicit value.
+type fileConfig struct {
+	Policy  *policySection  `yaml:"policy,omitempty"`
+	Capture *captureSection `yaml:"capture,omitempty"`
+	Hooks   *hooksSection   `yaml:"hooks,omitempty"`
+	Export  *exportSection  `yaml:"export,omitempty"`
+	Project *projectSection `yaml:"project,omitempty"`
+}
+
+type policySection struct {
+	policyKeys `yaml:",inline"`
+	// Profiles maps a session env to overrides of the keys above.
+	Profiles *map[string]policyKeys `yaml:"profiles,omitempty"`
+}
+
+// policyKeys are the `policy` keys a profile can override.
+type policyKeys struct {
+	Denylist          *[]string             `yaml:"denylist,omitempty"`
+	RedactionKeywords *[]string             `yaml:"redaction_keywords,omitempty"`
+	RedactionRules    *[]redactionRuleEntry `yaml:"redaction_rules,omitempty"`
+	Detectors         *map[string]bool      `yaml:"detectors,omitempty"`
+	EnforceDenylist   *bool                 `yaml:"enforce_denylist,omitempty"`
+	Mode              *string               `yaml:"mode,omitempty"`
+	Allowlist         *[]string             `yaml:"allowlist,omitempty"`
+	Guarded           *[]guardEntry         `yaml:"guarded,omitempty"`
+}
+
+type redactionRuleEntry struct {
+	Name    string     `yaml:"name"`
+	Pattern string     `yaml:"pattern"`
+	Replace string     `yaml:"replace,omitempty"`
+	Tools   stringList `yaml:"tools,omitempty"`
+}
+
+// guardEntry is a `policy.guarded` item: a plain pattern or a mapping with
+// `pattern` and `envs`.
+type guardEntry struct {
+	Pattern string     `yaml:"pattern"`
+	Envs    stringList `yaml:"envs,omitempty"`
+}
+
+func (g *guardEntry) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		g.Pattern = node.Value
+		return nil
+	}
+	type plain guardEntry
+	return node.Decode((*plain)(g))
+}
+
+type captureSection struct {
+	IncludeStdout     *bool                `yaml:"include_stdout"`
+	IncludeStderr     *bool                `yaml:"include_stderr"`
+	ExpectedExitCodes *map[string]flowInts `yaml:"expected_exit_codes"`
+}
+
+type hooksSection struct {
+	ProjectRoot *string        `yaml:"project_root,omitempty"`
+	Ignore      *ignoreSection `yaml:"ignore"`
+}
+
+type ignoreSection struct {
+	Commands        *[]string   `yaml:"commands"`
+	Binaries        *stringList `yaml:"binaries"`
+	Regexes         *[]string   `yaml:"regexes"`
+	MinDurationMS   *int64      `yaml:"min_duration_ms"`
+	CommandNotFound *bool       `yaml:"command_not_found"`
+}
+
+type exportSection struct {
+	Format   *string `yaml:"format"`
+	Annotate *string `yaml:"annotate"`
+}
+
+type projectSection struct {
+	Enabled     *bool `yaml:"enabled"`
+	AllowWeaken *bool `yaml:"allow_weaken"`
+}
+
+// stringList accepts a single scalar as a one-item list and is written in
+// flow style.
+type stringList []string
+
+func (l *stringList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*l = stringList{node.Value}
+		return nil
+	}
+	var items []string
+	if err := node.Decode(&items); err != nil {
+		return err
+	}
+	*l = items
+	return nil
+}
+
+func (l stringList) MarshalYAML() (any, error) {
+	return flowSequence(l), nil
+}
+
+// flowInts is an exit code list, written in flow style.
+type flowInts []int
+
+func (l flowInts) MarshalYAML() (any, error) {
+	items := make([]string, 0, len(l))
+	for _, code := range l {
+		items = append(items, strconv.Itoa(code))
+	}
+	node := flowSequence(items)
+	for _, item := range node.Content {
+		item.Tag = "!!int"
+	}
+	return node, nil
+}
+
+func flowSequence(items []string) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+	for _, item := range items {
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: item})
+	}
+	return node
+}
+
+// apply merges the parsed file over cfg. lines maps key paths to the line of
+// their value for error messages.
+func (f fileConfig) apply(cfg *Config, lines map[string]int) []ConfigProblem {
+	var problems []ConfigProblem
+	problem := func(path, format string, args ...any) {
+		problems = append(problems, ConfigProblem{Line: lines[path], Message: path + " " + fmt.Sprintf(format, args...)})
+	}
+
+	if p := f.Policy; p != nil {
+		cfg.applyProfile(p.policyKeys.profile("policy", lines, problem))
+		if p.Profiles != nil {
+			cfg.Profiles = parseProfiles(*p.Profiles, lines, problem)
+		}
+		if cfg.Mode == ModeAllowlist && len(cfg.Allowlist) == 0 {
+			problem("policy.mode", "is allowlist but policy.allowlist is empty")
 		}
+		for name := range cfg.Profiles {
+			if env := cfg.ForEnv(name); env.Mode == ModeAllowlist && len(env.Allowlist) == 0 {
+				problem("policy.profiles."+name, "uses allowlist mode with an empty allowlist")
+			}
+		}
+	}
 
-		if !strings.HasPrefix(line, " ") {
-			currentList = ""
-			inPolicy = strings.HasPrefix(trim, "policy:")
-			continue
+	if c := f.Capture; c != nil {
+		if c.IncludeStdout != nil {
+			cfg.IncludeStdout = *c.IncludeStdout
 		}
-		if !inPolicy {
-			continue
+		if c.IncludeStderr != nil {
+			cfg.IncludeStderr = *c.IncludeStderr
+		}
+		if c.ExpectedExitCodes != nil {
+			for key, codes := range *c.ExpectedExitCodes {
+				tool := toolName(key)
+				if tool == "" {
+					problem("capture.expected_exit_codes."+key, "is not a tool name")
+					continue
+				}
+				if len(codes) == 0 {
+					delete(cfg.ExpectedExitCodes, tool)
+					continue
+				}
+				cfg.ExpectedExitCodes[tool] = append([]int(nil), codes...)
+			}
 		}
+	}
 
-		if strings.HasPrefix(line, "  ") && !strings.HasPrefix(line, "    ") {
-			currentList = ""
-			key, value, hasValue := splitKeyValue(strings.TrimSpace(line))
-			switch key {
-			case "denylist":
-				denylistSeen = true
-				parsedDeny = parsedDeny[:0]
-				currentList = "denylist"
-			case "redaction_keywords":
-				keywordsSeen = true
-				parsedKeyword = parsedKeyword[:0]
-				currentList = "redaction_keywords"
-			case "enforce_denylist":
-				if !hasValue {
-					return Config{}, fmt.Errorf("parse policy config line %d: enforce_denylist requires a boolean value", idx+1)
+	if h := f.Hooks; h != nil {
+		if h.ProjectRoot != nil {
+			cfg.ProjectRoot = strings.TrimSpace(*h.ProjectRoot)
+		}
+		if ig := h.Ignore; ig != nil {
+			ignore := &cfg.HookIgnore
+			if ig.Commands != nil {
+				ignore.Commands = nonEmptyItems(*ig.Commands)
+			}
+			if ig.Binaries != nil {
+				ignore.Binaries = nonEmptyItems(*ig.Binaries)
+			}
+			if ig.Regexes != nil {
+				ignore.Regexes = nonEmptyItems(*ig.Regexes)
+				for i, expr := range *ig.Regexes {
+					if _, err := regexp.Compile(expr); err != nil {
+						problem(fmt.Sprintf("hooks.ignore.regexes[%d]", i), "is not a valid regular expression: %v", err)
+					}
 				}
-				value = strings.ToLower(strings.TrimSpace(value))
-				switch value {
-				case "true":
-					cfg.EnforceDenylist = true
-				case "false":
-					cfg.EnforceDenylist = false
-				default:
-					return Config{}, fmt.Errorf("parse policy config line %d: enforce_denylist must be true or false", idx+1)
+			}
+			if ig.MinDurationMS != nil {
+				if *ig.MinDurationMS < 0 {
+					problem("hooks.ignore.min_duration_ms", "must be a non-negative integer")
 				}
+				ignore.MinDurationMS = *ig.MinDurationMS
+			}
+			if ig.CommandNotFound != nil {
+				ignore.CommandNotFound = *ig.CommandNotFound
 			}
+		}
+	}
+
+	if e := f.Export; e != nil {
+		if e.Format != nil {
+			format := strings.ToLower(strings.TrimSpace(*e.Format))
+			if format != "md" {
+				problem("export.format", "must be md")
+			}
+			cfg.Export.Format = format
+		}
+		if e.Annotate != nil {
+			annotate := strings.ToLower(strings.TrimSpace(*e.Annotate))
+			switch annotate {
+			case AnnotateAuto, AnnotateAlways, AnnotateNever:
+			default:
+				problem("export.annotate", "must be auto, always or never")
+			}
+			cfg.Export.Annotate = annotate
+		}
+	}
+
+	if p := f.Project; p != nil {
+		if p.Enabled != nil {
+			cfg.Project.Enabled = *p.Enabled
+		}
+		if p.AllowWeaken != nil {
+			cfg.Project.AllowWeaken = *p.AllowWeaken
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].Line < problems[j].Line })
+	return problems
+}
+
+func parseProfiles(entries map[string]policyKeys, lines map[string]int, problem func(path, format string, args ...any)) map[string]PolicyProfile {
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	profiles := make(map[string]PolicyProfile, len(names))
+	for _, name := range names {
+		path := "policy.profiles." + name
+		env := strings.TrimSpace(name)
+		if env == "" {
+			problem(path, "is not an environment name")
 			continue
 		}
+		if other, ok := profileName(profiles, env); ok {
+			problem(path, "duplicates profile %q; env names are case-insensitive", other)
+			continue
+		}
+		profiles[env] = entries[name].profile(path, lines, problem)
+	}
+	return profiles
+}
 
-		if strings.HasPrefix(line, "    - ") && currentList != "" {
-			item := strings.TrimSpace(strings.TrimPrefix(line, "    - "))
-			item = trimMatchingQuotes(item)
-			if item == "" {
+// profile validates the policy keys at path and returns them as overrides.
+func (k policyKeys) profile(path string, lines map[string]int, problem func(path, format string, args ...any)) PolicyProfile {
+	var prof PolicyProfile
+	if k.Denylist != nil {
+		prof.Denylist = nonEmptyItems(*k.Denylist)
+	}
+	if k.RedactionKeywords != nil {
+		prof.RedactionKeywords = nonEmptyItems(*k.RedactionKeywords)
+	}
+	if k.RedactionRules != nil {
+		prof.RedactionRules = make([]RedactionRule, 0, len(*k.RedactionRules))
+		seen := make(map[string]bool, len(*k.RedactionRules))
+		for i, entry := range *k.RedactionRules {
+			path := fmt.Sprintf("%s.redaction_rules[%d]", path, i)
+			rule := RedactionRule{
+				Name:    strings.TrimSpace(entry.Name),
+				Pattern: entry.Pattern,
+				Replace: entry.Replace,
+			}
+			if len(entry.Tools) > 0 {
+				rule.Tools = nonEmptyItems(entry.Tools)
+			}
+			switch {
+			case rule.Name == "":
+				problem(path, "requires a name")
+				continue
+			case seen[rule.Name]:
+				problem(path+".name", "%q is already defined", rule.Name)
 				continue
 			}
-			switch currentList {
-			case "denylist":
-				parsedDeny = append(parsedDeny, item)
-			case "redaction_keywords":
-				parsedKeyword = append(parsedKeyword, item)
+			seen[rule.Name] = true
+			if _, err := compileRedactionRule(rule); err != nil {
+				if _, ok := lines[path+".pattern"]; ok {
+					path += ".pattern"
+				}
+				problem(path, "is invalid: %v", err)
+				continue
+			}
+			prof.RedactionRules = append(prof.RedactionRules, rule)
+		}
+	}
+	if k.Detectors != nil {
+		known := defaultDetectorToggles()
+		for name, enabled := range *k.Detectors {
+			if !known[name] {
+				problem(path+".detectors."+name, "is not a detector (known: %s)", strings.Join(DetectorNames(), ", "))
+				continue
+			}
+			if prof.Detectors == nil {
+				prof.Detectors = make(map[string]bool, len(*k.Detectors))
+			}
+			prof.Detectors[name] = enabled
+		}
+	}
+	if k.EnforceDenylist != nil {
+		enforce := *k.EnforceDenylist
+		prof.EnforceDenylist = &enforce
+	}
+	if k.Mode != nil {
+		mode := strings.ToLower(strings.TrimSpace(*k.Mode))
+		switch mode {
+		case ModeDenylist, ModeAllowlist:
+			prof.Mode = mode
+		default:
+			problem(path+".mode", "must be denylist or allowlist")
+		}
+	}
+	if k.Allowlist != nil {
+		prof.Allowlist = make([]string, 0, len(*k.Allowlist))
+		for i, entry := range *k.Allowlist {
+			rule, err := compileAllowRule(entry)
+			if err != nil {
+				problem(fmt.Sprintf("%s.allowlist[%d]", path, i), "is invalid: %v", err)
+				continue
+			}
+			prof.Allowlist = append(prof.Allowlist, rule.entry)
+		}
+	}
+	if k.Guarded != nil {
+		// An explicit `guarded: []` disables the inherited guards.
+		prof.Guarded = make([]GuardRule, 0, len(*k.Guarded))
+		for i, entry := range *k.Guarded {
+			pattern := strings.TrimSpace(entry.Pattern)
+			if pattern == "" {
+				problem(fmt.Sprintf("%s.guarded[%d]", path, i), "requires a pattern")
+				continue
+			}
+			rule := GuardRule{Pattern: pattern}
+			if len(entry.Envs) > 0 {
+				rule.Envs = nonEmptyItems(entry.Envs)
+			}
+			prof.Guarded = append(prof.Guarded, rule)
+		}
+	}
+	return prof
+}
+
+func newPolicyKeys(prof PolicyProfile) policyKeys {
+	var keys policyKeys
+	if prof.Denylist != nil {
+		keys.Denylist = &prof.Denylist
+	}
+	if prof.RedactionKeywords != nil {
+		keys.RedactionKeywords = &prof.RedactionKeywords
+	}
+	if prof.RedactionRules != nil {
+		rules := make([]redactionRuleEntry, 0, len(prof.RedactionRules))
+		for _, rule := range prof.RedactionRules {
+			rules = append(rules, redactionRuleEntry{Name: rule.Name, Pattern: rule.Pattern, Replace: rule.Replace, Tools: rule.Tools})
+		}
+		keys.RedactionRules = &rules
+	}
+	if len(prof.Detectors) > 0 {
+		keys.Detectors = &prof.Detectors
+	}
+	keys.EnforceDenylist = prof.EnforceDenylist
+	if prof.Mode != "" {
+		keys.Mode = &prof.Mode
+	}
+	if prof.Allowlist != nil {
+		keys.Allowlist = &prof.Allowlist
+	}
+	if prof.Guarded != nil {
+		guarded := make([]guardEntry, 0, len(prof.Guarded))
+		for _, rule := range prof.Guarded {
+			guarded = append(guarded, guardEntry{Pattern: rule.Pattern, Envs: rule.Envs})
+		}
+		keys.Guarded = &guarded
+	}
+	return keys
+}
+
+func newFileConfig(cfg Config) fileConfig {
+	base := newPolicyKeys(cfg.policyProfile())
+	exitCodes := make(map[string]flowInts, len(cfg.ExpectedExitCodes))
+	for tool, codes := range cfg.ExpectedExitCodes {
+		exitCodes[tool] = codes
+	}
+	binaries := stringList(cfg.HookIgnore.Binaries)
+
+	file := fileConfig{
+		Policy: &policySection{policyKeys: base},
+		Capture: &captureSection{
+			IncludeStdout:     &cfg.IncludeStdout,
+			IncludeStderr:     &cfg.IncludeStderr,
+			ExpectedExitCodes: &exitCodes,
+		},
+		Hooks: &hooksSection{
+			Ignore: &ignoreSection{
+				Commands:        &cfg.HookIgnore.Commands,
+				Binaries:        &binaries,
+				Regexes:         &cfg.HookIgnore.Regexes,
+				MinDurationMS:   &cfg.HookIgnore.MinDurationMS,
+				CommandNotFound: &cfg.HookIgnore.CommandNotFound,
+			},
+		},
+		Export: &exportSection{
+			Format:   &cfg.Export.Format,
+			Annotate: &cfg.Export.Annotate,
+		},
+		Project: &projectSection{
+			Enabled:     &cfg.Project.Enabled,
+			AllowWeaken: &cfg.Project.AllowWeaken,
+		},
+	}
+	if cfg.ProjectRoot != "" {
+		file.Hooks.ProjectRoot = &cfg.ProjectRoot
+	}
+	if len(cfg.Profiles) > 0 {
+		profiles := make(map[string]policyKeys, len(cfg.Profiles))
+		for env, prof := range cfg.Profiles {
+			profiles[env] = newPolicyKeys(prof)
+		}
+		file.Policy.Profiles = &profiles
+	}
+	return file
+}
+
+var (
+	guardEntryType = reflect.TypeOf(guardEntry{})
+	stringListType = reflect.TypeOf(stringList{})
+)
+
+// schemaChecker walks a YAML tree next to the Go type it will be decoded into
+// and records unknown keys and mismatched value kinds with their lines.
+type schemaChecker struct {
+	problems []ConfigProblem
+	lines    map[string]int
+}
+
+func (c *schemaChecker) check(node *yaml.Node, t reflect.Type, path string) {
+	if node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	c.lines[path] = node.Line
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	// `key:` with no value leaves the default in place.
+	if node.Kind == yaml.ScalarNode && node.ShortTag() == "!!null" {
+		return
+	}
+	if node.Kind == yaml.ScalarNode && (t == guardEntryType || t == stringListType) {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if !c.expect(node, yaml.MappingNode, path, "a mapping") {
+			return
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			field, ok := fields[key]
+			if !ok {
+				c.add(node.Content[i].Line, "unknown key %s", joinKeyPath(path, key))
+				continue
 			}
+			c.check(node.Content[i+1], field, joinKeyPath(path, key))
+		}
+	case reflect.Map:
+		if !c.expect(node, yaml.MappingNode, path, "a mapping") {
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			c.check(node.Content[i+1], t.Elem(), joinKeyPath(path, node.Content[i].Value))
+		}
+	case reflect.Slice:
+		if !c.expect(node, yaml.SequenceNode, path, "a list") {
+			return
+		}
+		for i, item := range node.Content {
+			c.check(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+		}
+	case reflect.Bool:
+		if node.Kind != yaml.ScalarNode || node.ShortTag() != "!!bool" {
+			c.add(node.Line, "%s must be true or false, got %s", path, describeNode(node))
 		}
+	case reflect.Int, reflect.Int64:
+		if node.Kind != yaml.ScalarNode || node.ShortTag() != "!!int" {
+			c.add(node.Line, "%s must be an integer, got %s", path, describeNode(node))
+		}
+	case reflect.String:
+		c.expect(node, yaml.ScalarNode, path, "a string")
 	}
+}
 
-	if denylistSeen && len(parsedDeny) > 0 {
-		cfg.Denylist = parsedDeny
+func (c *schemaChecker) expect(node *yaml.Node, kind yaml.Kind, path, what string) bool {
+	if node.Kind == kind {
+		return true
 	}
-	if keywordsSeen && len(parsedKeyword) > 0 {
-		cfg.RedactionKeywords = parsedKeyword
+	name := path
+	if name == "" {
+		name = "config"
 	}
+	c.add(node.Line, "%s must be %s, got %s", name, what, describeNode(node))
+	return false
+}
 
-	return cfg, nil
+func (c *schemaChecker) add(line int, format string, args ...any) {
+	c.problems = append(c.problems, ConfigProblem{Line: line, Message: fmt.Sprintf(format, args...)})
 }
 
-func splitKeyValue(line string) (key string, value string, hasValue bool) {
-	parts := strings.SplitN(line, ":", 2)
-	if len(parts) != 2 {
-		return strings.TrimSpace(line), "", false
+// yamlFields maps yaml key names of a struct type to field types.
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if field.Anonymous && opts == "inline" {
+			for key, typ := range yamlFields(field.Type) {
+				fields[key] = typ
+			}
+			continue
+		}
+		if name == "" || name == "-" {
+			continue
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+func describeNode(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a list"
+	default:
+		return strconv.Quote(node.Value)
 	}
-	key = strings.TrimSpace(parts[0])
-	value = strings.TrimSpace(parts[1])
-	if value == "" {
-		return key, "", false
+}
+
+func joinKeyPath(path, key string) string {
+	if path == "" {
+		return key
 	}
-	return key, trimMatchingQuotes(value), true
+	return path + "." + key
 }
 
-func trimMatchingQuotes(s string) string {
-	s = strings.TrimSpace(s)
-	if len(s) < 2 {
-		return s
+var yamlLinePrefix = regexp.MustCompile(`^yaml: line (\d+): `)
+
+func yamlSyntaxProblem(err error) ConfigProblem {
+	msg := err.Error()
+	if m := yamlLinePrefix.FindStringSubmatch(msg); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		return ConfigProblem{Line: line, Message: strings.TrimPrefix(msg, m[0])}
 	}
-	if (s[0] == '"' && s[len(s)-1] == '"') || (s[0] == '\'' && s[len(s)-1] == '\'') {
-		return s[1 : len(s)-1]
+	return ConfigProblem{Message: strings.TrimPrefix(msg, "yaml: ")}
+}
+
+func nonEmptyItems(items []string) []string {
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
 	}
-	return s
+	return out
 }
@@ -1,8 +1,10 @@
 package policy
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -93,6 +95,527 @@ func TestParseConfigHandlesUTF8BOM(t *testing.T) {
 	}
 }
 
+func TestParseConfigGuardedRules(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := ParseConfig(strings.Join([]string{
+		"policy:",
+		"  guarded:",
+		"    - terraform destroy*",
+		"    - pattern: kubectl delete *",
+		"      envs: [prod, \"production\"]",
+		"    - pattern: \"DROP TABLE\"",
+		"      envs:",
+		"        - prod",
+		"  enforce_denylist: false",
+	}, "\n"))
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+
+	want := []GuardRule{
+		{Pattern: "terraform destroy*"},
+		{Pattern: "kubectl delete *", Envs: []string{"prod", "production"}},
+		{Pattern: "DROP TABLE", Envs: []string{"prod"}},
+	}
+	if !reflect.DeepEqual(cfg.Guarded, want) {
+		t.Fatalf("guarded mismatch\n got: %#v\nwant: %#v", cfg.Guarded, want)
+	}
+}
+
+func TestParseConfigGuardedDefaultsAndDisable(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := ParseConfig("policy:\n  enforce_denylist: false\n")
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+	if len(cfg.Guarded) == 0 {
+		t.Fatalf("expected default guarded rules when guarded is missing")
+	}
+
+	cfg, err = ParseConfig("policy:\n  guarded: []\n")
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+	if len(cfg.Guarded) != 0 {
+		t.Fatalf("expected empty guarded list to disable defaults, got %#v", cfg.Guarded)
+	}
+
+	if _, err := ParseConfig("policy:\n  guarded:\n    - envs: [prod]\n"); err == nil {
+		t.Fatalf("expected error for guarded entry without pattern")
+	}
+}
+
+func TestParseConfigExpectedExitCodes(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := ParseConfig(strings.Join([]string{
+		"policy:",
+		"  enforce_denylist: false",
+		"capture:",
+		"  include_stdout: false",
+		"  expected_exit_codes:",
+		"    diff: []",
+		"    kubectl: [0, 1]",
+		"    Grep.exe: [0, 1, 2]",
+	}, "\n"))
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+
+	want := map[string][]int{
+		"grep":    {0, 1, 2},
+		"kubectl": {0, 1},
+	}
+	if !reflect.DeepEqual(cfg.ExpectedExitCodes, want) {
+		t.Fatalf("expected exit codes mismatch\n got: %#v\nwant: %#v", cfg.ExpectedExitCodes, want)
+	}
+
+	if _, err := ParseConfig("capture:\n  expected_exit_codes:\n    grep: [zero]\n"); err == nil {
+		t.Fatalf("expected error for non-numeric exit code")
+	}
+}
+
+func TestParseConfigHookIgnore(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := ParseConfig("policy:\n  enforce_denylist: false\n")
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+	if !reflect.DeepEqual(cfg.HookIgnore, defaultHookIgnore) {
+		t.Fatalf("expected default hooks.ignore, got %#v", cfg.HookIgnore)
+	}
+
+	cfg, err = ParseConfig(strings.Join([]string{
+		"hooks:",
+		"  ignore:",
+		"    commands:",
+		"      - git log*",
+		"      - 'kubectl get pods'",
+		"    binaries: []",
+		"    regexes:",
+		`      - "^\\s*#"`,
+		"    min_duration_ms: 50",
+		"    command_not_found: false",
+	}, "\n"))
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+	want := HookIgnore{
+		Commands:      []string{"git log*", "kubectl get pods"},
+		Binaries:      []string{},
+		Regexes:       []string{`^\s*#`},
+		MinDurationMS: 50,
+	}
+	if !reflect.DeepEqual(cfg.HookIgnore, want) {
+		t.Fatalf("hooks.ignore mismatch\n got: %#v\nwant: %#v", cfg.HookIgnore, want)
+	}
+
+	cfg, err = ParseConfig("hooks:\n  project_root: ~/src/app\n")
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+	if cfg.ProjectRoot != "~/src/app" {
+		t.Fatalf("expected project_root, got %q", cfg.ProjectRoot)
+	}
+
+	for _, content := range []string{
+		"hooks:\n  ignore:\n    min_duration_ms: -1\n",
+		"hooks:\n  ignore:\n    command_not_found: maybe\n",
+		"hooks:\n  ignore:\n    binary: [ls]\n",
+	} {
+		if _, err := ParseConfig(content); err == nil {
+			t.Fatalf("expected error for %q", content)
+		}
+	}
+}
+
+func TestParseConfigRedactionRules(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := ParseConfig(strings.Join([]string{
+		"policy:",
+		"  redaction_rules:",
+		"    - name: aws-account",
+		`      pattern: '\b\d{12}\b'`,
+		"      tools: aws",
+		"    - name: s3-customer",
+		`      pattern: 's3://(?P<bucket>[^/]+)/customers/[^/\s]+'`,
+		"      replace: 's3://${bucket}/customers/[CUSTOMER]'",
+	}, "\n"))
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+	want := []RedactionRule{
+		{Name: "aws-account", Pattern: `\b\d{12}\b`, Tools: []string{"aws"}},
+		{Name: "s3-customer", Pattern: `s3://(?P<bucket>[^/]+)/customers/[^/\s]+`, Replace: "s3://${bucket}/customers/[CUSTOMER]"},
+	}
+	if !reflect.DeepEqual(cfg.RedactionRules, want) {
+		t.Fatalf("redaction rules mismatch\n got: %#v\nwant: %#v", cfg.RedactionRules, want)
+	}
+
+	for content, wantMsg := range map[string]string{
+		"policy:\n  redaction_rules:\n    - pattern: x\n":                                         "line 3: policy.redaction_rules[0] requires a name",
+		"policy:\n  redaction_rules:\n    - name: a\n      pattern: '('\n":                        "line 4: policy.redaction_rules[0].pattern is invalid",
+		"policy:\n  redaction_rules:\n    - name: a\n      pattern: x\n      replace: $2\n":       "refers to group 2 but the pattern has 0",
+		"policy:\n  redaction_rules:\n    - {name: a, pattern: x}\n    - {name: a, pattern: y}\n": `line 4: policy.redaction_rules[1].name "a" is already defined`,
+	} {
+		_, err := ParseConfig(content)
+		if err == nil || !strings.Contains(err.Error(), wantMsg) {
+			t.Fatalf("expected %q for %q, got %v", wantMsg, content, err)
+		}
+	}
+}
+
+func TestParseConfigDetectors(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := ParseConfig("policy:\n  detectors:\n    high_entropy: false\n")
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+	if cfg.Detectors[DetectorHighEntropy] || !cfg.Detectors[DetectorJWT] || len(cfg.Detectors) != len(DetectorNames()) {
+		t.Fatalf("unexpected detectors: %#v", cfg.Detectors)
+	}
+
+	_, err = ParseConfig("policy:\n  detectors:\n    entropy: false\n")
+	if err == nil || !strings.Contains(err.Error(), "line 3: policy.detectors.entropy is not a detector") {
+		t.Fatalf("expected unknown detector error, got %v", err)
+	}
+}
+
+func TestParseConfigReportsSchemaProblemsWithLines(t *testing.T) {
+	t.Parallel()
+
+	_, err := ParseConfig(strings.Join([]string{
+		"policy:",
+		"  enforce_denylst: true",
+		"  denylist: docker login",
+		"capture:",
+		"  expected_exit_codes:",
+		"    grep: [zero]",
+		"hooks:",
+		"  ignore:",
+		"    min_duration_ms: fast",
+		"exports:",
+		"  format: md",
+	}, "\n"))
+	var cfgErr *ConfigError
+	if !errors.As(err, &cfgErr) {
+		t.Fatalf("expected *ConfigError, got %v", err)
+	}
+	want := []ConfigProblem{
+		{Line: 2, Message: "unknown key policy.enforce_denylst"},
+		{Line: 3, Message: `policy.denylist must be a list, got "docker login"`},
+		{Line: 6, Message: `capture.expected_exit_codes.grep[0] must be an integer, got "zero"`},
+		{Line: 9, Message: `hooks.ignore.min_duration_ms must be an integer, got "fast"`},
+		{Line: 10, Message: "unknown key exports"},
+	}
+	if !reflect.DeepEqual(cfgErr.Problems, want) {
+		t.Fatalf("problems mismatch\n got: %#v\nwant: %#v", cfgErr.Problems, want)
+	}
+
+	for content, wantLine := range map[string]int{
+		"policy:\n  guarded:\n    - envs: [prod]\n": 3,
+		"hooks:\n  ignore:\n    regexes: ['(']\n":   3,
+		"export:\n  annotate: sometimes\n":          2,
+		"policy:\n  denylist: [a\n":                 0,
+	} {
+		_, err := ParseConfig(content)
+		if !errors.As(err, &cfgErr) || len(cfgErr.Problems) != 1 {
+			t.Fatalf("expected one problem for %q, got %v", content, err)
+		}
+		if wantLine > 0 && cfgErr.Problems[0].Line != wantLine {
+			t.Fatalf("expected line %d for %q, got %v", wantLine, content, err)
+		}
+	}
+}
+
+func TestFormatConfigRoundTrips(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := ParseConfig(strings.Join([]string{
+		"policy:",
+		"  enforce_denylist: true",
+		"hooks:",
+		"  project_root: ~/src/app",
+		"export:",
+		"  annotate: never",
+	}, "\n"))
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+	out, err := FormatConfig(cfg)
+	if err != nil {
+		t.Fatalf("FormatConfig failed: %v", err)
+	}
+	for _, want := range []string{
+		"  enforce_denylist: true\n",
+		"    - pattern: kubectl delete *\n      envs: [prod, production]\n",
+		"    grep: [0, 1]\n",
+		"  project_root: ~/src/app\n",
+		"    binaries: [ls, cd, clear, cls, pwd]\n",
+		"export:\n  format: md\n  annotate: never\n",
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Fatalf("expected %q in formatted config:\n%s", want, out)
+		}
+	}
+
+	again, err := ParseConfig(string(out))
+	if err != nil {
+		t.Fatalf("formatted config does not parse: %v\n%s", err, out)
+	}
+	if again.EnforceDenylist != cfg.EnforceDenylist || again.ProjectRoot != cfg.ProjectRoot ||
+		again.Export != cfg.Export || !reflect.DeepEqual(again.Guarded, cfg.Guarded) {
+		t.Fatalf("round trip mismatch\n got: %#v\nwant: %#v", again, cfg)
+	}
+}
+
+func TestParseConfigPolicyProfiles(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := ParseConfig(strings.Join([]string{
+		"policy:",
+		"  redaction_keywords: [token]",
+		"  detectors:",
+		"    high_entropy: false",
+		"  profiles:",
+		"    prod:",
+		"      denylist: [\"terraform destroy\"]",
+		"      enforce_denylist: true",
+		"      detectors:",
+		"        high_entropy: true",
+		"    sandbox:",
+		"      guarded: []",
+	}, "\n"))
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+	if cfg.EnforceDenylist || cfg.Detectors[DetectorHighEntropy] || len(cfg.Guarded) == 0 {
+		t.Fatalf("profiles must not change the base config: %#v", cfg)
+	}
+
+	prod := cfg.ForEnv("PROD")
+	if !prod.EnforceDenylist || !prod.Detectors[DetectorHighEntropy] ||
+		!reflect.DeepEqual(prod.Denylist, []string{"terraform destroy"}) ||
+		!reflect.DeepEqual(prod.RedactionKeywords, []string{"token"}) {
+		t.Fatalf("unexpected prod config: %#v", prod)
+	}
+	if cfg.Detectors[DetectorHighEntropy] {
+		t.Fatalf("ForEnv must not modify the base detectors")
+	}
+	if sandbox := cfg.ForEnv("sandbox"); len(sandbox.Guarded) != 0 || !reflect.DeepEqual(sandbox.Denylist, cfg.Denylist) {
+		t.Fatalf("unexpected sandbox config: %#v", sandbox)
+	}
+	if staging := cfg.ForEnv("staging"); !reflect.DeepEqual(staging, cfg) {
+		t.Fatalf("expected an env without a profile to keep the base config")
+	}
+
+	out, err := FormatConfig(cfg)
+	if err != nil {
+		t.Fatalf("FormatConfig failed: %v", err)
+	}
+	if !strings.Contains(string(out), "  profiles:\n    prod:\n      denylist:\n        - terraform destroy\n") {
+		t.Fatalf("expected profiles in formatted config:\n%s", out)
+	}
+	again, err := ParseConfig(string(out))
+	if err != nil {
+		t.Fatalf("formatted config does not parse: %v\n%s", err, out)
+	}
+	if !reflect.DeepEqual(again.Profiles, cfg.Profiles) {
+		t.Fatalf("profile round trip mismatch\n got: %#v\nwant: %#v", again.Profiles, cfg.Profiles)
+	}
+
+	_, err = ParseConfig(strings.Join([]string{
+		"policy:",
+		"  profiles:",
+		"    prod:",
+		"      enforce_denylst: true",
+		"    staging:",
+		"      profiles: {}",
+	}, "\n"))
+	var cfgErr *ConfigError
+	if !errors.As(err, &cfgErr) {
+		t.Fatalf("expected *ConfigError, got %v", err)
+	}
+	for _, want := range []string{
+		"line 4: unknown key policy.profiles.prod.enforce_denylst",
+		"line 6: unknown key policy.profiles.staging.profiles",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q in %v", want, err)
+		}
+	}
+
+	_, err = ParseConfig("policy:\n  profiles:\n    prod: {}\n    Prod:\n      detectors:\n        aws: false\n")
+	for _, want := range []string{
+		`line 3: policy.profiles.prod duplicates profile "Prod"`,
+		"line 6: policy.profiles.Prod.detectors.aws is not a detector",
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q, got %v", want, err)
+		}
+	}
+}
+
+func TestParseConfigAllowlistMode(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := ParseConfig(strings.Join([]string{
+		"policy:",
+		"  mode: Allowlist",
+		"  allowlist:",
+		"    - kubectl   get",
+		"    - git",
+		"  profiles:",
+		"    sandbox:",
+		"      mode: denylist",
+	}, "\n"))
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+	if cfg.Mode != ModeAllowlist || !reflect.DeepEqual(cfg.Allowlist, []string{"kubectl get", "git"}) {
+		t.Fatalf("unexpected allowlist settings: %q %v", cfg.Mode, cfg.Allowlist)
+	}
+	if sandbox := cfg.ForEnv("sandbox"); sandbox.Mode != ModeDenylist {
+		t.Fatalf("expected the sandbox profile to switch back to denylist mode, got %q", sandbox.Mode)
+	}
+
+	_, err = ParseConfig("policy:\n  mode: strict\n  allowlist: [\"git [\"]\n  profiles:\n    prod:\n      mode: allowlist\n")
+	for _, want := range []string{
+		"line 2: policy.mode must be denylist or allowlist",
+		`line 3: policy.allowlist[0] is invalid: invalid allowlist entry "git [": bad pattern "["`,
+		"line 6: policy.profiles.prod uses allowlist mode with an empty allowlist",
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q, got %v", want, err)
+		}
+	}
+
+	project, err := ParseProjectConfig("policy:\n  mode: allowlist\n  allowlist: [terraform]\n")
+	if err != nil {
+		t.Fatalf("ParseProjectConfig failed: %v", err)
+	}
+	merged, notes := DefaultConfig().MergeProject(project)
+	if merged.Mode != ModeAllowlist || !reflect.DeepEqual(merged.Allowlist, []string{"terraform"}) || len(notes) != 0 {
+		t.Fatalf("expected the project to switch on allowlist mode, got %q %v %q", merged.Mode, merged.Allowlist, notes)
+	}
+	project, err = ParseProjectConfig("policy:\n  allowlist: [curl]\n")
+	if err != nil {
+		t.Fatalf("ParseProjectConfig failed: %v", err)
+	}
+	merged, notes = cfg.MergeProject(project)
+	if !reflect.DeepEqual(merged.Allowlist, cfg.Allowlist) || len(notes) != 1 || notes[0] != "policy.allowlist: curl would weaken config.yaml" {
+		t.Fatalf("expected project entries not to widen the allowlist, got %v %q", merged.Allowlist, notes)
+	}
+}
+
+func TestFindProjectConfigStopsAtGitRoot(t *testing.T) {
+	t.Parallel()
+
+	outer := t.TempDir()
+	repo := filepath.Join(outer, "repo")
+	sub := filepath.Join(repo, "deploy", "k8s")
+	if err := os.MkdirAll(filepath.Join(repo, ".git"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := osWriteFile(filepath.Join(outer, ProjectConfigName), []byte("policy: {}\n")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if path, err := FindProjectConfig(sub); err != nil || path != "" {
+		t.Fatalf("expected no project config inside the repo, got %q, %v", path, err)
+	}
+
+	want := filepath.Join(repo, ProjectConfigName)
+	if err := osWriteFile(want, []byte("policy: {}\n")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if path, err := FindProjectConfig(sub); err != nil || path != want {
+		t.Fatalf("FindProjectConfig = %q, %v; want %q", path, err, want)
+	}
+	if path, err := FindProjectConfig(outer); err != nil || path != filepath.Join(outer, ProjectConfigName) {
+		t.Fatalf("expected the directory itself to be searched outside a repo, got %q, %v", path, err)
+	}
+}
+
+func TestMergeProjectOnlyStrengthensPolicy(t *testing.T) {
+	t.Parallel()
+
+	user, err := ParseConfig(strings.Join([]string{
+		"policy:",
+		"  enforce_denylist: true",
+		"  redaction_rules:",
+		"    - name: account",
+		"      pattern: '\\d{12}'",
+		"  profiles:",
+		"    sandbox:",
+		"      enforce_denylist: false",
+	}, "\n"))
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+	project, err := ParseProjectConfig(strings.Join([]string{
+		"policy:",
+		"  denylist: [\"terraform destroy*\", printenv]",
+		"  redaction_keywords: [db_url]",
+		"  redaction_rules:",
+		"    - name: account",
+		"      pattern: 'acct-\\d+'",
+		"  detectors:",
+		"    jwt: false",
+		"  enforce_denylist: false",
+		"  profiles:",
+		"    Prod:",
+		"      guarded: [\"helm upgrade*\"]",
+	}, "\n"))
+	if err != nil {
+		t.Fatalf("ParseProjectConfig failed: %v", err)
+	}
+
+	merged, notes := user.MergeProject(project)
+	if !merged.EnforceDenylist || !merged.Detectors[DetectorJWT] {
+		t.Fatalf("project config must not weaken the user config: %#v", merged)
+	}
+	if got := merged.Denylist[len(merged.Denylist)-1]; got != "terraform destroy*" || len(merged.Denylist) != len(user.Denylist)+1 {
+		t.Fatalf("expected the project denylist entry appended once, got %v", merged.Denylist)
+	}
+	if !reflect.DeepEqual(merged.RedactionRules, user.RedactionRules) || merged.RedactionKeywords[len(merged.RedactionKeywords)-1] != "db_url" {
+		t.Fatalf("unexpected redaction settings: %v %v", merged.RedactionRules, merged.RedactionKeywords)
+	}
+	wantNotes := []string{
+		`policy.redaction_rules: "account" is already defined in config.yaml`,
+		"policy.detectors.jwt: false would weaken config.yaml",
+		"policy.enforce_denylist: false would weaken config.yaml",
+	}
+	if !reflect.DeepEqual(notes, wantNotes) {
+		t.Fatalf("notes = %q, want %q", notes, wantNotes)
+	}
+
+	if sandbox := merged.ForEnv("sandbox"); sandbox.EnforceDenylist || !strings.Contains(strings.Join(sandbox.Denylist, ","), "terraform destroy*") {
+		t.Fatalf("expected the sandbox profile to keep its own enforcement and gain the project denylist: %#v", sandbox)
+	}
+	prod := merged.ForEnv("prod")
+	if !prod.EnforceDenylist || prod.Guarded[len(prod.Guarded)-1].Pattern != "helm upgrade*" {
+		t.Fatalf("expected a prod profile from the project file: %#v", prod)
+	}
+
+	user.Project.AllowWeaken = true
+	merged, notes = user.MergeProject(project)
+	if merged.EnforceDenylist || merged.Detectors[DetectorJWT] || len(notes) != 1 {
+		t.Fatalf("expected allow_weaken to apply the project settings, got %#v %q", merged, notes)
+	}
+
+	if _, err := ParseProjectConfig("policy: {}\nhooks:\n  ignore: {}\n"); err == nil || !strings.Contains(err.Error(), "line 2: unknown key hooks") {
+		t.Fatalf("expected hooks to be rejected in a project config, got %v", err)
+	}
+}
+
 func osWriteFile(path string, data []byte) error {
 	return os.WriteFile(path, data, 0o600)
 }
@@ -0,0 +1,210 @@
+package policy
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// Built-in secret detectors, toggled by name under `policy.detectors`.
+const (
+	DetectorAWSAccessKey   = "aws_access_key"
+	DetectorGitHubToken    = "github_token"
+	DetectorGitLabToken    = "gitlab_token"
+	DetectorJWT            = "jwt"
+	DetectorPrivateKey     = "private_key"
+	DetectorSlackWebhook   = "slack_webhook"
+	DetectorDiscordWebhook = "discord_webhook"
+	DetectorBasicAuth      = "basic_auth"
+	DetectorHighEntropy    = "high_entropy"
+)
+
+const (
+	// entropyMinLength and entropyMinBits keep hex digests, UUIDs, branch
+	// names and ordinary identifiers out of the high_entropy detector.
+	entropyMinLength = 32
+	entropyMinBits   = 4.2
+)
+
+// DetectorNames lists the built-in detectors in evaluation order.
+func DetectorNames() []string {
+	names := make([]string, 0, len(builtinDetectors))
+	for _, d := range builtinDetectors {
+		if len(names) == 0 || names[len(names)-1] != d.name {
+			names = append(names, d.name)
+		}
+	}
+	return names
+}
+
+func defaultDetectorToggles() map[string]bool {
+	toggles := make(map[string]bool, len(builtinDetectors))
+	for _, name := range DetectorNames() {
+		toggles[name] = true
+	}
+	return toggles
+}
+
+// builtinDetectors recognize secrets by format rather than by the flag or
+// key they follow. They run before the keyword redactors.
+var builtinDetectors = []redactor{
+	{
+		name:       DetectorAWSAccessKey,
+		re:         regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA|AIPA)[A-Z0-9]{16}\b`),
+		repl:       RedactedValue,
+		wholeMatch: true,
+	},
+	{
+		name:       DetectorGitHubToken,
+		re:         regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{22,255})\b`),
+		repl:       RedactedValue,
+		wholeMatch: true,
+	},
+	{
+		name:       DetectorGitLabToken,
+		re:         regexp.MustCompile(`\bgl(?:pat|dt|rt|ptt|cbt|oas)-[A-Za-z0-9_\-]{20,}`),
+		repl:       RedactedValue,
+		wholeMatch: true,
+	},
+	{
+		name:       DetectorJWT,
+		re:         regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]{5,}\.eyJ[A-Za-z0-9_\-]{5,}\.[A-Za-z0-9_\-]{10,}`),
+		repl:       RedactedValue,
+		wholeMatch: true,
+	},
+	{
+		// Without an END line the rest of the command is treated as key material.
+		name:       DetectorPrivateKey,
+		re:         regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY-----(?s:.*?)(?:-----END [A-Z0-9 ]*PRIVATE KEY-----|$)`),
+		repl:       RedactedValue,
+		wholeMatch: true,
+	},
+	{
+		name: DetectorSlackWebhook,
+		re:   regexp.MustCompile(`(https://hooks\.slack\.com/)(?:services|workflows|triggers)/[A-Za-z0-9/_\-]+`),
+		repl: `${1}` + RedactedValue,
+	},
+	{
+		name: DetectorDiscordWebhook,
+		re:   regexp.MustCompile(`(?i)(https://(?:ptb\.|canary\.)?discord(?:app)?\.com/api/webhooks/)[0-9]+/[A-Za-z0-9_\-]+`),
+		repl: `${1}` + RedactedValue,
+	},
+	{
+		name: DetectorBasicAuth,
+		re:   regexp.MustCompile(`(?i)(authorization\s*:\s*basic\s+)([A-Za-z0-9+/]+={0,2})`),
+		repl: `${1}` + RedactedValue,
+	},
+	{
+		// uri-userinfo already covers user:password@; this catches a bare
+		// token used as the user, as in https://<token>@github.com.
+		name: DetectorBasicAuth,
+		re:   regexp.MustCompile(`(?i)([a-z][a-z0-9+.\-]*://)([A-Za-z0-9_\-]{20,})@`),
+		repl: `${1}` + RedactedValue + `@`,
+	},
+	{
+		// Only whole arguments and the values of key=value or key:value are
+		// candidates; dots are matched so that names like a.b.c stay whole.
+		name:   DetectorHighEntropy,
+		re:     regexp.MustCompile(`(^|[\s'"=:])([A-Za-z0-9+/_.\-]{32,}={0,2})`),
+		repl:   `${1}` + RedactedValue,
+		accept: looksRandom,
+	},
+}
+
+// buildDetectors returns the detectors enabled in toggles. Detectors missing
+// from toggles are enabled.
+func buildDetectors(toggles map[string]bool) ([]redactor, error) {
+	known := make(map[string]bool, len(builtinDetectors))
+	for _, d := range builtinDetectors {
+		known[d.name] = true
+	}
+	for name := range toggles {
+		if !known[name] {
+			return nil, fmt.Errorf("unknown detector %q", name)
+		}
+	}
+
+	out := make([]redactor, 0, len(builtinDetectors))
+	for _, d := range builtinDetectors {
+		if enabled, ok := toggles[d.name]; ok && !enabled {
+			continue
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+// looksRandom reports whether the token in match reads like a generated
+// secret: long, mixing upper case, lower case and digits, with high Shannon
+// entropy. Dotted names, paths starting with / or ./ and words joined by
+// separators, such as feature/ABC-1234-AddPayments, are never random. A slash
+// alone is not enough, since base64 secrets contain it.
+func looksRandom(match string) bool {
+	s := strings.TrimRight(strings.TrimLeft(match, " \t\r\n'\"=:"), "=")
+	if len(s) < entropyMinLength || strings.Contains(s, ".") || isPathPrefixed(s) || joinsWords(s) {
+		return false
+	}
+	var upper, lower, digit bool
+	for _, r := range s {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= '0' && r <= '9':
+			digit = true
+		}
+	}
+	return upper && lower && digit && shannonEntropy(s) >= entropyMinBits
+}
+
+var wordPart = regexp.MustCompile(`^[A-Za-z]*[0-9]*$`)
+
+// isPathPrefixed reports whether s starts like a path: `/`, `./`, `../` or
+// `~/`.
+func isPathPrefixed(s string) bool {
+	for _, prefix := range []string{"/", "./", "../", "~/"} {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// joinsWords reports whether s is several parts joined by `-`, `_` or `/`
+// that are each a word, a number or a word with a numeric suffix, as in
+// ABC-1234-AddPaymentsV2 or docs/Runbooks/Release2024.
+func joinsWords(s string) bool {
+	parts := strings.FieldsFunc(s, func(r rune) bool { return r == '-' || r == '_' || r == '/' })
+	if len(parts) < 2 {
+		return false
+	}
+	for _, part := range parts {
+		if !wordPart.MatchString(part) {
+			return false
+		}
+	}
+	return true
+}
+
+// shannonEntropy returns the entropy of s in bits per byte.
+func shannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	var counts [256]int
+	for i := 0; i < len(s); i++ {
+		counts[s[i]]++
+	}
+	entropy := 0.0
+	n := float64(len(s))
+	for _, c := range counts {
+		if c == 0 {
+			continue
+		}
+		p := float64(c) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
@@ -0,0 +1,52 @@
+package policy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Fingerprint identifies the rules that decide what a stored command looks
+// like: the denylist, the redactors with their patterns, the allowlist and
+// the tool sanitizers. Two policies with the same fingerprint sanitize every
+// command the same way. Settings that do not touch stored commands, such as
+// guards or export defaults, are not part of it.
+func (p *Policy) Fingerprint() string {
+	return p.fingerprint
+}
+
+func (p *Policy) computeFingerprint() string {
+	h := sha256.New()
+	field := func(parts ...string) {
+		fmt.Fprintf(h, "%q\n", parts)
+	}
+	field("mode", p.mode)
+	for _, rule := range p.denylist {
+		field("deny", rule.pattern)
+	}
+	for _, rule := range p.allowlist {
+		field("allow", rule.entry)
+	}
+	for _, r := range p.redact {
+		tools := make([]string, 0, len(r.tools))
+		for tool := range r.tools {
+			tools = append(tools, tool)
+		}
+		sort.Strings(tools)
+		field("redact", r.name, r.re.String(), r.repl, strings.Join(tools, ","))
+	}
+	tools := make([]string, 0, len(p.sanitizers))
+	for tool := range p.sanitizers {
+		tools = append(tools, tool)
+	}
+	sort.Strings(tools)
+	for _, tool := range tools {
+		for _, s := range p.sanitizers[tool] {
+			field("sanitizer", tool, s.Name())
+		}
+	}
+	field("keywords", strings.Join(p.keywords, ","))
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))[:16]
+}
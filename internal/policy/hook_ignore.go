@@ -0,0 +1,162 @@
+package policy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/fixi2/Commandry/internal/util"
+)
+
+// commandNotFoundExitCode is what bash, zsh and fish return for unknown commands.
+const commandNotFoundExitCode = 127
+
+// HookIgnore lists shell-hook events that are not worth a runbook step. It only
+// applies to `cmdry hook record`; `cmdry run` always records.
+type HookIgnore struct {
+	// Commands are globs matched against each whole command of the line.
+	Commands []string
+	// Binaries are tool names compared with the first word of each command,
+	// like capture.expected_exit_codes keys.
+	Binaries []string
+	// Regexes are Go regular expressions matched anywhere in the command line.
+	Regexes []string
+	// MinDurationMS skips commands that finished faster. Events without a
+	// measured duration are never skipped by it.
+	MinDurationMS int64
+	// CommandNotFound skips commands that exited with 127 (typos).
+	CommandNotFound bool
+}
+
+// IgnoreMatch explains why a hook event is ignored. Reason is the hook
+// SkippedReason; Rule is the config entry that matched.
+type IgnoreMatch struct {
+	Reason string
+	Rule   string
+}
+
+type hookIgnore struct {
+	commands        []ignorePattern
+	binaries        map[string]bool
+	regexes         []ignorePattern
+	minDurationMS   int64
+	commandNotFound bool
+}
+
+type ignorePattern struct {
+	pattern string
+	re      *regexp.Regexp
+}
+
+var defaultHookIgnore = HookIgnore{
+	Commands:        []string{"git status*"},
+	Binaries:        []string{"ls", "cd", "clear", "cls", "pwd"},
+	CommandNotFound: true,
+}
+
+func cloneHookIgnore(in HookIgnore) HookIgnore {
+	out := in
+	out.Commands = append([]string(nil), in.Commands...)
+	out.Binaries = append([]string(nil), in.Binaries...)
+	out.Regexes = append([]string(nil), in.Regexes...)
+	return out
+}
+
+func compileHookIgnore(in HookIgnore) (hookIgnore, error) {
+	out := hookIgnore{
+		binaries:        make(map[string]bool, len(in.Binaries)),
+		minDurationMS:   in.MinDurationMS,
+		commandNotFound: in.CommandNotFound,
+	}
+	for _, pattern := range in.Commands {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		// Unlike denylist patterns, ignore globs are anchored: `git status*`
+		// must not swallow `echo git status`.
+		glob := regexp.QuoteMeta(pattern)
+		glob = strings.ReplaceAll(glob, `\*`, `.*`)
+		glob = strings.Join(strings.Fields(glob), `\s+`)
+		re, err := regexp.Compile(`(?i)^` + glob + `$`)
+		if err != nil {
+			return hookIgnore{}, fmt.Errorf("invalid hooks ignore command %q: %w", pattern, err)
+		}
+		out.commands = append(out.commands, ignorePattern{pattern: pattern, re: re})
+	}
+	for _, binary := range in.Binaries {
+		if name := toolName(binary); name != "" {
+			out.binaries[name] = true
+		}
+	}
+	for _, pattern := range in.Regexes {
+		if strings.TrimSpace(pattern) == "" {
+			continue
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return hookIgnore{}, fmt.Errorf("invalid hooks ignore regex %q: %w", pattern, err)
+		}
+		out.regexes = append(out.regexes, ignorePattern{pattern: pattern, re: re})
+	}
+	return out, nil
+}
+
+// IgnoreHookEvent reports whether a shell hook event matches hooks.ignore.
+// Commands and binaries are matched per command of a list or pipeline, and
+// the line is ignored only when every command is; `cd /srv && make` is kept.
+// A negative durationMS means the shell did not measure the command.
+func (p *Policy) IgnoreHookEvent(rawCommand string, exitCode int, durationMS int64) (IgnoreMatch, bool) {
+	rules := p.hookIgnore
+	raw := strings.TrimSpace(rawCommand)
+	if rules.commandNotFound && exitCode == commandNotFoundExitCode {
+		return IgnoreMatch{Reason: "command_not_found", Rule: "command_not_found"}, true
+	}
+	if match, ok := rules.ignoreSegments(raw); ok {
+		return match, true
+	}
+	for _, rule := range rules.regexes {
+		if rule.re.MatchString(raw) {
+			return IgnoreMatch{Reason: "ignored_regex", Rule: rule.pattern}, true
+		}
+	}
+	if rules.minDurationMS > 0 && durationMS >= 0 && durationMS < rules.minDurationMS {
+		return IgnoreMatch{Reason: "below_min_duration", Rule: fmt.Sprintf("min_duration_ms: %d", rules.minDurationMS)}, true
+	}
+	return IgnoreMatch{}, false
+}
+
+// ignoreSegments returns the match of the first command of raw when every
+// command of it is an ignored binary or command.
+func (rules hookIgnore) ignoreSegments(raw string) (IgnoreMatch, bool) {
+	segments := util.SplitShellScript(raw)
+	if len(segments) == 0 {
+		return IgnoreMatch{}, false
+	}
+	var first IgnoreMatch
+	for i, segment := range segments {
+		match, ok := rules.ignoreCommand(segment.Command)
+		if !ok {
+			return IgnoreMatch{}, false
+		}
+		if i == 0 {
+			first = match
+		}
+	}
+	return first, true
+}
+
+func (rules hookIgnore) ignoreCommand(command string) (IgnoreMatch, bool) {
+	command = strings.TrimSpace(command)
+	if args := util.SplitArgs(command); len(args) > 0 {
+		if name := toolName(args[0]); rules.binaries[name] {
+			return IgnoreMatch{Reason: "ignored_binary", Rule: name}, true
+		}
+	}
+	for _, rule := range rules.commands {
+		if rule.re.MatchString(command) {
+			return IgnoreMatch{Reason: "ignored_command", Rule: rule.pattern}, true
+		}
+	}
+	return IgnoreMatch{}, false
+}
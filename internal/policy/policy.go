@@ -2,11 +2,12 @@ package policy
 
 import (
 	"fmt"
-	"os"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+
+	"github.com/fixi2/Commandry/internal/util"
 )
 
 const (
@@ -17,23 +18,84 @@ const (
 type Result struct {
 	Command string
 	Denied  bool
+	// Rule and Tool describe a denial: the first denylist pattern,
+	// "builtin: ..." rule or "allowlist: not listed" that matched, and the
+	// binary it matched. Both are empty when Denied is false.
+	Rule string
+	Tool string
 }
 
 type redactor struct {
-	re   *regexp.Regexp
-	repl string
+	name  string
+	re    *regexp.Regexp
+	repl  string
+	tools map[string]bool
+	// wholeMatch marks rules whose whole match is the redacted value. The
+	// keyword rules keep their first group.
+	wholeMatch bool
+	// accept, when set, filters matches; rejected matches stay unchanged.
+	accept func(match string) bool
+}
+
+type denyRule struct {
+	pattern string
+	re      *regexp.Regexp
 }
 
 type Policy struct {
-	denylist        []*regexp.Regexp
+	denylist        []denyRule
 	redact          []redactor
+	guarded         []guardRule
+	expectedExit    map[string][]int
+	hookIgnore      hookIgnore
+	projectRoot     string
+	export          ExportSettings
 	enforceDenylist bool
+	mode            string
+	allowlist       []allowRule
+	keywords        []string
+	sanitizers      map[string][]ArgSanitizer
+	fingerprint     string
 }
 
 type Options struct {
 	DenylistPatterns  []string
 	RedactionKeywords []string
-	EnforceDenylist   bool
+	// RedactionRules are user-defined regex redactors.
+	RedactionRules []RedactionRule
+	// Detectors toggles built-in secret detectors by name; missing names
+	// are enabled.
+	Detectors       map[string]bool
+	EnforceDenylist bool
+	// Mode is ModeDenylist (the default) or ModeAllowlist.
+	Mode string
+	// Allowlist lists the commands recorded in allowlist mode.
+	Allowlist []string
+	Guarded   []GuardRule
+	// ExpectedExitCodes maps a tool name (for example "grep") to the exit
+	// codes that count as success when `cmdry run` gets no --expect-* flag.
+	ExpectedExitCodes map[string][]int
+	// HookIgnore filters noise out of shell-hook recording.
+	HookIgnore HookIgnore
+	// ProjectRoot is the default hook recording scope for new sessions.
+	ProjectRoot string
+	// Export holds `cmdry export` defaults; zero fields fall back to md/auto.
+	Export ExportSettings
+	// Sanitizers run after the built-in tool sanitizers.
+	Sanitizers []ArgSanitizer
+}
+
+// GuardRule marks commands that require typed confirmation before they run.
+// Envs limits the rule to sessions with a matching env label; empty means all.
+type GuardRule struct {
+	Pattern string
+	Envs    []string
+}
+
+type guardRule struct {
+	pattern string
+	re      *regexp.Regexp
+	envs    []string
 }
 
 var credentialInImageRef = regexp.MustCompile(`^[^/\s:@]+:[^/\s@]+@`)
@@ -63,11 +125,29 @@ var defaultRedactionKeywords = []string{
 	"private_key",
 }
 
+var defaultGuardedRules = []GuardRule{
+	{Pattern: "kubectl delete *", Envs: []string{"prod", "production"}},
+	{Pattern: "terraform destroy*", Envs: []string{"prod", "production"}},
+	{Pattern: "helm uninstall*", Envs: []string{"prod", "production"}},
+	{Pattern: "*DROP TABLE", Envs: []string{"prod", "production"}},
+}
+
+// defaultExpectedExitCodes lists probes that exit 1 for "no match" or
+// "differs" rather than on error.
+var defaultExpectedExitCodes = map[string][]int{
+	"grep": {0, 1},
+	"diff": {0, 1},
+}
+
 func NewDefault() *Policy {
 	p, _ := New(Options{
 		DenylistPatterns:  defaultDenylistPatterns,
 		RedactionKeywords: defaultRedactionKeywords,
 		EnforceDenylist:   false,
+		Guarded:           defaultGuardedRules,
+		ExpectedExitCodes: defaultExpectedExitCodes,
+		HookIgnore:        defaultHookIgnore,
+		Export:            defaultExportSettings,
 	})
 	return p
 }
@@ -83,7 +163,7 @@ func New(opts Options) (*Policy, error) {
 		redactionKeywords = defaultRedactionKeywords
 	}
 
-	denylist := make([]*regexp.Regexp, 0, len(denyPatterns))
+	denylist := make([]denyRule, 0, len(denyPatterns))
 	for _, pattern := range denyPatterns {
 		if strings.TrimSpace(pattern) == "" {
 			continue
@@ -92,14 +172,108 @@ func New(opts Options) (*Policy, error) {
 		if err != nil {
 			return nil, err
 		}
-		denylist = append(denylist, re)
+		denylist = append(denylist, denyRule{pattern: strings.TrimSpace(pattern), re: re})
+	}
+
+	guarded := make([]guardRule, 0, len(opts.Guarded))
+	for _, rule := range opts.Guarded {
+		if strings.TrimSpace(rule.Pattern) == "" {
+			continue
+		}
+		re, err := compileGuardPattern(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid guarded pattern %q: %w", rule.Pattern, err)
+		}
+		guarded = append(guarded, guardRule{
+			pattern: strings.TrimSpace(rule.Pattern),
+			re:      re,
+			envs:    rule.Envs,
+		})
+	}
+
+	expectedExit := make(map[string][]int, len(opts.ExpectedExitCodes))
+	for tool, codes := range opts.ExpectedExitCodes {
+		tool = toolName(tool)
+		if tool == "" || len(codes) == 0 {
+			continue
+		}
+		expectedExit[tool] = append([]int(nil), codes...)
+	}
+
+	hookIgnore, err := compileHookIgnore(opts.HookIgnore)
+	if err != nil {
+		return nil, err
+	}
+
+	detectors, err := buildDetectors(opts.Detectors)
+	if err != nil {
+		return nil, err
+	}
+	customRedactors, err := compileRedactionRules(opts.RedactionRules)
+	if err != nil {
+		return nil, err
+	}
+	// Detectors run first so that format matches (a whole private key block,
+	// say) are not cut up by the broader keyword and assignment rules.
+	redact := append(detectors, buildRedactors(redactionKeywords)...)
+	redact = append(redact, customRedactors...)
+
+	mode := opts.Mode
+	switch mode {
+	case "":
+		mode = ModeDenylist
+	case ModeDenylist, ModeAllowlist:
+	default:
+		return nil, fmt.Errorf("unknown policy mode %q", opts.Mode)
+	}
+	allowlist, err := compileAllowlist(opts.Allowlist)
+	if err != nil {
+		return nil, err
 	}
 
-	return &Policy{
+	exportSettings := opts.Export
+	if exportSettings.Format == "" {
+		exportSettings.Format = defaultExportSettings.Format
+	}
+	if exportSettings.Annotate == "" {
+		exportSettings.Annotate = defaultExportSettings.Annotate
+	}
+
+	p := &Policy{
 		denylist:        denylist,
-		redact:          buildRedactors(redactionKeywords),
+		redact:          redact,
+		guarded:         guarded,
+		expectedExit:    expectedExit,
+		hookIgnore:      hookIgnore,
+		projectRoot:     strings.TrimSpace(opts.ProjectRoot),
+		export:          exportSettings,
 		enforceDenylist: opts.EnforceDenylist,
-	}, nil
+		mode:            mode,
+		allowlist:       allowlist,
+		keywords:        append([]string(nil), redactionKeywords...),
+		sanitizers:      indexSanitizers(opts.Sanitizers),
+	}
+	p.fingerprint = p.computeFingerprint()
+	return p, nil
+}
+
+func cloneExpectedExitCodes(codes map[string][]int) map[string][]int {
+	out := make(map[string][]int, len(codes))
+	for tool, list := range codes {
+		out[tool] = append([]int(nil), list...)
+	}
+	return out
+}
+
+func cloneGuardRules(rules []GuardRule) []GuardRule {
+	out := make([]GuardRule, 0, len(rules))
+	for _, rule := range rules {
+		out = append(out, GuardRule{
+			Pattern: rule.Pattern,
+			Envs:    append([]string(nil), rule.Envs...),
+		})
+	}
+	return out
 }
 
 func buildRedactors(keywords []string) []redactor {
@@ -110,38 +284,47 @@ func buildRedactors(keywords []string) []redactor {
 	}))
 	return []redactor{
 		{
+			name: "authorization-bearer",
 			re:   regexp.MustCompile(`(?i)(authorization\s*:\s*bearer\s+)([^\s"']+)`),
 			repl: `${1}` + RedactedValue,
 		},
 		{
+			name: "uri-userinfo",
 			re:   uriUserinfo,
 			repl: `${1}` + RedactedValue + `:` + RedactedValue + `@`,
 		},
 		{
+			name: "keyword-flag",
 			re:   regexp.MustCompile(`(?i)(--(?:` + keyPattern + `)=)([^\s]+)`),
 			repl: `${1}` + RedactedValue,
 		},
 		{
+			name: "keyword-flag-value",
 			re:   regexp.MustCompile(`(?i)(--(?:` + keyPattern + `)\s+)([^\s]+)`),
 			repl: `${1}` + RedactedValue,
 		},
 		{
+			name: "short-p-flag",
 			re:   regexp.MustCompile(`(?i)(-p\s+)([^\s]+)`),
 			repl: `${1}` + RedactedValue,
 		},
 		{
+			name: "keyword-assignment",
 			re:   regexp.MustCompile(`(?i)(\b(?:` + keyValuePattern + `)\b\s*[:=]\s*)([^\s]+)`),
 			repl: `${1}` + RedactedValue,
 		},
 		{
+			name: "assignment-double-quoted",
 			re:   regexp.MustCompile(`(?i)(\b[A-Za-z_][A-Za-z0-9_]*=)"[^"]*"`),
 			repl: `${1}"` + RedactedValue + `"`,
 		},
 		{
+			name: "assignment-single-quoted",
 			re:   regexp.MustCompile(`(?i)(\b[A-Za-z_][A-Za-z0-9_]*=)'[^']*'`),
 			repl: `${1}'` + RedactedValue + `'`,
 		},
 		{
+			name: "assignment",
 			re:   regexp.MustCompile(`(?i)(\b[A-Za-z_][A-Za-z0-9_]*=)([^\s"']+)`),
 			repl: `${1}` + RedactedValue,
 		},
@@ -196,62 +379,340 @@ func compileDenyPattern(pattern string) (*regexp.Regexp, error) {
 	return re, nil
 }
 
+// compileGuardPattern compiles a guarded glob anchored at the binary of a
+// command: it must match from the start of the argv up to a word boundary.
+// A leading * matches anywhere in the argv.
+func compileGuardPattern(pattern string) (*regexp.Regexp, error) {
+	re, err := compileDenyPattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return regexp.Compile(`(?i)^(?:` + strings.TrimPrefix(re.String(), `(?i)`) + `)(?:\s|$)`)
+}
+
 func LoadFromConfig(path string) (*Policy, error) {
 	cfg, err := ParseConfigFile(path)
 	if err != nil {
 		return nil, err
 	}
-	return New(Options{
-		DenylistPatterns:  cfg.Denylist,
-		RedactionKeywords: cfg.RedactionKeywords,
-		EnforceDenylist:   cfg.EnforceDenylist,
-	})
+	return New(cfg.Options())
+}
+
+func (p *Policy) EnforceDenylist() bool {
+	return p.enforceDenylist
+}
+
+// ProjectRoot returns the configured default scope for `cmdry start`, or "".
+func (p *Policy) ProjectRoot() string {
+	return p.projectRoot
 }
 
-func LoadFromConfigOrDefault(path string) (*Policy, error) {
-	_, statErr := os.Stat(path)
-	if statErr != nil {
-		if os.IsNotExist(statErr) {
-			return NewDefault(), nil
+// Export returns the configured `cmdry export` defaults.
+func (p *Policy) Export() ExportSettings {
+	return p.export
+}
+
+// Guard returns the first guarded pattern that matches the command args and
+// applies to the session env. Patterns are matched against the argv from the
+// binary on, after NAME=value assignments and wrappers such as sudo; the
+// script of `sh -c` is checked command by command like GuardShell.
+func (p *Policy) Guard(args []string, env string) (string, bool) {
+	env = strings.TrimSpace(env)
+	args = commandArgs(args)
+	if len(args) == 0 {
+		return "", false
+	}
+	if shellBinaries[toolName(args[0])] && len(args) > 2 && shellCommandFlag.MatchString(args[1]) {
+		return p.guardShell(args[2], env)
+	}
+
+	words := append([]string{toolName(args[0])}, args[1:]...)
+	command := strings.Join(words, " ")
+	for _, rule := range p.guarded {
+		if !guardAppliesToEnv(rule.envs, env) {
+			continue
+		}
+		if rule.re.MatchString(command) {
+			return rule.pattern, true
 		}
-		return nil, statErr
 	}
-	return LoadFromConfig(path)
+	return "", false
 }
 
-func (p *Policy) EnforceDenylist() bool {
-	return p.enforceDenylist
+// GuardShell is Guard for a shell script: every command of it, including
+// those in subshells and substitutions, is checked on its own. A script that
+// cannot be parsed is checked per top-level segment.
+func (p *Policy) GuardShell(script, env string) (string, bool) {
+	return p.guardShell(script, strings.TrimSpace(env))
+}
+
+func (p *Policy) guardShell(script, env string) (string, bool) {
+	commands, err := util.ShellCommands(script)
+	if err != nil {
+		commands = util.SplitShellScript(script)
+	}
+	for _, command := range commands {
+		if rule, guarded := p.Guard(util.SplitArgs(command.Command), env); guarded {
+			return rule, true
+		}
+	}
+	return "", false
+}
+
+// shellBinaries run the script passed with -c.
+var shellBinaries = map[string]bool{
+	"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true,
+}
+
+var shellCommandFlag = regexp.MustCompile(`^-[a-z]*c$`)
+
+// ExpectedExitCodes returns the configured success exit codes for the tool
+// invoked by args, after wrappers such as sudo, or nil when the tool has no
+// entry.
+func (p *Policy) ExpectedExitCodes(args []string) []int {
+	args = commandArgs(args)
+	if len(args) == 0 {
+		return nil
+	}
+	codes := p.expectedExit[toolName(args[0])]
+	if len(codes) == 0 {
+		return nil
+	}
+	return append([]int(nil), codes...)
+}
+
+func toolName(arg string) string {
+	name := strings.ToLower(filepath.Base(strings.Trim(strings.TrimSpace(arg), `"'`)))
+	if name == "." || name == string(filepath.Separator) {
+		return ""
+	}
+	return strings.TrimSuffix(name, ".exe")
+}
+
+// commandWrappers run the command that follows them, possibly after their
+// own flags.
+var commandWrappers = map[string]bool{
+	"env": true, "sudo": true, "doas": true, "command": true, "exec": true,
+	"nohup": true, "time": true, "nice": true, "xargs": true,
+}
+
+// deniedTool names the binary of a denied command for Result.Tool, which
+// ends up in the audit log. Leading NAME=value assignments, wrappers such as
+// env and sudo, and their flags are skipped, so no token holding `=` is ever
+// returned.
+func deniedTool(args []string) string {
+	args = commandArgs(args)
+	if len(args) == 0 {
+		return ""
+	}
+	return toolName(args[0])
+}
+
+// commandArgs returns args from the binary that actually runs on, skipping
+// leading NAME=value assignments, wrappers such as env and sudo, and the
+// wrappers' flags. A command made only of wrappers returns the last one.
+func commandArgs(args []string) []string {
+	last := -1
+	for i, arg := range args {
+		if strings.Contains(arg, "=") || (last >= 0 && strings.HasPrefix(arg, "-")) {
+			continue
+		}
+		if !commandWrappers[toolName(arg)] {
+			return args[i:]
+		}
+		last = i
+	}
+	if last < 0 {
+		return nil
+	}
+	return args[last:]
+}
+
+func guardAppliesToEnv(envs []string, env string) bool {
+	if len(envs) == 0 {
+		return true
+	}
+	for _, candidate := range envs {
+		if strings.EqualFold(strings.TrimSpace(candidate), env) {
+			return true
+		}
+	}
+	return false
 }
 
 func (p *Policy) Apply(rawCommand string, args []string) Result {
-	if p.isDenied(rawCommand, args) {
+	return p.apply(rawCommand, args, nil)
+}
+
+// ApplyWithTrace is Apply that also reports every rule that matched.
+func (p *Policy) ApplyWithTrace(rawCommand string, args []string) (Result, Trace) {
+	var trace Trace
+	result := p.apply(rawCommand, args, &trace)
+	return result, trace
+}
+
+func (p *Policy) apply(rawCommand string, args []string, trace *Trace) Result {
+	if p.denied(rawCommand, args, trace) {
 		return Result{
 			Command: DeniedPlaceholder,
 			Denied:  true,
+			Rule:    p.denyRule(rawCommand, args, trace),
+			Tool:    deniedTool(args),
+		}
+	}
+	return Result{
+		Command: p.redactCommand(rawCommand, args, trace),
+		Denied:  false,
+	}
+}
+
+// redactCommand runs the tool sanitizers and redactors over a command that
+// passed the deny checks.
+func (p *Policy) redactCommand(rawCommand string, args []string, trace *Trace) string {
+	tool := ""
+	if len(args) > 0 {
+		tool = toolName(args[0])
+	}
+	// Tool sanitizers see the untouched argv, so they run before anything
+	// rewrites the command.
+	secrets := p.toolSecrets(rawCommand, args)
+	sanitized, preserved := preserveKubectlSetImageAssignments(redactSpans(rawCommand, secrets), args)
+	if trace != nil {
+		sanitized = p.traceRedactions(rawCommand, tool, secrets, preserved, trace)
+	} else {
+		for _, rule := range p.redact {
+			if rule.appliesTo(tool) {
+				sanitized = rule.replace(sanitized)
+			}
 		}
 	}
+	for _, arg := range preserved {
+		sanitized = strings.ReplaceAll(sanitized, arg.placeholder, arg.original)
+	}
+	return sanitized
+}
 
-	sanitized, preserved := preserveKubectlSetImageAssignments(rawCommand, args)
+// SanitizePath redacts a tracked file path before it is stored. Path
+// elements named after a redaction keyword, such as prod-db-password.txt,
+// are replaced whole, and the redactors and detectors run over the rest.
+func (p *Policy) SanitizePath(path string) string {
+	var b strings.Builder
+	start := 0
+	for i := 0; i <= len(path); i++ {
+		if i < len(path) && path[i] != '/' && path[i] != '\\' {
+			continue
+		}
+		if element := path[start:i]; element != "" && p.sensitiveKey(element) {
+			b.WriteString(RedactedValue)
+		} else {
+			b.WriteString(element)
+		}
+		if i < len(path) {
+			b.WriteByte(path[i])
+		}
+		start = i + 1
+	}
+	path = b.String()
 	for _, rule := range p.redact {
-		sanitized = rule.re.ReplaceAllString(sanitized, rule.repl)
+		if rule.appliesTo("") {
+			path = rule.replace(path)
+		}
+	}
+	return path
+}
+
+// UnparsableShellRule is the Result.Rule of shell lines denied because their
+// quotes, parentheses or backticks do not balance.
+const UnparsableShellRule = "builtin: unparsable shell line"
+
+// ApplyShell sanitizes a shell script one pipeline segment at a time so that
+// denylist and tool-specific rules see each command with its own argv.
+// Commands inside subshells, `$( … )` and backticks are checked against the
+// deny rules too, and a script that cannot be split is denied.
+func (p *Policy) ApplyShell(script string) Result {
+	return p.applyShell(script, nil)
+}
+
+// ApplyShellWithTrace is ApplyShell that also reports every rule that
+// matched, with spans in script.
+func (p *Policy) ApplyShellWithTrace(script string) (Result, Trace) {
+	var trace Trace
+	result := p.applyShell(script, &trace)
+	return result, trace
+}
+
+func (p *Policy) applyShell(script string, trace *Trace) Result {
+	commands, err := util.ShellCommands(script)
+	if err != nil {
+		if trace != nil {
+			trace.add(Match{Kind: MatchDeny, Rule: UnparsableShellRule, Start: -1, End: -1, Text: err.Error()})
+		}
+		return Result{Command: DeniedPlaceholder, Denied: true, Rule: UnparsableShellRule}
+	}
+
+	var denial *Result
+	for _, command := range commands {
+		args := util.SplitArgs(command.Command)
+		var commandTrace *Trace
+		if trace != nil {
+			commandTrace = &Trace{}
+		}
+		denied := p.denied(command.Command, args, commandTrace)
+		if commandTrace != nil {
+			trace.addShifted(commandTrace.Matches, command.Start)
+		}
+		if denied && denial == nil {
+			denial = &Result{
+				Command: DeniedPlaceholder,
+				Denied:  true,
+				Rule:    p.denyRule(command.Command, args, commandTrace),
+				Tool:    deniedTool(args),
+			}
+			if trace == nil {
+				break
+			}
+		}
 	}
-	for placeholder, original := range preserved {
-		sanitized = strings.ReplaceAll(sanitized, placeholder, original)
+	if denial != nil {
+		return *denial
 	}
 
+	var b strings.Builder
+	last := 0
+	for _, segment := range util.SplitShellScript(script) {
+		b.WriteString(script[last:segment.Start])
+		var segmentTrace *Trace
+		if trace != nil {
+			segmentTrace = &Trace{}
+		}
+		b.WriteString(p.redactCommand(segment.Command, util.SplitArgs(segment.Command), segmentTrace))
+		if segmentTrace != nil {
+			trace.addShifted(segmentTrace.Matches, segment.Start)
+		}
+		last = segment.End
+	}
+	b.WriteString(script[last:])
+
 	return Result{
-		Command: sanitized,
+		Command: b.String(),
 		Denied:  false,
 	}
 }
 
-func preserveKubectlSetImageAssignments(rawCommand string, args []string) (string, map[string]string) {
+// preservedArg is a `kubectl set image` assignment hidden behind a
+// placeholder while redactors run.
+type preservedArg struct {
+	placeholder string
+	original    string
+}
+
+func preserveKubectlSetImageAssignments(rawCommand string, args []string) (string, []preservedArg) {
 	if !isKubectlSetImage(args) {
 		return rawCommand, nil
 	}
 
 	sanitized := rawCommand
-	preserved := make(map[string]string)
+	preserved := make([]preservedArg, 0, 1)
 	index := 0
 
 	for _, arg := range args {
@@ -265,7 +726,7 @@ func preserveKubectlSetImageAssignments(rawCommand string, args []string) (strin
 		placeholder := "__COMMANDRY_IMG_ASSIGN_" + strconv.Itoa(index) + "__"
 		index++
 		sanitized = strings.ReplaceAll(sanitized, arg, placeholder)
-		preserved[placeholder] = arg
+		preserved = append(preserved, preservedArg{placeholder: placeholder, original: arg})
 	}
 
 	return sanitized, preserved
@@ -315,24 +776,63 @@ func containsSensitiveKeyword(v string) bool {
 	return false
 }
 
-func (p *Policy) isDenied(rawCommand string, args []string) bool {
+// denied reports whether args fail the allowlist or any denylist rule
+// matches. Without a trace it stops at the first match; with one it records
+// every match.
+func (p *Policy) denied(rawCommand string, args []string, trace *Trace) bool {
+	denied := false
+	if !p.allowed(args, trace) {
+		if trace == nil {
+			return true
+		}
+		denied = true
+	}
 	if len(args) > 0 {
 		binary := strings.ToLower(filepath.Base(args[0]))
 		if binary == "env" || binary == "printenv" {
-			return true
+			if trace == nil {
+				return true
+			}
+			trace.add(Match{Kind: MatchDeny, Rule: "builtin: " + binary, Start: -1, End: -1, Text: args[0]})
+			denied = true
 		}
 		if isKubectlSecretOutputDenied(args) {
-			return true
+			if trace == nil {
+				return true
+			}
+			trace.add(Match{Kind: MatchDeny, Rule: "builtin: kubectl get secret -o yaml|json", Start: -1, End: -1})
+			denied = true
 		}
 	}
 
 	for _, rule := range p.denylist {
-		if rule.MatchString(rawCommand) {
+		loc := rule.re.FindStringIndex(rawCommand)
+		if loc == nil {
+			continue
+		}
+		if trace == nil {
 			return true
 		}
+		trace.add(Match{Kind: MatchDeny, Rule: rule.pattern, Start: loc[0], End: loc[1], Text: rawCommand[loc[0]:loc[1]]})
+		denied = true
 	}
 
-	return false
+	return denied
+}
+
+// denyRule returns the first deny match in trace, tracing the denylist again
+// when the caller did not.
+func (p *Policy) denyRule(rawCommand string, args []string, trace *Trace) string {
+	if trace == nil {
+		trace = &Trace{}
+		p.denied(rawCommand, args, trace)
+	}
+	for _, m := range trace.Matches {
+		if m.Kind == MatchDeny {
+			return m.Rule
+		}
+	}
+	return ""
 }
 
 func isKubectlSecretOutputDenied(args []string) bool {
@@ -0,0 +1,155 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PolicyProfile overrides `policy` keys for sessions whose env matches a
+// `policy.profiles` key. Nil fields inherit the base policy; empty denylist and
+// keyword lists inherit too, like they keep the defaults at the top level.
+type PolicyProfile struct {
+	Denylist          []string
+	RedactionKeywords []string
+	// RedactionRules replaces the base rules when set.
+	RedactionRules []RedactionRule
+	// Detectors holds only the detectors the profile toggles.
+	Detectors       map[string]bool
+	EnforceDenylist *bool
+	// Mode is "" to inherit, or ModeDenylist or ModeAllowlist.
+	Mode string
+	// Allowlist replaces the base allowlist when set.
+	Allowlist []string
+	// Guarded replaces the base guards when set; empty disables them.
+	Guarded []GuardRule
+}
+
+// ProfileName returns the `policy.profiles` key that applies to env. Env names
+// match case-insensitively, like guarded `envs`.
+func (c Config) ProfileName(env string) (string, bool) {
+	return profileName(c.Profiles, env)
+}
+
+func profileName(profiles map[string]PolicyProfile, env string) (string, bool) {
+	env = strings.TrimSpace(env)
+	if env == "" {
+		return "", false
+	}
+	if _, ok := profiles[env]; ok {
+		return env, true
+	}
+	for name := range profiles {
+		if strings.EqualFold(name, env) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// ForEnv returns the config with the profile for env merged over the base
+// policy keys. Without a matching profile c is returned unchanged.
+func (c Config) ForEnv(env string) Config {
+	name, ok := c.ProfileName(env)
+	if !ok {
+		return c
+	}
+	out := c
+	out.Detectors = make(map[string]bool, len(c.Detectors))
+	for detector, enabled := range c.Detectors {
+		out.Detectors[detector] = enabled
+	}
+	out.applyProfile(c.Profiles[name])
+	return out
+}
+
+// policyProfile returns every policy key of c as a profile.
+func (c Config) policyProfile() PolicyProfile {
+	enforce := c.EnforceDenylist
+	return PolicyProfile{
+		Denylist:          c.Denylist,
+		RedactionKeywords: c.RedactionKeywords,
+		RedactionRules:    append(make([]RedactionRule, 0, len(c.RedactionRules)), c.RedactionRules...),
+		Detectors:         c.Detectors,
+		EnforceDenylist:   &enforce,
+		Mode:              c.Mode,
+		Allowlist:         append(make([]string, 0, len(c.Allowlist)), c.Allowlist...),
+		Guarded:           append(make([]GuardRule, 0, len(c.Guarded)), c.Guarded...),
+	}
+}
+
+// applyProfile merges prof over the policy keys of c.
+func (c *Config) applyProfile(prof PolicyProfile) {
+	if len(prof.Denylist) > 0 {
+		c.Denylist = prof.Denylist
+	}
+	if len(prof.RedactionKeywords) > 0 {
+		c.RedactionKeywords = prof.RedactionKeywords
+	}
+	if prof.RedactionRules != nil {
+		c.RedactionRules = prof.RedactionRules
+	}
+	for name, enabled := range prof.Detectors {
+		c.Detectors[name] = enabled
+	}
+	if prof.EnforceDenylist != nil {
+		c.EnforceDenylist = *prof.EnforceDenylist
+	}
+	if prof.Mode != "" {
+		c.Mode = prof.Mode
+	}
+	if prof.Allowlist != nil {
+		c.Allowlist = prof.Allowlist
+	}
+	if prof.Guarded != nil {
+		c.Guarded = prof.Guarded
+	}
+}
+
+// Resolver picks the effective policy for a session env. Every profile is
+// compiled up front so a bad profile fails at load time rather than on the
+// first command recorded in that env.
+type Resolver struct {
+	config   Config
+	base     *Policy
+	profiles map[string]*Policy
+}
+
+func NewResolver(cfg Config) (*Resolver, error) {
+	base, err := New(cfg.Options())
+	if err != nil {
+		return nil, err
+	}
+	r := &Resolver{config: cfg, base: base, profiles: make(map[string]*Policy, len(cfg.Profiles))}
+	for name := range cfg.Profiles {
+		p, err := New(cfg.ForEnv(name).Options())
+		if err != nil {
+			return nil, fmt.Errorf("policy profile %q: %w", name, err)
+		}
+		r.profiles[name] = p
+	}
+	return r, nil
+}
+
+// NewDefaultResolver resolves every env to NewDefault.
+func NewDefaultResolver() *Resolver {
+	return &Resolver{config: DefaultConfig(), base: NewDefault()}
+}
+
+// Default returns the base policy, used where no session env applies.
+func (r *Resolver) Default() *Policy {
+	return r.base
+}
+
+// ForEnv returns the policy for sessions with env, or Default when no
+// profile matches.
+func (r *Resolver) ForEnv(env string) *Policy {
+	if name, ok := r.config.ProfileName(env); ok {
+		return r.profiles[name]
+	}
+	return r.base
+}
+
+// Profile returns the profile name ForEnv uses for env.
+func (r *Resolver) Profile(env string) (string, bool) {
+	return r.config.ProfileName(env)
+}
@@ -0,0 +1,288 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ProjectConfigName is the project-local config file teams commit to a repo.
+const ProjectConfigName = ".commandry.yaml"
+
+// ProjectConfig is a parsed .commandry.yaml. It holds only `policy` keys.
+type ProjectConfig struct {
+	// Path is the file the config was read from.
+	Path     string
+	Policy   PolicyProfile
+	Profiles map[string]PolicyProfile
+}
+
+// projectFileConfig is the on-disk shape of .commandry.yaml.
+type projectFileConfig struct {
+	Policy *policySection `yaml:"policy"`
+}
+
+// FindProjectConfig walks up from dir to the enclosing git root and returns
+// the first .commandry.yaml it finds, or "". Outside a git repository only
+// dir itself is searched.
+func FindProjectConfig(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("resolve project directory: %w", err)
+	}
+	top := dir
+	if root, ok := gitRoot(dir); ok {
+		top = root
+	}
+	for {
+		path := filepath.Join(dir, ProjectConfigName)
+		info, err := os.Stat(path)
+		switch {
+		case err == nil && !info.IsDir():
+			return path, nil
+		case err != nil && !errors.Is(err, os.ErrNotExist):
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if dir == top || parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+func gitRoot(dir string) (string, bool) {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// LoadProjectConfig finds and parses the .commandry.yaml that applies to dir.
+// The result has an empty Path when there is none.
+func LoadProjectConfig(dir string) (ProjectConfig, error) {
+	path, err := FindProjectConfig(dir)
+	if err != nil || path == "" {
+		return ProjectConfig{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ProjectConfig{}, fmt.Errorf("read project config: %w", err)
+	}
+	project, err := ParseProjectConfig(string(data))
+	if err != nil {
+		return ProjectConfig{}, fmt.Errorf("%s: %w", path, err)
+	}
+	project.Path = path
+	return project, nil
+}
+
+// ParseProjectConfig reads .commandry.yaml content. It accepts the keys of
+// the config.yaml `policy` section, including profiles, and nothing else.
+func ParseProjectConfig(content string) (ProjectConfig, error) {
+	var file projectFileConfig
+	lines, err := decodeConfig(content, &file)
+	if err != nil {
+		return ProjectConfig{}, err
+	}
+
+	var (
+		project  ProjectConfig
+		problems []ConfigProblem
+	)
+	problem := func(path, format string, args ...any) {
+		problems = append(problems, ConfigProblem{Line: lines[path], Message: path + " " + fmt.Sprintf(format, args...)})
+	}
+	if p := file.Policy; p != nil {
+		project.Policy = p.policyKeys.profile("policy", lines, problem)
+		if p.Profiles != nil {
+			project.Profiles = parseProfiles(*p.Profiles, lines, problem)
+		}
+	}
+	if project.Policy.Mode == ModeAllowlist && len(project.Policy.Allowlist) == 0 {
+		problem("policy.mode", "is allowlist but policy.allowlist is empty")
+	}
+	for name, prof := range project.Profiles {
+		if prof.Mode == ModeAllowlist && len(prof.Allowlist) == 0 && len(project.Policy.Allowlist) == 0 {
+			problem("policy.profiles."+name, "uses allowlist mode with an empty allowlist")
+		}
+	}
+	if len(problems) > 0 {
+		sort.SliceStable(problems, func(i, j int) bool { return problems[i].Line < problems[j].Line })
+		return ProjectConfig{}, &ConfigError{Problems: problems}
+	}
+	return project, nil
+}
+
+// MergeProject adds the project policy to c. The project can extend the
+// denylist, keywords, redaction rules, guards and detectors, and turn on
+// enforce_denylist or allowlist mode. Turning enforce_denylist or a detector
+// off, leaving allowlist mode or adding to an active allowlist is applied only
+// with c.Project.AllowWeaken; otherwise it is dropped and described in the
+// returned notes, as are redaction rules whose name c already uses.
+//
+// Profiles of both files are resolved per env, so the result has a complete
+// profile for every env either file names.
+func (c Config) MergeProject(project ProjectConfig) (Config, []string) {
+	var notes []string
+	out := c
+	notes = append(notes, out.addProjectPolicy(project.Policy, "policy")...)
+
+	// Env names match case-insensitively; config.yaml spelling wins.
+	seen := make(map[string]bool)
+	names := make([]string, 0, len(c.Profiles)+len(project.Profiles))
+	for _, profiles := range []map[string]PolicyProfile{c.Profiles, project.Profiles} {
+		for name := range profiles {
+			if key := strings.ToLower(name); !seen[key] {
+				seen[key] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	if len(names) > 0 {
+		out.Profiles = make(map[string]PolicyProfile, len(names))
+	}
+	for _, env := range names {
+		eff := c.ForEnv(env)
+		notes = append(notes, eff.addProjectPolicy(project.Policy, "policy")...)
+		if name, ok := profileName(project.Profiles, env); ok {
+			notes = append(notes, eff.addProjectPolicy(project.Profiles[name], "policy.profiles."+name)...)
+		}
+		out.Profiles[env] = eff.policyProfile()
+	}
+	return out, dedupeNotes(notes)
+}
+
+// addProjectPolicy merges one set of project keys into c; see MergeProject.
+func (c *Config) addProjectPolicy(prof PolicyProfile, path string) []string {
+	var notes []string
+	weakens := func(key string) {
+		notes = append(notes, fmt.Sprintf("%s.%s: false would weaken config.yaml", path, key))
+	}
+
+	c.Denylist = appendMissing(c.Denylist, prof.Denylist)
+	c.RedactionKeywords = appendMissing(c.RedactionKeywords, prof.RedactionKeywords)
+
+	rules := append([]RedactionRule(nil), c.RedactionRules...)
+	for _, rule := range prof.RedactionRules {
+		if redactionRuleDefined(rules, rule.Name) {
+			notes = append(notes, fmt.Sprintf("%s.redaction_rules: %q is already defined in config.yaml", path, rule.Name))
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	c.RedactionRules = rules
+
+	detectors := make(map[string]bool, len(c.Detectors))
+	for name, enabled := range c.Detectors {
+		detectors[name] = enabled
+	}
+	names := make([]string, 0, len(prof.Detectors))
+	for name := range prof.Detectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		enabled := prof.Detectors[name]
+		if !enabled && detectors[name] && !c.Project.AllowWeaken {
+			weakens("detectors." + name)
+			continue
+		}
+		detectors[name] = enabled
+	}
+	c.Detectors = detectors
+
+	if enforce := prof.EnforceDenylist; enforce != nil {
+		if !*enforce && c.EnforceDenylist && !c.Project.AllowWeaken {
+			weakens("enforce_denylist")
+		} else {
+			c.EnforceDenylist = *enforce
+		}
+	}
+
+	// An allowlist narrows what is recorded, so switching to it is allowed,
+	// while widening an active allowlist or leaving it is weakening.
+	switch {
+	case prof.Mode == ModeAllowlist && c.Mode != ModeAllowlist:
+		c.Mode = ModeAllowlist
+		c.Allowlist = append([]string(nil), prof.Allowlist...)
+	case prof.Mode == ModeDenylist && c.Mode == ModeAllowlist && !c.Project.AllowWeaken:
+		notes = append(notes, fmt.Sprintf("%s.mode: denylist would weaken config.yaml", path))
+	default:
+		if prof.Mode != "" {
+			c.Mode = prof.Mode
+		}
+		extra := appendMissing(c.Allowlist, prof.Allowlist)[len(c.Allowlist):]
+		if len(extra) > 0 && c.Mode == ModeAllowlist && !c.Project.AllowWeaken {
+			notes = append(notes, fmt.Sprintf("%s.allowlist: %s would weaken config.yaml", path, strings.Join(extra, ", ")))
+		} else {
+			c.Allowlist = append(append([]string(nil), c.Allowlist...), extra...)
+		}
+	}
+
+	guarded := append([]GuardRule(nil), c.Guarded...)
+	for _, rule := range prof.Guarded {
+		if !guardDefined(guarded, rule) {
+			guarded = append(guarded, rule)
+		}
+	}
+	c.Guarded = guarded
+	return notes
+}
+
+func appendMissing(items, extra []string) []string {
+	out := append([]string(nil), items...)
+	for _, item := range extra {
+		found := false
+		for _, existing := range out {
+			if strings.EqualFold(existing, item) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func redactionRuleDefined(rules []RedactionRule, name string) bool {
+	for _, rule := range rules {
+		if rule.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func guardDefined(rules []GuardRule, rule GuardRule) bool {
+	for _, existing := range rules {
+		if existing.Pattern == rule.Pattern && strings.Join(existing.Envs, ",") == strings.Join(rule.Envs, ",") {
+			return true
+		}
+	}
+	return false
+}
+
+func dedupeNotes(notes []string) []string {
+	seen := make(map[string]bool, len(notes))
+	out := notes[:0]
+	for _, note := range notes {
+		if !seen[note] {
+			seen[note] = true
+			out = append(out, note)
+		}
+	}
+	return out
+}
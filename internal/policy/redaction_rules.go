@@ -0,0 +1,103 @@
+package policy
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RedactionRule is a user-defined redactor from `policy.redaction_rules`.
+// Rules run after the built-in detectors and redactors, in config order.
+type RedactionRule struct {
+	Name string
+	// Pattern is a Go regular expression.
+	Pattern string
+	// Replace is the replacement template; `$1` and `${name}` expand to
+	// capture groups. Empty replaces the whole match with [REDACTED].
+	Replace string
+	// Tools limits the rule to commands whose first word is one of these
+	// tool names, like capture.expected_exit_codes keys. Empty means all.
+	Tools []string
+}
+
+var replaceGroupRef = regexp.MustCompile(`\$(\{[^}]*\}|[A-Za-z0-9_]+)`)
+
+func compileRedactionRules(rules []RedactionRule) ([]redactor, error) {
+	out := make([]redactor, 0, len(rules))
+	for _, rule := range rules {
+		re, err := compileRedactionRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction rule %q: %w", rule.Name, err)
+		}
+		repl := rule.Replace
+		if repl == "" {
+			repl = RedactedValue
+		}
+		var tools map[string]bool
+		for _, tool := range rule.Tools {
+			if tool = toolName(tool); tool != "" {
+				if tools == nil {
+					tools = make(map[string]bool, len(rule.Tools))
+				}
+				tools[tool] = true
+			}
+		}
+		out = append(out, redactor{name: rule.Name, re: re, repl: repl, tools: tools, wholeMatch: true})
+	}
+	return out, nil
+}
+
+// compileRedactionRule compiles the pattern and checks that Replace only
+// refers to groups the pattern defines.
+func compileRedactionRule(rule RedactionRule) (*regexp.Regexp, error) {
+	if strings.TrimSpace(rule.Pattern) == "" {
+		return nil, fmt.Errorf("pattern cannot be empty")
+	}
+	re, err := regexp.Compile(rule.Pattern)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool)
+	for _, name := range re.SubexpNames() {
+		if name != "" {
+			names[name] = true
+		}
+	}
+	for _, m := range replaceGroupRef.FindAllStringSubmatch(strings.ReplaceAll(rule.Replace, "$$", ""), -1) {
+		ref := strings.Trim(m[1], "{}")
+		if n, err := strconv.Atoi(ref); err == nil {
+			if n > re.NumSubexp() {
+				return nil, fmt.Errorf("replace refers to group %d but the pattern has %d", n, re.NumSubexp())
+			}
+			continue
+		}
+		if !names[ref] {
+			return nil, fmt.Errorf("replace refers to unknown group %q", ref)
+		}
+	}
+	return re, nil
+}
+
+func (r redactor) appliesTo(tool string) bool {
+	return len(r.tools) == 0 || r.tools[tool]
+}
+
+func (r redactor) replace(s string) string {
+	if r.accept == nil {
+		return r.re.ReplaceAllString(s, r.repl)
+	}
+	var (
+		out  []byte
+		last int
+	)
+	for _, loc := range r.re.FindAllStringSubmatchIndex(s, -1) {
+		if !r.accept(s[loc[0]:loc[1]]) {
+			continue
+		}
+		out = append(out, s[last:loc[0]]...)
+		out = r.re.ExpandString(out, r.repl, s, loc)
+		last = loc[1]
+	}
+	return string(append(out, s[last:]...))
+}
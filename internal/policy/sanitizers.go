@@ -0,0 +1,395 @@
+package policy
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ArgSpan is a secret at bytes [Start, End) of Args[Arg].
+type ArgSpan struct {
+	Arg   int
+	Start int
+	End   int
+}
+
+// ArgSanitizer finds secrets using one tool's argument grammar, such as the
+// value of `docker run -e KEY=VALUE`, where a generic regex would have to
+// guess. Sanitizers see the parsed argv; Policy maps the spans they return
+// back onto the raw command.
+type ArgSanitizer interface {
+	// Name identifies the sanitizer in a Trace as "tool: <name>".
+	Name() string
+	// Tools lists the binaries the sanitizer handles, compared like
+	// capture.expected_exit_codes keys.
+	Tools() []string
+	// Sanitize returns the secret spans in args; args[0] is the binary.
+	// sensitive reports whether a key such as a helm value path or a form
+	// field names a secret under the policy's redaction keywords.
+	Sanitize(args []string, sensitive func(key string) bool) []ArgSpan
+}
+
+// builtinSanitizers run before the Options.Sanitizers of a policy.
+var builtinSanitizers = []ArgSanitizer{
+	dockerSanitizer{},
+	helmSanitizer{},
+	terraformSanitizer{},
+	awsSanitizer{},
+	databaseSanitizer{},
+	curlSanitizer{},
+}
+
+// sensitiveKeyHints extend the redaction keywords for key names only.
+var sensitiveKeyHints = []string{"pass", "pwd", "credential"}
+
+func indexSanitizers(extra []ArgSanitizer) map[string][]ArgSanitizer {
+	byTool := make(map[string][]ArgSanitizer)
+	for _, s := range append(append([]ArgSanitizer(nil), builtinSanitizers...), extra...) {
+		for _, tool := range s.Tools() {
+			if tool = toolName(tool); tool != "" {
+				byTool[tool] = append(byTool[tool], s)
+			}
+		}
+	}
+	return byTool
+}
+
+// sensitiveKey reports whether key contains a redaction keyword or hint,
+// ignoring case and `-`, `_` and `.` separators.
+func (p *Policy) sensitiveKey(key string) bool {
+	key = normalizeKey(key)
+	for _, keyword := range p.keywords {
+		if keyword = normalizeKey(keyword); keyword != "" && strings.Contains(key, keyword) {
+			return true
+		}
+	}
+	for _, hint := range sensitiveKeyHints {
+		if strings.Contains(key, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeKey(key string) string {
+	return strings.NewReplacer("-", "", "_", "", ".", "").Replace(strings.ToLower(key))
+}
+
+// toolSecrets runs the sanitizers for args[0] and returns their spans as
+// offsets into rawCommand, sorted and without overlaps. Spans in arguments
+// that cannot be found verbatim in rawCommand (escaped quotes, say) are
+// dropped and left to the regex redactors.
+func (p *Policy) toolSecrets(rawCommand string, args []string) []Match {
+	if len(args) == 0 {
+		return nil
+	}
+	sanitizers := p.sanitizers[toolName(args[0])]
+	if len(sanitizers) == 0 {
+		return nil
+	}
+	offsets := locateArgs(rawCommand, args)
+
+	var matches []Match
+	for _, s := range sanitizers {
+		for _, span := range s.Sanitize(args, p.sensitiveKey) {
+			if span.Arg < 0 || span.Arg >= len(args) || offsets[span.Arg] < 0 ||
+				span.Start < 0 || span.End > len(args[span.Arg]) || span.Start >= span.End {
+				continue
+			}
+			start := offsets[span.Arg] + span.Start
+			end := offsets[span.Arg] + span.End
+			matches = append(matches, Match{Kind: MatchRedact, Rule: "tool: " + s.Name(), Start: start, End: end, Text: rawCommand[start:end]})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Start < matches[j].Start })
+	out := matches[:0]
+	for _, m := range matches {
+		if len(out) > 0 && m.Start < out[len(out)-1].End {
+			continue
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// locateArgs finds each argument in rawCommand, left to right, and returns
+// its offset or -1.
+func locateArgs(rawCommand string, args []string) []int {
+	offsets := make([]int, len(args))
+	cursor := 0
+	for i, arg := range args {
+		offsets[i] = -1
+		if arg == "" {
+			continue
+		}
+		if idx := strings.Index(rawCommand[cursor:], arg); idx >= 0 {
+			offsets[i] = cursor + idx
+			cursor += idx + len(arg)
+		}
+	}
+	return offsets
+}
+
+// redactSpans replaces the spans, which must be sorted, with RedactedValue.
+func redactSpans(s string, spans []Match) string {
+	if len(spans) == 0 {
+		return s
+	}
+	var b strings.Builder
+	last := 0
+	for _, span := range spans {
+		b.WriteString(s[last:span.Start])
+		b.WriteString(RedactedValue)
+		last = span.End
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// flagValue reports where the value of flag starts if args[i] sets it: in
+// the same argument (`--flag=v`, `-fv` for one-letter flags) or, when
+// separate is true, as the whole next argument.
+func flagValue(args []string, i int, flag string, separate bool) (arg, offset int, ok bool) {
+	a := args[i]
+	switch {
+	case a == flag:
+		if separate && i+1 < len(args) {
+			return i + 1, 0, true
+		}
+	case len(flag) > 2 && strings.HasPrefix(a, flag+"="):
+		return i, len(flag) + 1, true
+	case len(flag) == 2 && flag[1] != '-' && len(a) > 2 && strings.HasPrefix(a, flag):
+		return i, 2, true
+	}
+	return 0, 0, false
+}
+
+// eachFlagValue calls fn for the value of every flag in flags.
+func eachFlagValue(args []string, flags []string, separate bool, fn func(arg, offset int)) {
+	for i := 1; i < len(args); i++ {
+		for _, flag := range flags {
+			if arg, offset, ok := flagValue(args, i, flag, separate); ok {
+				fn(arg, offset)
+				if arg > i {
+					i = arg
+				}
+				break
+			}
+		}
+	}
+}
+
+// assignmentValue returns the span of VALUE in a KEY=VALUE argument starting
+// at offset, if VALUE is not empty.
+func assignmentValue(args []string, arg, offset int) (string, ArgSpan, bool) {
+	a := args[arg]
+	eq := strings.IndexByte(a[offset:], '=')
+	if eq <= 0 || offset+eq+1 == len(a) {
+		return "", ArgSpan{}, false
+	}
+	return a[offset : offset+eq], ArgSpan{Arg: arg, Start: offset + eq + 1, End: len(a)}, true
+}
+
+func hasPositional(args []string, word string) bool {
+	for _, arg := range args[1:] {
+		if !strings.HasPrefix(arg, "-") {
+			return arg == word
+		}
+	}
+	return false
+}
+
+// dockerSanitizer hides every `-e/--env` and `--build-arg` value, since
+// container environment is where credentials are usually passed, and the
+// password of `docker login -p`.
+type dockerSanitizer struct{}
+
+func (dockerSanitizer) Name() string    { return "docker" }
+func (dockerSanitizer) Tools() []string { return []string{"docker", "podman"} }
+
+func (dockerSanitizer) Sanitize(args []string, _ func(string) bool) []ArgSpan {
+	var spans []ArgSpan
+	eachFlagValue(args, []string{"-e", "--env", "--build-arg"}, true, func(arg, offset int) {
+		if _, span, ok := assignmentValue(args, arg, offset); ok {
+			spans = append(spans, span)
+		}
+	})
+	if hasPositional(args, "login") {
+		eachFlagValue(args, []string{"-p", "--password"}, true, func(arg, offset int) {
+			spans = append(spans, ArgSpan{Arg: arg, Start: offset, End: len(args[arg])})
+		})
+	}
+	return spans
+}
+
+// helmSanitizer hides `--set` values whose key path is sensitive, such as
+// `db.password` in `--set image.tag=1.2,db.password=x`.
+type helmSanitizer struct{}
+
+func (helmSanitizer) Name() string    { return "helm" }
+func (helmSanitizer) Tools() []string { return []string{"helm"} }
+
+func (helmSanitizer) Sanitize(args []string, sensitive func(string) bool) []ArgSpan {
+	var spans []ArgSpan
+	eachFlagValue(args, []string{"--set", "--set-string", "--set-json", "--set-literal"}, true, func(arg, offset int) {
+		a := args[arg]
+		start := offset
+		for start < len(a) {
+			end := start
+			for end < len(a) && a[end] != ',' {
+				if a[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end > len(a) {
+				end = len(a)
+			}
+			if eq := strings.IndexByte(a[start:end], '='); eq > 0 && start+eq+1 < end && sensitive(a[start:start+eq]) {
+				spans = append(spans, ArgSpan{Arg: arg, Start: start + eq + 1, End: end})
+			}
+			start = end + 1
+		}
+	})
+	return spans
+}
+
+// terraformSanitizer hides sensitive `-var` and `-backend-config` values.
+type terraformSanitizer struct{}
+
+func (terraformSanitizer) Name() string    { return "terraform" }
+func (terraformSanitizer) Tools() []string { return []string{"terraform", "tofu"} }
+
+func (terraformSanitizer) Sanitize(args []string, sensitive func(string) bool) []ArgSpan {
+	var spans []ArgSpan
+	eachFlagValue(args, []string{"-var", "--var", "-backend-config", "--backend-config"}, true, func(arg, offset int) {
+		if key, span, ok := assignmentValue(args, arg, offset); ok && sensitive(key) {
+			spans = append(spans, span)
+		}
+	})
+	return spans
+}
+
+// awsSanitizer hides credential flags and `aws configure set` secrets.
+type awsSanitizer struct{}
+
+func (awsSanitizer) Name() string    { return "aws" }
+func (awsSanitizer) Tools() []string { return []string{"aws"} }
+
+func (awsSanitizer) Sanitize(args []string, sensitive func(string) bool) []ArgSpan {
+	var spans []ArgSpan
+	eachFlagValue(args, []string{"--secret-access-key", "--session-token", "--password"}, true, func(arg, offset int) {
+		spans = append(spans, ArgSpan{Arg: arg, Start: offset, End: len(args[arg])})
+	})
+	positional := make([]int, 0, len(args))
+	for i, arg := range args[1:] {
+		if !strings.HasPrefix(arg, "-") {
+			positional = append(positional, i+1)
+		}
+	}
+	if len(positional) >= 4 && args[positional[0]] == "configure" && args[positional[1]] == "set" &&
+		sensitive(args[positional[2]]) && !strings.HasSuffix(strings.ToLower(args[positional[2]]), "_id") {
+		value := positional[3]
+		spans = append(spans, ArgSpan{Arg: value, Start: 0, End: len(args[value])})
+	}
+	return spans
+}
+
+var (
+	connURIPassword     = regexp.MustCompile(`(?i)[a-z][a-z0-9+.\-]*://[^/\s:@]*:([^/\s@]+)@`)
+	connInfoPassword    = regexp.MustCompile(`(?i)(?:^|\s)password\s*=\s*('(?:[^'\\]|\\.)*'|\S+)`)
+	databaseClientTools = []string{"psql", "pg_dump", "pg_dumpall", "pg_restore", "mysql", "mysqldump", "mysqladmin", "mariadb"}
+)
+
+// databaseSanitizer hides passwords in PostgreSQL and MySQL client
+// connection strings and the attached `mysql -p<password>` form. A bare
+// `-p` makes mysql prompt, so the next argument is left alone.
+type databaseSanitizer struct{}
+
+func (databaseSanitizer) Name() string    { return "database" }
+func (databaseSanitizer) Tools() []string { return databaseClientTools }
+
+func (databaseSanitizer) Sanitize(args []string, _ func(string) bool) []ArgSpan {
+	var spans []ArgSpan
+	mysql := strings.HasPrefix(toolName(args[0]), "mysql") || toolName(args[0]) == "mariadb"
+	for i := 1; i < len(args); i++ {
+		a := args[i]
+		if mysql {
+			if _, offset, ok := flagValue(args, i, "-p", false); ok {
+				spans = append(spans, ArgSpan{Arg: i, Start: offset, End: len(a)})
+				continue
+			}
+			if _, offset, ok := flagValue(args, i, "--password", false); ok {
+				spans = append(spans, ArgSpan{Arg: i, Start: offset, End: len(a)})
+				continue
+			}
+		}
+		for _, loc := range connURIPassword.FindAllStringSubmatchIndex(a, -1) {
+			spans = append(spans, ArgSpan{Arg: i, Start: loc[2], End: loc[3]})
+		}
+		for _, loc := range connInfoPassword.FindAllStringSubmatchIndex(a, -1) {
+			spans = append(spans, ArgSpan{Arg: i, Start: loc[2], End: loc[3]})
+		}
+	}
+	return spans
+}
+
+var jsonStringField = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"\s*:\s*"((?:[^"\\]|\\.)*)"`)
+
+// curlSanitizer hides the password of `-u user:password`, bearer tokens and
+// sensitive fields of form or JSON request bodies.
+type curlSanitizer struct{}
+
+func (curlSanitizer) Name() string    { return "curl" }
+func (curlSanitizer) Tools() []string { return []string{"curl"} }
+
+func (curlSanitizer) Sanitize(args []string, sensitive func(string) bool) []ArgSpan {
+	var spans []ArgSpan
+	eachFlagValue(args, []string{"-u", "--user", "-U", "--proxy-user"}, true, func(arg, offset int) {
+		a := args[arg]
+		if colon := strings.IndexByte(a[offset:], ':'); colon >= 0 && offset+colon+1 < len(a) {
+			spans = append(spans, ArgSpan{Arg: arg, Start: offset + colon + 1, End: len(a)})
+		}
+	})
+	eachFlagValue(args, []string{"--oauth2-bearer"}, true, func(arg, offset int) {
+		spans = append(spans, ArgSpan{Arg: arg, Start: offset, End: len(args[arg])})
+	})
+	eachFlagValue(args, []string{"-d", "--data", "--data-raw", "--data-binary", "--data-urlencode", "-F", "--form", "--json"}, true, func(arg, offset int) {
+		spans = append(spans, bodySecrets(args, arg, offset, sensitive)...)
+	})
+	return spans
+}
+
+// bodySecrets finds sensitive values in a request body: `"key": "value"`
+// pairs of a JSON object, or `key=value` pairs joined by `&`. Bodies read
+// from a file (`@path`) are left alone.
+func bodySecrets(args []string, arg, offset int, sensitive func(string) bool) []ArgSpan {
+	a := args[arg]
+	body := strings.TrimSpace(a[offset:])
+	if strings.HasPrefix(body, "@") {
+		return nil
+	}
+	var spans []ArgSpan
+	if strings.HasPrefix(body, "{") || strings.HasPrefix(body, "[") {
+		for _, loc := range jsonStringField.FindAllStringSubmatchIndex(a[offset:], -1) {
+			if sensitive(a[offset+loc[2]:offset+loc[3]]) && loc[5] > loc[4] {
+				spans = append(spans, ArgSpan{Arg: arg, Start: offset + loc[4], End: offset + loc[5]})
+			}
+		}
+		return spans
+	}
+	start := offset
+	for start < len(a) {
+		end := strings.IndexByte(a[start:], '&')
+		if end < 0 {
+			end = len(a)
+		} else {
+			end += start
+		}
+		if eq := strings.IndexByte(a[start:end], '='); eq > 0 && start+eq+1 < end && sensitive(a[start:start+eq]) {
+			spans = append(spans, ArgSpan{Arg: arg, Start: start + eq + 1, End: end})
+		}
+		start = end + 1
+	}
+	return spans
+}
@@ -0,0 +1,206 @@
+package policy
+
+import "strings"
+
+// Match kinds reported in a Trace.
+const (
+	MatchDeny     = "deny"
+	MatchRedact   = "redact"
+	MatchPreserve = "preserve"
+	// MatchAllow is the allowlist entry that admitted the command.
+	MatchAllow = "allow"
+)
+
+// Match is one rule that fired while sanitizing a command.
+type Match struct {
+	Kind string
+	// Rule is the denylist pattern, allowlist entry, redactor name or
+	// "tool: " and the name of an ArgSanitizer.
+	Rule string
+	// Start and End are byte offsets of Text in the input command. Both are
+	// -1 for argv-based rules and for values that overlap text an earlier
+	// rule inserted.
+	Start int
+	End   int
+	Text  string
+}
+
+// Trace lists the rules that fired for one command in evaluation order.
+// Denylist matches stop evaluation, so a denied command has no redactions.
+type Trace struct {
+	Matches []Match
+}
+
+func (t *Trace) add(m Match) {
+	t.Matches = append(t.Matches, m)
+}
+
+// addShifted adds matches found in a command that starts at offset in the
+// traced input.
+func (t *Trace) addShifted(matches []Match, offset int) {
+	for _, m := range matches {
+		if m.Start >= 0 {
+			m.Start += offset
+			m.End += offset
+		}
+		t.add(m)
+	}
+}
+
+// traceRedactions runs the redactors like apply does while recording each
+// replaced value with its span in rawCommand.
+func (p *Policy) traceRedactions(rawCommand, tool string, secrets []Match, preserved []preservedArg, trace *Trace) string {
+	text := newTracedText(rawCommand)
+	for _, secret := range secrets {
+		trace.add(secret)
+	}
+	text.redactSpans(secrets)
+	for _, arg := range preserved {
+		trace.add(Match{Kind: MatchPreserve, Rule: "kubectl set image", Start: -1, End: -1, Text: arg.original})
+		text.replaceLiteral(arg.original, arg.placeholder)
+	}
+	for _, rule := range p.redact {
+		if rule.appliesTo(tool) {
+			text.redact(rule, rawCommand, trace)
+		}
+	}
+	return text.s
+}
+
+// tracedText is a partly sanitized command that remembers, for every byte,
+// its offset in the input (-1 for bytes a rule inserted).
+type tracedText struct {
+	s      string
+	origin []int
+}
+
+func newTracedText(s string) *tracedText {
+	origin := make([]int, len(s))
+	for i := range origin {
+		origin[i] = i
+	}
+	return &tracedText{s: s, origin: origin}
+}
+
+// redact is rule.re.ReplaceAllString on t. For built-in rules the reported
+// value is what follows the first group (the kept prefix such as `--token=`);
+// user rules report the whole match. Values that are already redacted are not
+// reported again.
+func (t *tracedText) redact(rule redactor, input string, trace *Trace) {
+	matches := rule.re.FindAllStringSubmatchIndex(t.s, -1)
+	if len(matches) == 0 {
+		return
+	}
+
+	var (
+		out    strings.Builder
+		origin = make([]int, 0, len(t.origin))
+		last   = 0
+	)
+	for _, loc := range matches {
+		if rule.accept != nil && !rule.accept(t.s[loc[0]:loc[1]]) {
+			continue
+		}
+		out.WriteString(t.s[last:loc[0]])
+		origin = append(origin, t.origin[last:loc[0]]...)
+
+		if start, end, ok := redactedSpan(rule, loc); ok {
+			if value := strings.Trim(t.s[start:end], `"'`); value != "" && value != RedactedValue {
+				trace.add(t.match(rule.name, start, end, input))
+			}
+		}
+
+		replacement := string(rule.re.ExpandString(nil, rule.repl, t.s, loc))
+		out.WriteString(replacement)
+		// Replacements start with the kept prefix; its bytes keep their origin.
+		prefix := 0
+		if !rule.wholeMatch && len(loc) > 3 && loc[2] >= 0 && strings.HasPrefix(replacement, t.s[loc[2]:loc[3]]) {
+			prefix = loc[3] - loc[2]
+			origin = append(origin, t.origin[loc[2]:loc[3]]...)
+		}
+		for i := prefix; i < len(replacement); i++ {
+			origin = append(origin, -1)
+		}
+		last = loc[1]
+	}
+	out.WriteString(t.s[last:])
+	t.origin = append(origin, t.origin[last:]...)
+	t.s = out.String()
+}
+
+func redactedSpan(rule redactor, loc []int) (int, int, bool) {
+	if rule.wholeMatch {
+		return loc[0], loc[1], true
+	}
+	if len(loc) < 4 || loc[3] < 0 {
+		return 0, 0, false
+	}
+	start, end := loc[3], loc[1]
+	if group := loc[len(loc)-1]; len(loc) > 4 && group >= 0 {
+		end = group
+	}
+	return start, end, true
+}
+
+// redactSpans is redactSpans on t; spans are offsets into the unchanged
+// input.
+func (t *tracedText) redactSpans(spans []Match) {
+	if len(spans) == 0 {
+		return
+	}
+	origin := make([]int, 0, len(t.origin))
+	last := 0
+	for _, span := range spans {
+		origin = append(origin, t.origin[last:span.Start]...)
+		for i := 0; i < len(RedactedValue); i++ {
+			origin = append(origin, -1)
+		}
+		last = span.End
+	}
+	t.origin = append(origin, t.origin[last:]...)
+	t.s = redactSpans(t.s, spans)
+}
+
+func (t *tracedText) replaceLiteral(old, replacement string) {
+	if old == "" {
+		return
+	}
+	var (
+		out    strings.Builder
+		origin = make([]int, 0, len(t.origin))
+		last   = 0
+	)
+	for {
+		idx := strings.Index(t.s[last:], old)
+		if idx < 0 {
+			break
+		}
+		idx += last
+		out.WriteString(t.s[last:idx])
+		origin = append(origin, t.origin[last:idx]...)
+		out.WriteString(replacement)
+		for i := 0; i < len(replacement); i++ {
+			origin = append(origin, -1)
+		}
+		last = idx + len(old)
+	}
+	out.WriteString(t.s[last:])
+	t.origin = append(origin, t.origin[last:]...)
+	t.s = out.String()
+}
+
+// match maps [start, end) in t back to input. Spans that cover inserted
+// bytes keep the current text and report -1.
+func (t *tracedText) match(rule string, start, end int, input string) Match {
+	m := Match{Kind: MatchRedact, Rule: rule, Start: -1, End: -1, Text: t.s[start:end]}
+	if start >= end {
+		return m
+	}
+	first, lastByte := t.origin[start], t.origin[end-1]
+	if first < 0 || lastByte < 0 || lastByte-first != end-1-start {
+		return m
+	}
+	m.Start, m.End = first, lastByte+1
+	m.Text = input[m.Start:m.End]
+	return m
+}
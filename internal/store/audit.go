@@ -0,0 +1,76 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// AppendAudit adds event to audit.jsonl. The log is append-only: nothing in
+// Commandry rewrites or trims it.
+func (s *JSONStore) AppendAudit(_ context.Context, event AuditEvent) error {
+	if err := s.requireInitialized(); err != nil {
+		return err
+	}
+
+	event.Timestamp = event.Timestamp.UTC()
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal audit event: %w", err)
+	}
+
+	file, err := os.OpenFile(s.auditPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("open audit log: %w", err)
+	}
+	defer file.Close()
+
+	// One write per event keeps concurrent appends from interleaving.
+	if _, err := file.Write(append(payload, '\n')); err != nil {
+		return fmt.Errorf("append audit event: %w", err)
+	}
+	return nil
+}
+
+// AuditEvents returns the events logged at or after since, oldest first. A
+// zero since returns all of them.
+func (s *JSONStore) AuditEvents(_ context.Context, since time.Time) ([]AuditEvent, error) {
+	if err := s.requireInitialized(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(s.auditPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	events := make([]AuditEvent, 0, 64)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var event AuditEvent
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			return nil, fmt.Errorf("decode audit event: %w", err)
+		}
+		if !since.IsZero() && event.Timestamp.Before(since) {
+			continue
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scan audit log: %w", err)
+	}
+	return events, nil
+}
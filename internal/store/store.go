@@ -30,12 +30,16 @@ type SessionStore interface {
 	IsInitialized(ctx context.Context) (bool, error)
 	RootDir() string
 	StartSession(ctx context.Context, title, env string, startedAt time.Time) (*Session, error)
+	StartSessionWithOptions(ctx context.Context, opts StartOptions) (*Session, error)
 	GetActiveSession(ctx context.Context) (*Session, error)
 	AddStep(ctx context.Context, step Step) error
 	StopSession(ctx context.Context, endedAt time.Time) (*Session, error)
 	LastSession(ctx context.Context) (*Session, error)
 	ListSessions(ctx context.Context, limit int) ([]Session, error)
 	SessionByID(ctx context.Context, id string) (*Session, error)
+	RewriteSessions(ctx context.Context, rewrite func(*Session) bool) (int, error)
+	AppendAudit(ctx context.Context, event AuditEvent) error
+	AuditEvents(ctx context.Context, since time.Time) ([]AuditEvent, error)
 }
 
 type JSONStore struct {
@@ -43,6 +47,7 @@ type JSONStore struct {
 	configPath      string
 	sessionsPath    string
 	activeStatePath string
+	auditPath       string
 }
 
 func DefaultRootDir() (string, error) {
@@ -59,6 +64,7 @@ func NewJSONStore(rootPath string) *JSONStore {
 		configPath:      filepath.Join(rootPath, "config.yaml"),
 		sessionsPath:    filepath.Join(rootPath, "sessions.jsonl"),
 		activeStatePath: filepath.Join(rootPath, "active_session.json"),
+		auditPath:       filepath.Join(rootPath, "audit.jsonl"),
 	}
 }
 
@@ -94,7 +100,26 @@ func (s *JSONStore) IsInitialized(_ context.Context) (bool, error) {
 	return !info.IsDir(), nil
 }
 
-func (s *JSONStore) StartSession(_ context.Context, title, env string, startedAt time.Time) (*Session, error) {
+// StartOptions configures a new session.
+type StartOptions struct {
+	Title     string
+	Env       string
+	StartedAt time.Time
+	// Plan records steps without executing them.
+	Plan bool
+	// Scope and ShellToken restrict hook recording; see Session.
+	Scope      string
+	ShellToken string
+	StartDir   string
+	// PolicyFingerprint identifies the policy steps will be sanitized with.
+	PolicyFingerprint string
+}
+
+func (s *JSONStore) StartSession(ctx context.Context, title, env string, startedAt time.Time) (*Session, error) {
+	return s.StartSessionWithOptions(ctx, StartOptions{Title: title, Env: env, StartedAt: startedAt})
+}
+
+func (s *JSONStore) StartSessionWithOptions(_ context.Context, opts StartOptions) (*Session, error) {
 	if err := s.requireInitialized(); err != nil {
 		return nil, err
 	}
@@ -110,11 +135,17 @@ func (s *JSONStore) StartSession(_ context.Context, title, env string, startedAt
 		}
 
 		session := &Session{
-			ID:        fmt.Sprintf("%d", startedAt.UnixNano()),
-			Title:     strings.TrimSpace(title),
-			Env:       strings.TrimSpace(env),
-			StartedAt: startedAt.UTC(),
-			Steps:     make([]Step, 0, 8),
+			ID:         fmt.Sprintf("%d", opts.StartedAt.UnixNano()),
+			Title:      strings.TrimSpace(opts.Title),
+			Env:        strings.TrimSpace(opts.Env),
+			Plan:       opts.Plan,
+			Scope:      strings.TrimSpace(opts.Scope),
+			ShellToken: strings.TrimSpace(opts.ShellToken),
+			StartDir:   opts.StartDir,
+			StartedAt:  opts.StartedAt.UTC(),
+			Steps:      make([]Step, 0, 8),
+
+			PolicyFingerprint: opts.PolicyFingerprint,
 		}
 
 		if err := s.writeJSONAtomic(s.activeStatePath, session); err != nil {
@@ -272,6 +303,49 @@ func (s *JSONStore) SessionByID(_ context.Context, id string) (*Session, error)
 	return nil, ErrSessionNotFound
 }
 
+// RewriteSessions calls rewrite for every completed session, oldest first,
+// and replaces sessions.jsonl in one atomic write when rewrite reports a
+// change for any of them. It returns the number of changed sessions. The
+// store lock is held throughout, so `stop` cannot append in between.
+func (s *JSONStore) RewriteSessions(_ context.Context, rewrite func(*Session) bool) (int, error) {
+	if err := s.requireInitialized(); err != nil {
+		return 0, err
+	}
+
+	changed := 0
+	err := s.withActiveStateLock(func() error {
+		sessions, err := s.readAllSessions()
+		if err != nil {
+			return err
+		}
+		for i := range sessions {
+			if rewrite(&sessions[i]) {
+				changed++
+			}
+		}
+		if changed == 0 {
+			return nil
+		}
+
+		var payload []byte
+		for _, session := range sessions {
+			line, err := json.Marshal(session)
+			if err != nil {
+				return fmt.Errorf("marshal session: %w", err)
+			}
+			payload = append(append(payload, line...), '\n')
+		}
+		if err := s.writeFileAtomic(s.sessionsPath, payload); err != nil {
+			return fmt.Errorf("rewrite sessions file: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return changed, nil
+}
+
 func (s *JSONStore) ensureConfigFile() error {
 	_, err := os.Stat(s.configPath)
 	if err == nil {
@@ -302,10 +376,70 @@ func (s *JSONStore) ensureConfigFile() error {
     - api_key
     - apikey
     - private_key
+  # Extra regex redactors, run after the built-in ones. replace may use
+  # capture groups ($1, ${name}); tools limits a rule to some commands.
+  # redaction_rules:
+  #   - name: aws-account-id
+  #     pattern: '\b\d{12}\b'
+  #     replace: '[AWS-ACCOUNT]'
+  #     tools: [aws]
+  redaction_rules: []
+  # Format-based secret detectors; set one to false to turn it off.
+  detectors:
+    aws_access_key: true
+    github_token: true
+    gitlab_token: true
+    jwt: true
+    private_key: true
+    slack_webhook: true
+    discord_webhook: true
+    basic_auth: true
+    high_entropy: true
   enforce_denylist: false
+  # allowlist mode records only commands listed under allowlist, such as
+  # "kubectl get" or "git"; anything else is stored as a policy placeholder.
+  mode: denylist
+  allowlist: []
+  # guarded patterns match each command from its binary on; a leading *
+  # matches anywhere in the command.
+  guarded:
+    - pattern: kubectl delete *
+      envs: [prod, production]
+    - pattern: terraform destroy*
+      envs: [prod, production]
+    - pattern: helm uninstall*
+      envs: [prod, production]
+    - pattern: "*DROP TABLE"
+      envs: [prod, production]
+  # Overrides for sessions started with a matching --env.
+  # profiles:
+  #   prod:
+  #     enforce_denylist: true
+  #   sandbox:
+  #     guarded: []
 capture:
   include_stdout: false
   include_stderr: false
+  expected_exit_codes:
+    grep: [0, 1]
+    diff: [0, 1]
+hooks:
+  # project_root: ~/src/my-service
+  ignore:
+    commands:
+      - git status*
+    binaries: [ls, cd, clear, cls, pwd]
+    regexes: []
+    min_duration_ms: 0
+    command_not_found: true
+export:
+  format: md
+  annotate: auto
+project:
+  # Merge the .commandry.yaml found between the working directory and its git root.
+  enabled: true
+  # Let .commandry.yaml turn off enforce_denylist or detectors set here.
+  allow_weaken: false
 `
 	return os.WriteFile(s.configPath, []byte(defaultConfig), 0o600)
 }
@@ -400,7 +534,10 @@ func (s *JSONStore) writeJSONAtomic(path string, value any) error {
 	if err != nil {
 		return fmt.Errorf("marshal json: %w", err)
 	}
+	return s.writeFileAtomic(path, payload)
+}
 
+func (s *JSONStore) writeFileAtomic(path string, payload []byte) error {
 	dir := filepath.Dir(path)
 	base := filepath.Base(path)
 
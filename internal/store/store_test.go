@@ -162,6 +162,65 @@ func TestJSONStoreListSessionsAndByID(t *testing.T) {
 	}
 }
 
+func TestJSONStoreRewriteSessions(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	root := newRetryTempDir(t)
+	s := NewJSONStore(root)
+	if err := s.Init(ctx); err != nil {
+		t.Fatalf("init failed: %v", err)
+	}
+
+	base := time.Date(2026, 2, 6, 12, 0, 0, 0, time.UTC)
+	ids := make([]string, 0, 2)
+	for i := 0; i < 2; i++ {
+		start := base.Add(time.Duration(i) * time.Minute)
+		session, err := s.StartSessionWithOptions(ctx, StartOptions{Title: "rewrite", StartedAt: start, PolicyFingerprint: "sha256:old"})
+		if err != nil {
+			t.Fatalf("start session %d failed: %v", i, err)
+		}
+		if err := s.AddStep(ctx, Step{Timestamp: start, Command: "deploy --key abc", Status: "OK"}); err != nil {
+			t.Fatalf("add step %d failed: %v", i, err)
+		}
+		if _, err := s.StopSession(ctx, start.Add(time.Second)); err != nil {
+			t.Fatalf("stop session %d failed: %v", i, err)
+		}
+		ids = append(ids, session.ID)
+	}
+
+	changed, err := s.RewriteSessions(ctx, func(*Session) bool { return false })
+	if err != nil || changed != 0 {
+		t.Fatalf("expected no changes, got %d (err=%v)", changed, err)
+	}
+
+	changed, err = s.RewriteSessions(ctx, func(session *Session) bool {
+		if session.ID != ids[1] {
+			return false
+		}
+		session.Steps[0].Command = "deploy --key [REDACTED]"
+		session.PolicyFingerprint = "sha256:new"
+		return true
+	})
+	if err != nil || changed != 1 {
+		t.Fatalf("expected 1 changed session, got %d (err=%v)", changed, err)
+	}
+
+	sessions, err := s.ListSessions(ctx, 0)
+	if err != nil {
+		t.Fatalf("list sessions failed: %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].ID != ids[1] || sessions[1].ID != ids[0] {
+		t.Fatalf("expected both sessions in order, got %+v", sessions)
+	}
+	if sessions[0].Steps[0].Command != "deploy --key [REDACTED]" || sessions[0].PolicyFingerprint != "sha256:new" {
+		t.Fatalf("rewrite not persisted: %+v", sessions[0])
+	}
+	if sessions[1].Steps[0].Command != "deploy --key abc" || sessions[1].PolicyFingerprint != "sha256:old" {
+		t.Fatalf("unchanged session was modified: %+v", sessions[1])
+	}
+}
+
 func TestJSONStoreLastSessionLargeRecord(t *testing.T) {
 	t.Parallel()
 
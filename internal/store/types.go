@@ -3,20 +3,57 @@ package store
 import "time"
 
 type Step struct {
-	Timestamp  time.Time `json:"timestamp"`
-	Command    string    `json:"command"`
-	Status     string    `json:"status,omitempty"` // OK, FAILED, REDACTED
-	Reason     string    `json:"reason,omitempty"` // nonzero_exit, command_not_found, start_failed, policy_redacted, policy_blocked, unknown
-	ExitCode   *int      `json:"exit_code,omitempty"`
-	DurationMS int64     `json:"duration_ms"`
-	CWD        string    `json:"cwd,omitempty"`
+	Timestamp    time.Time    `json:"timestamp"`
+	Command      string       `json:"command"`
+	Status       string       `json:"status,omitempty"` // OK, FAILED, UNEXPECTED, REDACTED, ABORTED, PLANNED
+	Reason       string       `json:"reason,omitempty"` // nonzero_exit, command_not_found, start_failed, policy_redacted, policy_blocked, guard_declined, guard_unconfirmed, unexpected_exit, unexpected_success, unknown
+	ExitCode     *int         `json:"exit_code,omitempty"`
+	DurationMS   int64        `json:"duration_ms"`
+	CWD          string       `json:"cwd,omitempty"`
+	Shell        bool         `json:"shell,omitempty"`  // command is a shell script recorded via run --shell
+	Source       string       `json:"source,omitempty"` // hook for shell hook lines, empty for cmdry run
+	Files        []FileChange `json:"files,omitempty"`
+	Guard        *GuardCheck  `json:"guard,omitempty"`
+	ExpectedExit []int        `json:"expected_exit,omitempty"` // run --expect-exit or capture.expected_exit_codes
+	ExpectFail   bool         `json:"expect_fail,omitempty"`   // run --expect-fail
+}
+
+// GuardCheck records the confirmation asked for a guarded command.
+type GuardCheck struct {
+	Rule      string `json:"rule"`
+	Confirmed bool   `json:"confirmed"`
+	Via       string `json:"via,omitempty"` // prompt, yes_flag, non_interactive
+}
+
+type FileChange struct {
+	Path   string `json:"path"`
+	Change string `json:"change"` // created, modified, deleted
 }
 
 type Session struct {
-	ID        string     `json:"id"`
-	Title     string     `json:"title"`
-	Env       string     `json:"env,omitempty"`
-	StartedAt time.Time  `json:"started_at"`
-	EndedAt   *time.Time `json:"ended_at,omitempty"`
-	Steps     []Step     `json:"steps"`
+	ID         string     `json:"id"`
+	Title      string     `json:"title"`
+	Env        string     `json:"env,omitempty"`
+	Plan       bool       `json:"plan,omitempty"`        // started with start --plan; run records PLANNED steps
+	Scope      string     `json:"scope,omitempty"`       // hooks only record commands run inside this directory
+	ShellToken string     `json:"shell_token,omitempty"` // hooks only record from the shell exporting this COMMANDRY_SHELL_TOKEN
+	StartDir   string     `json:"start_dir,omitempty"`   // working directory of `cmdry start`; export relativizes cd lines to it
+	StartedAt  time.Time  `json:"started_at"`
+	EndedAt    *time.Time `json:"ended_at,omitempty"`
+	Steps      []Step     `json:"steps"`
+
+	// PolicyFingerprint identifies the policy that sanitized the steps; see
+	// policy.Policy.Fingerprint. Empty for sessions recorded before it existed.
+	PolicyFingerprint string `json:"policy_fingerprint,omitempty"`
+}
+
+// AuditEvent is one policy block or redaction in audit.jsonl. It never holds
+// the command itself.
+type AuditEvent struct {
+	Timestamp time.Time `json:"timestamp"`
+	SessionID string    `json:"session_id,omitempty"`
+	Event     string    `json:"event"`            // policy_blocked, policy_redacted
+	Rule      string    `json:"rule,omitempty"`   // denylist pattern, builtin or allowlist rule
+	Tool      string    `json:"tool,omitempty"`   // binary of the command
+	Source    string    `json:"source,omitempty"` // run, hook
 }
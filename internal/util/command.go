@@ -1,6 +1,11 @@
 package util
 
-import "strings"
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
 
 func JoinCommand(args []string) string {
 	parts := make([]string, 0, len(args))
@@ -22,3 +27,284 @@ func quoteArg(arg string) string {
 	escaped = strings.ReplaceAll(escaped, `"`, `\"`)
 	return `"` + escaped + `"`
 }
+
+// ShellSegment is one simple command of a shell script. Start and End are byte
+// offsets of the trimmed command text in the original script.
+type ShellSegment struct {
+	Command string
+	Start   int
+	End     int
+}
+
+// SplitShellScript splits a shell script on pipeline and list operators
+// (|, |&, ||, &&, ;, & and newlines) outside quotes, subshells and backticks.
+func SplitShellScript(script string) []ShellSegment {
+	segments := make([]ShellSegment, 0, 4)
+	var (
+		inSingle bool
+		inDouble bool
+		inTick   bool
+		escaped  bool
+		depth    int
+		start    int
+	)
+
+	flush := func(end int) {
+		segStart, segEnd := start, end
+		for segStart < segEnd && isShellSpace(script[segStart]) {
+			segStart++
+		}
+		for segEnd > segStart && isShellSpace(script[segEnd-1]) {
+			segEnd--
+		}
+		if segStart < segEnd {
+			segments = append(segments, ShellSegment{
+				Command: script[segStart:segEnd],
+				Start:   segStart,
+				End:     segEnd,
+			})
+		}
+	}
+
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		if escaped {
+			escaped = false
+			continue
+		}
+		switch {
+		case inSingle:
+			if c == '\'' {
+				inSingle = false
+			}
+			continue
+		case c == '\\':
+			escaped = true
+			continue
+		case inDouble:
+			if c == '"' {
+				inDouble = false
+			}
+			continue
+		case c == '`':
+			inTick = !inTick
+			continue
+		case inTick:
+			continue
+		}
+
+		switch c {
+		case '\'':
+			inSingle = true
+		case '"':
+			inDouble = true
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+			}
+		case '|', ';', '\n':
+			if depth > 0 {
+				continue
+			}
+			flush(i)
+			if c == '|' && i+1 < len(script) && (script[i+1] == '|' || script[i+1] == '&') {
+				i++
+			}
+			start = i + 1
+		case '&':
+			if depth > 0 {
+				continue
+			}
+			// Keep redirections such as 2>&1 and &>file inside the segment.
+			if i > 0 && (script[i-1] == '>' || script[i-1] == '<') {
+				continue
+			}
+			if i+1 < len(script) && script[i+1] == '>' {
+				continue
+			}
+			flush(i)
+			if i+1 < len(script) && script[i+1] == '&' {
+				i++
+			}
+			start = i + 1
+		}
+	}
+	flush(len(script))
+	return segments
+}
+
+// SplitArgs tokenizes a single shell command into argv, removing quotes and
+// backslash escapes. Operators are not interpreted.
+func SplitArgs(command string) []string {
+	args := make([]string, 0, 8)
+	var (
+		current  strings.Builder
+		inSingle bool
+		inDouble bool
+		hasToken bool
+	)
+
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case inSingle:
+			if c == '\'' {
+				inSingle = false
+				continue
+			}
+			current.WriteByte(c)
+		case inDouble:
+			if c == '"' {
+				inDouble = false
+				continue
+			}
+			if c == '\\' && i+1 < len(command) && (command[i+1] == '"' || command[i+1] == '\\') {
+				i++
+				c = command[i]
+			}
+			current.WriteByte(c)
+		case c == '\'':
+			inSingle = true
+			hasToken = true
+		case c == '"':
+			inDouble = true
+			hasToken = true
+		case c == '\\' && i+1 < len(command):
+			i++
+			current.WriteByte(command[i])
+			hasToken = true
+		case isShellSpace(c):
+			if hasToken {
+				args = append(args, current.String())
+				current.Reset()
+				hasToken = false
+			}
+		default:
+			current.WriteByte(c)
+			hasToken = true
+		}
+	}
+	if hasToken {
+		args = append(args, current.String())
+	}
+	return args
+}
+
+func isShellSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// ShellCommands returns every simple command of script: the segments of
+// SplitShellScript and, recursively, the commands inside subshells `( … )`,
+// command substitutions `$( … )` and backticks. A segment that is only a
+// subshell group is replaced by the commands in it. Offsets are into script.
+// Unbalanced quotes, parentheses or backticks are an error, so callers can
+// refuse what they cannot inspect.
+func ShellCommands(script string) ([]ShellSegment, error) {
+	scanner := &shellScanner{s: script}
+	if _, err := scanner.scan(0, 0); err != nil {
+		return nil, err
+	}
+
+	// Inner scripts are recorded before the ones around them.
+	sort.Slice(scanner.nested, func(i, j int) bool { return scanner.nested[i].Start < scanner.nested[j].Start })
+	commands := make([]ShellSegment, 0, 4)
+	for _, segment := range SplitShellScript(script) {
+		if !strings.HasPrefix(segment.Command, "(") {
+			commands = append(commands, segment)
+		}
+	}
+	for _, nested := range scanner.nested {
+		for _, segment := range SplitShellScript(nested.Command) {
+			if strings.HasPrefix(segment.Command, "(") {
+				continue
+			}
+			segment.Start += nested.Start
+			segment.End += nested.Start
+			commands = append(commands, segment)
+		}
+	}
+	return commands, nil
+}
+
+// shellScanner finds the scripts nested in a shell script, at any depth.
+type shellScanner struct {
+	s      string
+	nested []ShellSegment
+}
+
+// scan reads s from i up to the unquoted byte close, or to the end of s when
+// close is 0, and returns the offset where it stopped.
+func (sc *shellScanner) scan(i int, close byte) (int, error) {
+	s := sc.s
+	inDouble := false
+	wordStart := true
+	for ; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\':
+			i++
+		case c == close && (close == '`' || !inDouble):
+			return i, nil
+		case c == '\'' && !inDouble:
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return 0, errors.New("unterminated single quote")
+			}
+			i += end + 1
+		case c == '"':
+			inDouble = !inDouble
+		case c == '#' && !inDouble && wordStart:
+			// Comments may hold unbalanced quotes; skip to the newline.
+			end := strings.IndexByte(s[i:], '\n')
+			if end < 0 {
+				return sc.end(close, inDouble)
+			}
+			i += end - 1
+		case c == '`':
+			end, err := sc.nest(i+1, '`')
+			if err != nil {
+				return 0, err
+			}
+			i = end
+		case c == '$' && i+1 < len(s) && s[i+1] == '(':
+			end, err := sc.nest(i+2, ')')
+			if err != nil {
+				return 0, err
+			}
+			i = end
+		case c == '(' && !inDouble:
+			end, err := sc.nest(i+1, ')')
+			if err != nil {
+				return 0, err
+			}
+			i = end
+		case c == ')' && !inDouble:
+			return 0, errors.New("unbalanced )")
+		}
+		wordStart = isShellSpace(c) || strings.IndexByte(";|&(", c) >= 0
+	}
+	return sc.end(close, inDouble)
+}
+
+func (sc *shellScanner) end(close byte, inDouble bool) (int, error) {
+	switch {
+	case close != 0:
+		return 0, fmt.Errorf("unterminated %q", close)
+	case inDouble:
+		return 0, errors.New("unterminated double quote")
+	}
+	return len(sc.s), nil
+}
+
+// nest scans a nested script starting at start and records it.
+func (sc *shellScanner) nest(start int, close byte) (int, error) {
+	end, err := sc.scan(start, close)
+	if err != nil {
+		return 0, err
+	}
+	sc.nested = append(sc.nested, ShellSegment{Command: sc.s[start:end], Start: start, End: end})
+	return end, nil
+}
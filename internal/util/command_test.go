@@ -0,0 +1,94 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitShellScript(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{name: "single command", script: "kubectl get pods", want: []string{"kubectl get pods"}},
+		{name: "pipeline", script: "kubectl get pods | grep api", want: []string{"kubectl get pods", "grep api"}},
+		{name: "list operators", script: "make build && make test || echo failed; ls", want: []string{"make build", "make test", "echo failed", "ls"}},
+		{name: "quoted operators", script: `grep "a|b" file | sed 's/;/,/'`, want: []string{`grep "a|b" file`, `sed 's/;/,/'`}},
+		{name: "redirect kept", script: "terraform plan 2>&1 | tee plan.log", want: []string{"terraform plan 2>&1", "tee plan.log"}},
+		{name: "subshell kept", script: "echo $(date | cut -c1-3) | cat", want: []string{"echo $(date | cut -c1-3)", "cat"}},
+		{name: "background and newline", script: "sleep 1 &\nwait", want: []string{"sleep 1", "wait"}},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			segments := SplitShellScript(tc.script)
+			got := make([]string, 0, len(segments))
+			for _, segment := range segments {
+				if tc.script[segment.Start:segment.End] != segment.Command {
+					t.Fatalf("segment offsets do not match command %q", segment.Command)
+				}
+				got = append(got, segment.Command)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("segments mismatch\n got: %#v\nwant: %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	t.Parallel()
+
+	got := SplitArgs(`psql "host=db user=app" -c 'select 1' a\ b`)
+	want := []string{"psql", "host=db user=app", "-c", "select 1", "a b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("args mismatch\n got: %#v\nwant: %#v", got, want)
+	}
+}
+
+func TestShellCommands(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{name: "plain list", script: "make build && make test", want: []string{"make build", "make test"}},
+		{name: "subshell group", script: "(kubectl get secret x -o yaml)", want: []string{"kubectl get secret x -o yaml"}},
+		{name: "group after list", script: "echo ok && (cd infra; terraform apply)", want: []string{"echo ok", "cd infra", "terraform apply"}},
+		{name: "command substitution", script: `echo "$(kubectl get secret x | base64)"`, want: []string{`echo "$(kubectl get secret x | base64)"`, "kubectl get secret x", "base64"}},
+		{name: "backticks", script: "echo `whoami`", want: []string{"echo `whoami`", "whoami"}},
+		{name: "nested", script: "a $(b $(c))", want: []string{"a $(b $(c))", "b $(c)", "c"}},
+		{name: "quoted parentheses", script: `echo "(x)" ')'`, want: []string{`echo "(x)" ')'`}},
+		{name: "comment with quote", script: "ls # it's fine", want: []string{"ls # it's fine"}},
+	}
+	for _, tc := range tests {
+		commands, err := ShellCommands(tc.script)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+		got := make([]string, 0, len(commands))
+		for _, command := range commands {
+			if tc.script[command.Start:command.End] != command.Command {
+				t.Fatalf("%s: offsets do not match command %q", tc.name, command.Command)
+			}
+			got = append(got, command.Command)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("%s: commands mismatch\n got: %#v\nwant: %#v", tc.name, got, tc.want)
+		}
+	}
+
+	for _, script := range []string{"echo 'open", `echo "open`, "(echo open", "echo $(open", "echo `open", "case x in a) ;; esac"} {
+		if _, err := ShellCommands(script); err == nil {
+			t.Fatalf("expected %q to be rejected", script)
+		}
+	}
+}